@@ -195,3 +195,52 @@ func TestFetchValidators(t *testing.T) {
 		require.Equal(t, 0, len(validators))
 	})
 }
+
+func TestPublishBlock(t *testing.T) {
+	block := &types.SignedBeaconBlock{
+		Message: &types.BeaconBlock{
+			Body: &types.BeaconBlockBody{
+				ExecutionPayload: &types.ExecutionPayload{},
+			},
+		},
+	}
+
+	t.Run("returns success if at least one beacon node publishes", func(t *testing.T) {
+		backend := newTestBackend(t, 3)
+		backend.beaconInstances[0].MockPublishBlockErr = errTest
+		backend.beaconInstances[1].MockPublishBlockErr = errTest
+		code, err := backend.beaconClient.PublishBlock(block)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("returns error if all beacon nodes fail", func(t *testing.T) {
+		backend := newTestBackend(t, 2)
+		backend.beaconInstances[0].MockPublishBlockErr = errTest
+		backend.beaconInstances[1].MockPublishBlockErr = errTest
+		code, err := backend.beaconClient.PublishBlock(block)
+		require.Equal(t, errTest, err)
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("returns on first success", func(t *testing.T) {
+		backend := newTestBackend(t, 2)
+		backend.beaconInstances[1].ResponseDelay = time.Second
+		timeStart := time.Now()
+		_, err := backend.beaconClient.PublishBlock(block)
+		require.NoError(t, err)
+		require.Less(t, time.Since(timeStart), time.Second)
+	})
+
+	t.Run("waits for all beacon nodes if configured", func(t *testing.T) {
+		backend := newTestBackend(t, 2)
+		backend.beaconClient.(*MultiBeaconClient).ffPublishBlockWaitForAll = true
+		backend.beaconInstances[1].ResponseDelay = 50 * time.Millisecond
+		backend.beaconInstances[1].MockPublishBlockErr = errTest
+		timeStart := time.Now()
+		code, err := backend.beaconClient.PublishBlock(block)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		require.GreaterOrEqual(t, time.Since(timeStart), 50*time.Millisecond)
+	})
+}
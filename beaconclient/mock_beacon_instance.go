@@ -1,6 +1,8 @@
 package beaconclient
 
 import (
+	"context"
+	"net/http"
 	"sync"
 	"time"
 
@@ -16,6 +18,7 @@ type MockBeaconInstance struct {
 	MockProposerDuties     *ProposerDutiesResponse
 	MockProposerDutiesErr  error
 	MockFetchValidatorsErr error
+	MockPublishBlockErr    error
 
 	ResponseDelay time.Duration
 }
@@ -81,7 +84,10 @@ func (c *MockBeaconInstance) CurrentSlot() (uint64, error) {
 	return c.MockSyncStatus.HeadSlot, nil
 }
 
-func (c *MockBeaconInstance) SubscribeToHeadEvents(slotC chan HeadEventData) {}
+func (c *MockBeaconInstance) SubscribeToHeadEvents(ctx context.Context, slotC chan HeadEventData) {}
+
+func (c *MockBeaconInstance) SubscribeToFinalizedCheckpointEvents(finalizedC chan FinalizedCheckpointEventData) {
+}
 
 func (c *MockBeaconInstance) GetProposerDuties(epoch uint64) (*ProposerDutiesResponse, error) {
 	c.addDelay()
@@ -99,7 +105,11 @@ func (c *MockBeaconInstance) addDelay() {
 }
 
 func (c *MockBeaconInstance) PublishBlock(block *types.SignedBeaconBlock) (code int, err error) {
-	return 0, nil
+	c.addDelay()
+	if c.MockPublishBlockErr != nil {
+		return http.StatusBadRequest, c.MockPublishBlockErr
+	}
+	return http.StatusOK, nil
 }
 
 func (c *MockBeaconInstance) GetGenesis() (*GetGenesisResponse, error) {
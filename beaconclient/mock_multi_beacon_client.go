@@ -1,6 +1,8 @@
 package beaconclient
 
 import (
+	"context"
+
 	"github.com/flashbots/go-boost-utils/types"
 )
 
@@ -10,7 +12,10 @@ func NewMockMultiBeaconClient() *MockMultiBeaconClient {
 	return &MockMultiBeaconClient{}
 }
 
-func (*MockMultiBeaconClient) SubscribeToHeadEvents(slotC chan HeadEventData) {}
+func (*MockMultiBeaconClient) SubscribeToHeadEvents(ctx context.Context, slotC chan HeadEventData) {}
+
+func (*MockMultiBeaconClient) SubscribeToFinalizedCheckpointEvents(finalizedC chan FinalizedCheckpointEventData) {
+}
 
 func (*MockMultiBeaconClient) BestSyncStatus() (*SyncStatusPayloadData, error) {
 	return &SyncStatusPayloadData{HeadSlot: 1}, nil
@@ -2,6 +2,7 @@
 package beaconclient
 
 import (
+	"context"
 	"errors"
 	"os"
 	"sync"
@@ -19,7 +20,8 @@ var (
 // IMultiBeaconClient is the interface for the MultiBeaconClient, which can manage several beacon client instances under the hood
 type IMultiBeaconClient interface {
 	BestSyncStatus() (*SyncStatusPayloadData, error)
-	SubscribeToHeadEvents(slotC chan HeadEventData)
+	SubscribeToHeadEvents(ctx context.Context, slotC chan HeadEventData)
+	SubscribeToFinalizedCheckpointEvents(finalizedC chan FinalizedCheckpointEventData)
 
 	// FetchValidators returns all active and pending validators from the beacon node
 	FetchValidators(headSlot uint64) (map[types.PubkeyHex]ValidatorResponseEntry, error)
@@ -35,7 +37,8 @@ type IMultiBeaconClient interface {
 type IBeaconInstance interface {
 	SyncStatus() (*SyncStatusPayloadData, error)
 	CurrentSlot() (uint64, error)
-	SubscribeToHeadEvents(slotC chan HeadEventData)
+	SubscribeToHeadEvents(ctx context.Context, slotC chan HeadEventData)
+	SubscribeToFinalizedCheckpointEvents(finalizedC chan FinalizedCheckpointEventData)
 	FetchValidators(headSlot uint64) (map[types.PubkeyHex]ValidatorResponseEntry, error)
 	GetProposerDuties(epoch uint64) (*ProposerDutiesResponse, error)
 	GetURI() string
@@ -53,6 +56,7 @@ type MultiBeaconClient struct {
 
 	// feature flags
 	ffAllowSyncingBeaconNode bool
+	ffPublishBlockWaitForAll bool
 }
 
 func NewMultiBeaconClient(log *logrus.Entry, beaconInstances []IBeaconInstance) *MultiBeaconClient {
@@ -69,6 +73,11 @@ func NewMultiBeaconClient(log *logrus.Entry, beaconInstances []IBeaconInstance)
 		client.ffAllowSyncingBeaconNode = true
 	}
 
+	if os.Getenv("PUBLISH_BLOCK_WAIT_FOR_ALL") == "1" {
+		client.log.Warn("env: PUBLISH_BLOCK_WAIT_FOR_ALL: wait for all CL nodes when publishing a block")
+		client.ffPublishBlockWaitForAll = true
+	}
+
 	return client
 }
 
@@ -125,10 +134,18 @@ func (c *MultiBeaconClient) BestSyncStatus() (*SyncStatusPayloadData, error) {
 }
 
 // SubscribeToHeadEvents subscribes to head events from all beacon nodes. A single head event will be received multiple times,
-// likely once for every beacon nodes.
-func (c *MultiBeaconClient) SubscribeToHeadEvents(slotC chan HeadEventData) {
+// likely once for every beacon nodes. Cancelling the context ends the subscriptions.
+func (c *MultiBeaconClient) SubscribeToHeadEvents(ctx context.Context, slotC chan HeadEventData) {
+	for _, instance := range c.beaconInstances {
+		go instance.SubscribeToHeadEvents(ctx, slotC)
+	}
+}
+
+// SubscribeToFinalizedCheckpointEvents subscribes to finalized_checkpoint events from all beacon nodes. As with head events,
+// a single checkpoint will likely be received once for every beacon node.
+func (c *MultiBeaconClient) SubscribeToFinalizedCheckpointEvents(finalizedC chan FinalizedCheckpointEventData) {
 	for _, instance := range c.beaconInstances {
-		go instance.SubscribeToHeadEvents(slotC)
+		go instance.SubscribeToFinalizedCheckpointEvents(finalizedC)
 	}
 }
 
@@ -194,7 +211,15 @@ func (c *MultiBeaconClient) beaconInstancesByLastResponse() []IBeaconInstance {
 	return instances
 }
 
+type publishBlockResult struct {
+	code int
+	err  error
+}
+
 // PublishBlock publishes the signed beacon block via https://ethereum.github.io/beacon-APIs/#/ValidatorRequiredApi/publishBlock
+//
+// The block is sent to all CL nodes concurrently. It returns on the first successful publish, or after all nodes
+// responded if PUBLISH_BLOCK_WAIT_FOR_ALL is set.
 func (c *MultiBeaconClient) PublishBlock(block *types.SignedBeaconBlock) (code int, err error) {
 	log := c.log.WithFields(logrus.Fields{
 		"slot":      block.Message.Slot,
@@ -202,17 +227,40 @@ func (c *MultiBeaconClient) PublishBlock(block *types.SignedBeaconBlock) (code i
 	})
 
 	clients := c.beaconInstancesByLastResponse()
+	resultC := make(chan publishBlockResult, len(clients))
 	for _, client := range clients {
-		log := log.WithField("uri", client.GetURI())
-		log.Debug("publishing block")
+		go func(client IBeaconInstance) {
+			log := log.WithField("uri", client.GetURI())
+			log.Debug("publishing block")
+
+			code, err := client.PublishBlock(block)
+			if err != nil {
+				log.WithField("statusCode", code).WithError(err).Warn("failed to publish block")
+			} else {
+				log.WithField("statusCode", code).Info("published block")
+			}
+			resultC <- publishBlockResult{code: code, err: err}
+		}(client)
+	}
 
-		if code, err = client.PublishBlock(block); err != nil {
-			log.WithField("statusCode", code).WithError(err).Warn("failed to publish block")
+	published := false
+	publishedCode := 0
+	for range clients {
+		result := <-resultC
+		if result.err != nil {
+			code, err = result.code, result.err
 			continue
 		}
 
-		log.WithField("statusCode", code).Info("published block")
-		return code, nil
+		if !c.ffPublishBlockWaitForAll {
+			return result.code, nil
+		}
+		published = true
+		publishedCode = result.code
+	}
+
+	if published {
+		return publishedCode, nil
 	}
 
 	log.WithField("statusCode", code).WithError(err).Error("failed to publish block on any CL node")
@@ -1,6 +1,7 @@
 package beaconclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"github.com/flashbots/go-boost-utils/types"
 	"github.com/r3labs/sse/v2"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/cenkalti/backoff.v1"
 )
 
 type ProdBeaconInstance struct {
@@ -32,28 +34,69 @@ type HeadEventData struct {
 	State string `json:"state"`
 }
 
-func (c *ProdBeaconInstance) SubscribeToHeadEvents(slotC chan HeadEventData) {
+// SubscribeToHeadEvents sends the head events to slotC and reconnects on errors, until the context is cancelled
+func (c *ProdBeaconInstance) SubscribeToHeadEvents(ctx context.Context, slotC chan HeadEventData) {
 	eventsURL := fmt.Sprintf("%s/eth/v1/events?topics=head", c.beaconURI)
 	log := c.log.WithField("url", eventsURL)
 	log.Info("subscribing to head events")
 
-	for {
+	for ctx.Err() == nil {
 		client := sse.NewClient(eventsURL)
-		err := client.SubscribeRaw(func(msg *sse.Event) {
+		client.ReconnectStrategy = backoff.WithContext(backoff.NewExponentialBackOff(), ctx)
+		err := client.SubscribeRawWithContext(ctx, func(msg *sse.Event) {
 			var data HeadEventData
 			err := json.Unmarshal(msg.Data, &data)
 			if err != nil {
 				log.WithError(err).Error("could not unmarshal head event")
-			} else {
-				slotC <- data
+				return
+			}
+			select {
+			case slotC <- data:
+			case <-ctx.Done():
 			}
 		})
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
 			log.WithError(err).Error("failed to subscribe to head events")
 			time.Sleep(1 * time.Second)
 		}
 		c.log.Warn("beaconclient SubscribeRaw ended, reconnecting")
 	}
+	log.Info("unsubscribed from head events")
+}
+
+// FinalizedCheckpointEventData represents the data of a finalized_checkpoint event
+// {"block":"0x9a2fefd2fdb57f74993c7780ea5b9030d2897b615b89f808011ca5aebed54eaf","state":"0x600e852a08c1200654ddf11025f1ceacb3c2e74bdd5c630cde0838b2591b69f9","epoch":"2","execution_optimistic":false}
+type FinalizedCheckpointEventData struct {
+	Block string `json:"block"`
+	State string `json:"state"`
+	Epoch uint64 `json:"epoch,string"`
+}
+
+func (c *ProdBeaconInstance) SubscribeToFinalizedCheckpointEvents(finalizedC chan FinalizedCheckpointEventData) {
+	eventsURL := fmt.Sprintf("%s/eth/v1/events?topics=finalized_checkpoint", c.beaconURI)
+	log := c.log.WithField("url", eventsURL)
+	log.Info("subscribing to finalized_checkpoint events")
+
+	for {
+		client := sse.NewClient(eventsURL)
+		err := client.SubscribeRaw(func(msg *sse.Event) {
+			var data FinalizedCheckpointEventData
+			err := json.Unmarshal(msg.Data, &data)
+			if err != nil {
+				log.WithError(err).Error("could not unmarshal finalized_checkpoint event")
+			} else {
+				finalizedC <- data
+			}
+		})
+		if err != nil {
+			log.WithError(err).Error("failed to subscribe to finalized_checkpoint events")
+			time.Sleep(1 * time.Second)
+		}
+		c.log.Warn("beaconclient SubscribeRaw ended, reconnecting")
+	}
 }
 
 func (c *ProdBeaconInstance) FetchValidators(headSlot uint64) (map[types.PubkeyHex]ValidatorResponseEntry, error) {
@@ -189,7 +232,10 @@ type GetBlockResponse struct {
 func (c *ProdBeaconInstance) GetBlock(blockID string) (block *GetBlockResponse, err error) {
 	uri := fmt.Sprintf("%s/eth/v2/beacon/blocks/%s", c.beaconURI, blockID)
 	resp := new(GetBlockResponse)
-	_, err = fetchBeacon(http.MethodGet, uri, nil, resp)
+	code, err := fetchBeacon(http.MethodGet, uri, nil, resp)
+	if code == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrBlockNotFound, err.Error())
+	}
 	return resp, err
 }
 
@@ -9,7 +9,10 @@ import (
 	"net/http"
 )
 
-var ErrHTTPErrorResponse = errors.New("got an HTTP error response")
+var (
+	ErrHTTPErrorResponse = errors.New("got an HTTP error response")
+	ErrBlockNotFound     = errors.New("block not found")
+)
 
 func fetchBeacon(method, url string, payload, dst any) (code int, err error) {
 	var req *http.Request
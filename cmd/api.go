@@ -9,6 +9,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/flashbots/go-boost-utils/bls"
+	"github.com/flashbots/go-utils/cli"
 	"github.com/flashbots/mev-boost-relay/beaconclient"
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
@@ -47,6 +48,7 @@ func init() {
 	apiCmd.Flags().StringSliceVar(&beaconNodeURIs, "beacon-uris", defaultBeaconURIs, "beacon endpoints")
 	apiCmd.Flags().StringVar(&redisURI, "redis-uri", defaultRedisURI, "redis uri")
 	apiCmd.Flags().StringVar(&postgresDSN, "db", defaultPostgresDSN, "PostgreSQL DSN")
+	apiCmd.Flags().StringVar(&postgresReadReplicaDSN, "db-read-replica", defaultPostgresReadReplicaDSN, "PostgreSQL DSN of a read replica for the data API, uses the primary if empty")
 	apiCmd.Flags().StringVar(&apiSecretKey, "secret-key", apiDefaultSecretKey, "secret key for signing bids")
 	apiCmd.Flags().StringVar(&apiBlockSimURL, "blocksim", apiDefaultBlockSim, "URL for block simulator")
 	apiCmd.Flags().StringVar(&network, "network", defaultNetwork, "Which network to use")
@@ -79,6 +81,11 @@ var apiCmd = &cobra.Command{
 			log.WithError(err).Fatalf("error getting network details")
 		}
 		log.Infof("Using network: %s", networkInfo.Name)
+		if capellaForkEpoch := cli.GetEnvInt("CAPELLA_FORK_EPOCH", -1); capellaForkEpoch >= 0 {
+			// Override the fork schedule of the network, e.g. for devnets
+			networkInfo.CapellaForkEpoch = uint64(capellaForkEpoch)
+			log.Infof("Using Capella fork epoch: %d", capellaForkEpoch)
+		}
 
 		// Connect to beacon clients and ensure it's synced
 		if len(beaconNodeURIs) == 0 {
@@ -104,10 +111,18 @@ var apiCmd = &cobra.Command{
 			log.WithError(err).Fatalf("couldn't read db URL")
 		}
 		log.Infof("Connecting to Postgres database at %s%s ...", dbURL.Host, dbURL.Path)
-		db, err := database.NewDatabaseService(postgresDSN)
+		if postgresReadReplicaDSN != "" {
+			replicaURL, err := url.Parse(postgresReadReplicaDSN)
+			if err != nil {
+				log.WithError(err).Fatalf("couldn't read db read replica URL")
+			}
+			log.Infof("Using Postgres read replica at %s%s for the data API", replicaURL.Host, replicaURL.Path)
+		}
+		db, err := database.NewDatabaseService(postgresDSN, postgresReadReplicaDSN)
 		if err != nil {
 			log.WithError(err).Fatalf("Failed to connect to Postgres database at %s%s", dbURL.Host, dbURL.Path)
 		}
+		db.SlotsPerEpoch = networkInfo.SlotsInEpoch()
 
 		log.Info("Setting up datastore...")
 		ds, err := datastore.NewDatastore(log, redis, db)
@@ -130,6 +145,12 @@ var apiCmd = &cobra.Command{
 			DataAPI:         true,
 			InternalAPI:     apiInternalAPI,
 			PprofAPI:        apiPprofEnabled,
+
+			BuildInfo: api.BuildInfo{
+				Version:   Version,
+				Commit:    Commit,
+				BuildTime: BuildTime,
+			},
 		}
 
 		// Decode the private key
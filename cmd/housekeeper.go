@@ -62,16 +62,18 @@ var housekeeperCmd = &cobra.Command{
 			log.WithError(err).Fatalf("couldn't read db URL")
 		}
 		log.Infof("Connecting to Postgres database at %s%s ...", dbURL.Host, dbURL.Path)
-		db, err := database.NewDatabaseService(postgresDSN)
+		db, err := database.NewDatabaseService(postgresDSN, "")
 		if err != nil {
 			log.WithError(err).Fatalf("Failed to connect to Postgres database at %s%s", dbURL.Host, dbURL.Path)
 		}
+		db.SlotsPerEpoch = networkInfo.SlotsInEpoch()
 
 		opts := &housekeeper.HousekeeperOpts{
-			Log:          log,
-			Redis:        redis,
-			DB:           db,
-			BeaconClient: beaconClient,
+			Log:           log,
+			Redis:         redis,
+			DB:            db,
+			BeaconClient:  beaconClient,
+			EthNetDetails: *networkInfo,
 		}
 		service := housekeeper.NewHousekeeper(opts)
 		log.Info("Starting housekeeper service...")
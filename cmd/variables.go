@@ -7,16 +7,18 @@ import (
 )
 
 var (
-	defaultNetwork     = common.GetEnv("NETWORK", "")
-	defaultBeaconURIs  = common.GetSliceEnv("BEACON_URIS", []string{"http://localhost:3500"})
-	defaultRedisURI    = common.GetEnv("REDIS_URI", "localhost:6379")
-	defaultPostgresDSN = common.GetEnv("POSTGRES_DSN", "")
-	defaultLogJSON     = os.Getenv("LOG_JSON") != ""
-	defaultLogLevel    = common.GetEnv("LOG_LEVEL", "info")
+	defaultNetwork                = common.GetEnv("NETWORK", "")
+	defaultBeaconURIs             = common.GetSliceEnv("BEACON_URIS", []string{"http://localhost:3500"})
+	defaultRedisURI               = common.GetEnv("REDIS_URI", "localhost:6379")
+	defaultPostgresDSN            = common.GetEnv("POSTGRES_DSN", "")
+	defaultPostgresReadReplicaDSN = common.GetEnv("POSTGRES_READ_REPLICA_DSN", "")
+	defaultLogJSON                = os.Getenv("LOG_JSON") != ""
+	defaultLogLevel               = common.GetEnv("LOG_LEVEL", "info")
 
-	beaconNodeURIs []string
-	redisURI       string
-	postgresDSN    string
+	beaconNodeURIs         []string
+	redisURI               string
+	postgresDSN            string
+	postgresReadReplicaDSN string
 
 	logJSON  bool
 	logLevel string
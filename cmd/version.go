@@ -6,7 +6,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var Version = "dev" // is set during build process
+// set during build process
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
@@ -17,6 +22,6 @@ var versionCmd = &cobra.Command{
 	Short: "Print the version number the relay application",
 	Long:  `All software has versions. This is the boost relay's`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("boost-relay %s\n", Version)
+		fmt.Printf("boost-relay %s (commit: %s, built: %s)\n", Version, Commit, BuildTime)
 	},
 }
@@ -31,20 +31,26 @@ type BuilderStatus struct {
 }
 
 type Profile struct {
-	Unzip       uint64
-	ReadHeader  uint64
-	Read        uint64
-	Decode      uint64
-	CacheRead   uint64
-	RandaoLock1 uint64
-	DutiesLock  uint64
-	Checks      uint64
-	RandaoLock2 uint64
-	Simulation  uint64
-	RedisUpdate uint64
-	Submission  uint64
+	Unzip       uint64 `json:"unzip"`
+	ReadHeader  uint64 `json:"read_header"`
+	Read        uint64 `json:"read"`
+	Decode      uint64 `json:"decode"`
+	CacheRead   uint64 `json:"cache_read"`
+	RandaoLock1 uint64 `json:"randao_lock_1"`
+	DutiesLock  uint64 `json:"duties_lock"`
+	Checks      uint64 `json:"checks"`
+	RandaoLock2 uint64 `json:"randao_lock_2"`
+	Simulation  uint64 `json:"simulation"`
+	RedisUpdate uint64 `json:"redis_update"`
+	Submission  uint64 `json:"submission"`
+
+	// Time waiting for the simulation rate limiter, not included in Simulation
+	SimulationQueue uint64 `json:"simulation_queue"`
+
+	// Content-Encoding of the request, decompression time is included in the durations of reading the request
+	Encoding string `json:"encoding,omitempty"`
 }
 
 func (p *Profile) String() string {
-	return fmt.Sprintf("%v,%v,%v,%v,%v,%v,%v,%v,%v,%v,%v,%v", p.Unzip, p.ReadHeader, p.Read, p.Decode, p.CacheRead, p.RandaoLock1, p.DutiesLock, p.Checks, p.RandaoLock2, p.Simulation, p.RedisUpdate, p.Submission)
+	return fmt.Sprintf("%v,%v,%v,%v,%v,%v,%v,%v,%v,%v,%v,%v,%v", p.Unzip, p.ReadHeader, p.Read, p.Decode, p.CacheRead, p.RandaoLock1, p.DutiesLock, p.Checks, p.RandaoLock2, p.Simulation, p.RedisUpdate, p.Submission, p.SimulationQueue)
 }
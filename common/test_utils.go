@@ -68,6 +68,9 @@ func TestBuilderSubmitBlockRequest(pk *types.PublicKey, sk *blst.SecretKey, bid
 		Signature: signature,
 		ExecutionPayload: &types.ExecutionPayload{
 			BlockHash:    bid.BlockHash,
+			ParentHash:   bid.ParentHash,
+			GasLimit:     bid.GasLimit,
+			GasUsed:      bid.GasUsed,
 			Timestamp:    bid.Slot * 12, // 12 seconds per slot.
 			Transactions: []hexutil.Bytes{_HexToBytes("0x03")},
 			Random:       _HexToHash("01234567890123456789012345678901"),
@@ -4,13 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/flashbots/go-boost-utils/types"
 )
 
-var ErrUnknownNetwork = errors.New("unknown network")
+var (
+	ErrUnknownNetwork         = errors.New("unknown network")
+	ErrInvalidChainParameters = errors.New("invalid chain parameters")
+)
 
 // BuilderEntry represents a builder that is allowed to send blocks
 // Address will be schema://hostname:port
@@ -51,6 +57,15 @@ type EthNetworkDetails struct {
 	GenesisValidatorsRootHex string
 	BellatrixForkVersionHex  string
 
+	// Epoch of the Capella fork, 0 if it isn't scheduled
+	CapellaForkEpoch uint64
+
+	// Chain parameters, overridden with SLOTS_PER_EPOCH and SECONDS_PER_SLOT for custom networks. If 0, the
+	// mainnet values of SlotsPerEpoch and DurationPerSlot are used.
+	SlotsPerEpoch  uint64
+	SecondsPerSlot uint64
+
+	// Signing domains are computed once in NewEthNetworkDetails and reused for every signature check
 	DomainBuilder        types.Domain
 	DomainBeaconProposer types.Domain
 }
@@ -67,12 +82,22 @@ var (
 	GenesisForkVersionZhejiang    = "0x00000069"
 	GenesisValidatorsRootZhejiang = "0x53a92d8f2bb1d85f62d16a156e6ebcd1bcaba652d0900b2c2f387826f3481f6f"
 	BellatrixForkVersionZhejiang  = "0x00000071"
+
+	// Capella fork epochs
+	CapellaForkEpochMainnet  = uint64(194048)
+	CapellaForkEpochGoerli   = uint64(162304)
+	CapellaForkEpochSepolia  = uint64(56832)
+	CapellaForkEpochZhejiang = uint64(1350)
+
+	ForkBellatrix = "bellatrix"
+	ForkCapella   = "capella"
 )
 
 func NewEthNetworkDetails(networkName string) (ret *EthNetworkDetails, err error) {
 	var genesisForkVersion string
 	var genesisValidatorsRoot string
 	var bellatrixForkVersion string
+	var capellaForkEpoch uint64
 	var domainBuilder types.Domain
 	var domainBeaconProposer types.Domain
 
@@ -89,18 +114,22 @@ func NewEthNetworkDetails(networkName string) (ret *EthNetworkDetails, err error
 		genesisForkVersion = types.GenesisForkVersionSepolia
 		genesisValidatorsRoot = types.GenesisValidatorsRootSepolia
 		bellatrixForkVersion = types.BellatrixForkVersionSepolia
+		capellaForkEpoch = CapellaForkEpochSepolia
 	case EthNetworkGoerli:
 		genesisForkVersion = types.GenesisForkVersionGoerli
 		genesisValidatorsRoot = types.GenesisValidatorsRootGoerli
 		bellatrixForkVersion = types.BellatrixForkVersionGoerli
+		capellaForkEpoch = CapellaForkEpochGoerli
 	case EthNetworkMainnet:
 		genesisForkVersion = types.GenesisForkVersionMainnet
 		genesisValidatorsRoot = types.GenesisValidatorsRootMainnet
 		bellatrixForkVersion = types.BellatrixForkVersionMainnet
+		capellaForkEpoch = CapellaForkEpochMainnet
 	case EthNetworkZhejiang:
 		genesisForkVersion = GenesisForkVersionZhejiang
 		genesisValidatorsRoot = GenesisValidatorsRootZhejiang
 		bellatrixForkVersion = BellatrixForkVersionZhejiang
+		capellaForkEpoch = CapellaForkEpochZhejiang
 	default:
 		return nil, fmt.Errorf("%w: %s", ErrUnknownNetwork, networkName)
 	}
@@ -115,16 +144,74 @@ func NewEthNetworkDetails(networkName string) (ret *EthNetworkDetails, err error
 		return nil, err
 	}
 
+	slotsPerEpoch, err := getChainParameterEnv("SLOTS_PER_EPOCH", uint64(SlotsPerEpoch))
+	if err != nil {
+		return nil, err
+	}
+	secondsPerSlot, err := getChainParameterEnv("SECONDS_PER_SLOT", uint64(DurationPerSlot.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+
 	return &EthNetworkDetails{
 		Name:                     networkName,
 		GenesisForkVersionHex:    genesisForkVersion,
 		GenesisValidatorsRootHex: genesisValidatorsRoot,
 		BellatrixForkVersionHex:  bellatrixForkVersion,
+		CapellaForkEpoch:         capellaForkEpoch,
+		SlotsPerEpoch:            slotsPerEpoch,
+		SecondsPerSlot:           secondsPerSlot,
 		DomainBuilder:            domainBuilder,
 		DomainBeaconProposer:     domainBeaconProposer,
 	}, nil
 }
 
+func getChainParameterEnv(key string, defaultValue uint64) (uint64, error) {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.ParseUint(valueStr, 10, 64)
+	if err != nil || value == 0 {
+		return 0, fmt.Errorf("%w: %s=%s", ErrInvalidChainParameters, key, valueStr)
+	}
+	return value, nil
+}
+
+// SlotsInEpoch returns the number of slots per epoch of the network
+func (e *EthNetworkDetails) SlotsInEpoch() uint64 {
+	if e.SlotsPerEpoch == 0 {
+		return uint64(SlotsPerEpoch)
+	}
+	return e.SlotsPerEpoch
+}
+
+// SlotDuration returns the duration of a slot of the network
+func (e *EthNetworkDetails) SlotDuration() time.Duration {
+	if e.SecondsPerSlot == 0 {
+		return DurationPerSlot
+	}
+	return time.Duration(e.SecondsPerSlot) * time.Second
+}
+
+// EpochDuration returns the duration of an epoch of the network
+func (e *EthNetworkDetails) EpochDuration() time.Duration {
+	return e.SlotDuration() * time.Duration(e.SlotsInEpoch())
+}
+
+// SlotTimestamp returns the unix timestamp of the start of a slot, which is also the timestamp of its execution payload
+func (e *EthNetworkDetails) SlotTimestamp(genesisTime, slot uint64) uint64 {
+	return genesisTime + slot*uint64(e.SlotDuration().Seconds())
+}
+
+// ForkAtSlot returns the fork active at a slot, which determines the version of its execution payload
+func (e *EthNetworkDetails) ForkAtSlot(slot uint64) string {
+	if e.CapellaForkEpoch > 0 && slot >= e.CapellaForkEpoch*e.SlotsInEpoch() {
+		return ForkCapella
+	}
+	return ForkBellatrix
+}
+
 type BidTraceV2 struct {
 	types.BidTrace
 	BlockNumber uint64 `json:"block_number,string" db:"block_number"`
@@ -143,6 +230,9 @@ type BidTraceV2JSON struct {
 	Value                string `json:"value"`
 	NumTx                uint64 `json:"num_tx,string"`
 	BlockNumber          uint64 `json:"block_number,string"`
+
+	// FillRatio is gas_used / gas_limit, derived when the response is built
+	FillRatio float64 `json:"fill_ratio,string"`
 }
 
 func (b *BidTraceV2JSON) CSVHeader() []string {
@@ -181,6 +271,10 @@ type BidTraceV2WithTimestampJSON struct {
 	BidTraceV2JSON
 	Timestamp   int64 `json:"timestamp,string,omitempty"`
 	TimestampMs int64 `json:"timestamp_ms,string,omitempty"`
+
+	// ReceivedAtMs and EligibleAtMs are when the relay received the submission and when it became eligible as a bid
+	ReceivedAtMs int64 `json:"received_at_ms,string,omitempty"`
+	EligibleAtMs int64 `json:"eligible_at_ms,string,omitempty"`
 }
 
 func (b *BidTraceV2WithTimestampJSON) CSVHeader() []string {
@@ -198,6 +292,8 @@ func (b *BidTraceV2WithTimestampJSON) CSVHeader() []string {
 		"block_number",
 		"timestamp",
 		"timestamp_ms",
+		"received_at_ms",
+		"eligible_at_ms",
 	}
 }
 
@@ -216,5 +312,30 @@ func (b *BidTraceV2WithTimestampJSON) ToCSVRecord() []string {
 		fmt.Sprint(b.BlockNumber),
 		fmt.Sprint(b.Timestamp),
 		fmt.Sprint(b.TimestampMs),
+		fmt.Sprint(b.ReceivedAtMs),
+		fmt.Sprint(b.EligibleAtMs),
 	}
 }
+
+// SubmitBlockResponse is the status code and body the relay responded with to a block submission
+type SubmitBlockResponse struct {
+	Code int    `json:"code"`
+	Body string `json:"body"`
+}
+
+type FeeRecipientChangeJSON struct {
+	Pubkey          string `json:"pubkey"`
+	OldFeeRecipient string `json:"old_fee_recipient"`
+	NewFeeRecipient string `json:"new_fee_recipient"`
+	Timestamp       uint64 `json:"timestamp,string"`
+}
+
+type RejectedSubmissionJSON struct {
+	Slot          uint64 `json:"slot,string"`
+	BuilderPubkey string `json:"builder_pubkey"`
+	BlockHash     string `json:"block_hash"`
+	Value         string `json:"value"`
+	Reason        string `json:"reason"`
+	TimestampMs   int64  `json:"timestamp_ms,string"`
+	RemoteAddr    string `json:"remote_addr"`
+}
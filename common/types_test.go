@@ -0,0 +1,108 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flashbots/go-boost-utils/bls"
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/stretchr/testify/require"
+)
+
+func signedTestBidTrace(t require.TestingT, domain types.Domain) (*types.BidTrace, types.Signature) {
+	sk, pk, err := bls.GenerateNewKeypair()
+	require.NoError(t, err)
+	pubkey, err := types.BlsPublicKeyToPublicKey(pk)
+	require.NoError(t, err)
+
+	bidTrace := &types.BidTrace{
+		Slot:          1,
+		BuilderPubkey: pubkey,
+		Value:         types.IntToU256(1),
+	}
+	sig, err := types.SignMessage(bidTrace, domain, sk)
+	require.NoError(t, err)
+	return bidTrace, sig
+}
+
+func TestDomainBuilderVerification(t *testing.T) {
+	details, err := NewEthNetworkDetails(EthNetworkMainnet)
+	require.NoError(t, err)
+
+	// Signed with a freshly computed domain, verified with the one cached at startup
+	domain, err := ComputeDomain(types.DomainTypeAppBuilder, types.GenesisForkVersionMainnet, types.Root{}.String())
+	require.NoError(t, err)
+	require.Equal(t, domain, details.DomainBuilder)
+
+	bidTrace, sig := signedTestBidTrace(t, domain)
+	ok, err := types.VerifySignature(bidTrace, details.DomainBuilder, bidTrace.BuilderPubkey[:], sig[:])
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// A different domain must not verify
+	ok, err = types.VerifySignature(bidTrace, details.DomainBeaconProposer, bidTrace.BuilderPubkey[:], sig[:])
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestForkAtSlot(t *testing.T) {
+	details, err := NewEthNetworkDetails(EthNetworkMainnet)
+	require.NoError(t, err)
+	capellaSlot := CapellaForkEpochMainnet * uint64(SlotsPerEpoch)
+	require.Equal(t, ForkBellatrix, details.ForkAtSlot(capellaSlot-1))
+	require.Equal(t, ForkCapella, details.ForkAtSlot(capellaSlot))
+
+	// Without a scheduled fork
+	details.CapellaForkEpoch = 0
+	require.Equal(t, ForkBellatrix, details.ForkAtSlot(capellaSlot))
+}
+
+func TestChainParameters(t *testing.T) {
+	details, err := NewEthNetworkDetails(EthNetworkMainnet)
+	require.NoError(t, err)
+	require.Equal(t, uint64(32), details.SlotsInEpoch())
+	require.Equal(t, 12*time.Second, details.SlotDuration())
+	require.Equal(t, uint64(1_000+10*12), details.SlotTimestamp(1_000, 10))
+
+	// Custom networks
+	t.Setenv("SLOTS_PER_EPOCH", "8")
+	t.Setenv("SECONDS_PER_SLOT", "6")
+	details, err = NewEthNetworkDetails(EthNetworkMainnet)
+	require.NoError(t, err)
+	require.Equal(t, uint64(8), details.SlotsInEpoch())
+	require.Equal(t, 48*time.Second, details.EpochDuration())
+	require.Equal(t, uint64(1_000+10*6), details.SlotTimestamp(1_000, 10))
+	require.Equal(t, ForkCapella, details.ForkAtSlot(CapellaForkEpochMainnet*8))
+
+	t.Setenv("SECONDS_PER_SLOT", "0")
+	_, err = NewEthNetworkDetails(EthNetworkMainnet)
+	require.ErrorIs(t, err, ErrInvalidChainParameters)
+
+	// Zero values fall back to the mainnet parameters
+	details = &EthNetworkDetails{}
+	require.Equal(t, uint64(SlotsPerEpoch), details.SlotsInEpoch())
+	require.Equal(t, DurationPerSlot, details.SlotDuration())
+}
+
+func BenchmarkVerifySignatureCachedDomain(b *testing.B) {
+	details, err := NewEthNetworkDetails(EthNetworkMainnet)
+	require.NoError(b, err)
+	bidTrace, sig := signedTestBidTrace(b, details.DomainBuilder)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = types.VerifySignature(bidTrace, details.DomainBuilder, bidTrace.BuilderPubkey[:], sig[:])
+	}
+}
+
+func BenchmarkVerifySignatureComputedDomain(b *testing.B) {
+	details, err := NewEthNetworkDetails(EthNetworkMainnet)
+	require.NoError(b, err)
+	bidTrace, sig := signedTestBidTrace(b, details.DomainBuilder)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		domain, _ := ComputeDomain(types.DomainTypeAppBuilder, types.GenesisForkVersionMainnet, types.Root{}.String())
+		_, _ = types.VerifySignature(bidTrace, domain, bidTrace.BuilderPubkey[:], sig[:])
+	}
+}
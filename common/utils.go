@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"strings"
@@ -105,3 +106,11 @@ func GetMevBoostVersionFromUserAgent(ua string) string {
 	}
 	return "-"
 }
+
+// GasFillRatio returns gas_used / gas_limit of a block rounded to 4 decimals, or 0 without a gas limit
+func GasFillRatio(gasUsed, gasLimit uint64) float64 {
+	if gasLimit == 0 {
+		return 0
+	}
+	return math.Round(float64(gasUsed)/float64(gasLimit)*10_000) / 10_000
+}
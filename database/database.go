@@ -3,7 +3,9 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -23,12 +25,21 @@ type IDatabaseService interface {
 	SaveValidatorRegistration(entry ValidatorRegistrationEntry) error
 	GetLatestValidatorRegistrations(timestampOnly bool) ([]*ValidatorRegistrationEntry, error)
 	GetValidatorRegistration(pubkey string) (*ValidatorRegistrationEntry, error)
+	GetValidatorRegistrationHistory(pubkey string) ([]*ValidatorRegistrationEntry, error)
 	GetValidatorRegistrationsForPubkeys(pubkeys []string) ([]*ValidatorRegistrationEntry, error)
+	InsertFeeRecipientChange(entry *FeeRecipientChangeEntry) error
+	GetFeeRecipientChanges(pubkey string, limit uint64) ([]*FeeRecipientChangeEntry, error)
 
-	SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError error, receivedAt, eligibleAt time.Time, profile common.Profile, optimisticSubmission, payloadParsed bool) (entry *BuilderBlockSubmissionEntry, err error)
+	SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError, redisError error, receivedAt, eligibleAt time.Time, profile common.Profile, optimisticSubmission, payloadParsed bool, remoteAddr string, parentTimestamp, parentGasLimit uint64) (entry *BuilderBlockSubmissionEntry, err error)
 	GetBlockSubmissionEntry(slot uint64, proposerPubkey, blockHash string) (entry *BuilderBlockSubmissionEntry, err error)
+	GetBestBlockSubmissionEntry(slot uint64, parentHash, proposerPubkey string) (entry *BuilderBlockSubmissionEntry, err error)
 	GetBuilderSubmissions(filters GetBuilderSubmissionsFilters) ([]*BuilderBlockSubmissionEntry, error)
 	GetBuilderSubmissionsBySlots(slotFrom, slotTo uint64) (entries []*BuilderBlockSubmissionEntry, err error)
+	GetRecentRejectedSubmissions(limit uint64) (entries []*BuilderBlockSubmissionEntry, err error)
+	GetSubmissionDurationStats(slotFrom, slotTo uint64) (*SubmissionDurationStatsEntry, error)
+	GetBuilderStats(builderPubkey string, slotFrom, slotTo uint64) ([]*BuilderStatsEntry, error)
+	SaveRawBlockSubmission(entry *RawBlockSubmissionEntry) error
+	GetRawBlockSubmission(slot uint64, builderPubkey, blockHash string) (*RawBlockSubmissionEntry, error)
 	GetExecutionPayloadEntryByID(executionPayloadID int64) (entry *ExecutionPayloadEntry, err error)
 	GetExecutionPayloadEntryBySlotPkHash(slot uint64, proposerPubkey, blockHash string) (entry *ExecutionPayloadEntry, err error)
 	GetExecutionPayloads(idFirst, idLast uint64) (entries []*ExecutionPayloadEntry, err error)
@@ -38,36 +49,50 @@ type IDatabaseService interface {
 	GetNumDeliveredPayloads() (uint64, error)
 	GetRecentDeliveredPayloads(filters GetPayloadsFilters) ([]*DeliveredPayloadEntry, error)
 	GetDeliveredPayloads(idFirst, idLast uint64) (entries []*DeliveredPayloadEntry, err error)
+	SetDeliveredPayloadReorged(slot uint64, blockHash string) error
+	GetDeliveredPayloadBySlot(slot uint64) (*DeliveredPayloadEntry, error)
 
 	GetBlockBuilders() ([]*BlockBuilderEntry, error)
+	GetBlockBuildersWithFilters(filters GetBlockBuildersFilters) ([]*BlockBuilderEntry, error)
 	GetBlockBuilderByPubkey(pubkey string) (*BlockBuilderEntry, error)
 	SetBlockBuilderStatus(pubkey string, status common.BuilderStatus) error
+	SetBlockBuilderHighPrio(pubkey string, isHighPrio bool) error
 	SetBlockBuilderCollateral(pubkey, collateralID, collateralValue string) error
 	UpsertBlockBuilderEntryAfterSubmission(lastSubmission *BuilderBlockSubmissionEntry, isError bool) error
 	IncBlockBuilderStatsAfterGetPayload(builderPubkey string) error
 
-	InsertBuilderDemotion(submitBlockRequest *types.BuilderSubmitBlockRequest, simError error) error
+	InsertBuilderDemotion(submitBlockRequest *types.BuilderSubmitBlockRequest, simError error, simErrorResponse string) error
 	UpdateBuilderDemotion(trace *types.BidTrace, signedBlock *types.SignedBeaconBlock, signedRegistration *types.SignedValidatorRegistration) error
 	GetBuilderDemotion(trace *types.BidTrace) (*BuilderDemotionEntry, error)
+	GetBuilderDemotions(filters GetBuilderDemotionsFilters) ([]*BuilderDemotionEntry, error)
+
+	SaveMetricsSnapshot(entry *MetricsSnapshotEntry) error
 }
 
 type DatabaseService struct {
 	DB *sqlx.DB
 
+	// readDB serves the read-heavy data API queries, a read replica if configured and DB otherwise
+	readDB *sqlx.DB
+
+	// SlotsPerEpoch of the network, used for the epoch columns. If 0, the mainnet value is used.
+	SlotsPerEpoch uint64
+
+	// Only store the submission profile in the JSON column, not in the individual duration columns
+	ffDisableProfileColumns bool
+
 	nstmtInsertExecutionPayload       *sqlx.NamedStmt
 	nstmtInsertBlockBuilderSubmission *sqlx.NamedStmt
 }
 
-func NewDatabaseService(dsn string) (*DatabaseService, error) {
-	db, err := sqlx.Connect("postgres", dsn)
+// NewDatabaseService connects to the database at dsn and applies the migrations. With a readReplicaDSN, the data API
+// queries go to the read replica instead.
+func NewDatabaseService(dsn, readReplicaDSN string) (*DatabaseService, error) {
+	db, err := connectDB(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	db.DB.SetMaxOpenConns(50)
-	db.DB.SetMaxIdleConns(10)
-	db.DB.SetConnMaxIdleTime(0)
-
 	if os.Getenv("DB_DONT_APPLY_SCHEMA") == "" {
 		migrate.SetTable(vars.TableMigrations)
 		_, err := migrate.Exec(db.DB, "postgres", migrations.Migrations, migrate.Up)
@@ -76,11 +101,38 @@ func NewDatabaseService(dsn string) (*DatabaseService, error) {
 		}
 	}
 
-	dbService := &DatabaseService{DB: db} //nolint:exhaustruct
+	dbService := &DatabaseService{DB: db, readDB: db} //nolint:exhaustruct
+	if readReplicaDSN != "" {
+		dbService.readDB, err = connectDB(readReplicaDSN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dbService.ffDisableProfileColumns = os.Getenv("DB_DISABLE_PROFILE_COLUMNS") == "1"
 	err = dbService.prepareNamedQueries()
 	return dbService, err
 }
 
+// epoch returns the epoch of a slot
+func (s *DatabaseService) epoch(slot uint64) uint64 {
+	if s.SlotsPerEpoch == 0 {
+		return slot / uint64(common.SlotsPerEpoch)
+	}
+	return slot / s.SlotsPerEpoch
+}
+
+func connectDB(dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.DB.SetMaxOpenConns(50)
+	db.DB.SetMaxIdleConns(10)
+	db.DB.SetConnMaxIdleTime(0)
+	return db, nil
+}
+
 func (s *DatabaseService) prepareNamedQueries() (err error) {
 	// Insert execution payload
 	query := `INSERT INTO ` + vars.TableExecutionPayload + `
@@ -95,14 +147,19 @@ func (s *DatabaseService) prepareNamedQueries() (err error) {
 
 	// Insert block builder submission
 	query = `INSERT INTO ` + vars.TableBuilderBlockSubmission + `
-	(received_at, eligible_at, execution_payload_id, sim_success, sim_error, signature, slot, parent_hash, block_hash, builder_pubkey, proposer_pubkey, proposer_fee_recipient, gas_used, gas_limit, num_tx, value, epoch, block_number, unzip_duration, read_header_duration, read_duration, decode_duration, cache_read_duration, randao_lock_1_duration, duties_lock_duration, checks_duration, randao_lock_2_duration, simulation_duration, redis_update_duration, submission_duration, optimistic_submission, payload_parsed) VALUES
-	(:received_at, :eligible_at, :execution_payload_id, :sim_success, :sim_error, :signature, :slot, :parent_hash, :block_hash, :builder_pubkey, :proposer_pubkey, :proposer_fee_recipient, :gas_used, :gas_limit, :num_tx, :value, :epoch, :block_number, :unzip_duration, :read_header_duration, :read_duration, :decode_duration, :cache_read_duration, :randao_lock_1_duration, :duties_lock_duration, :checks_duration, :randao_lock_2_duration, :simulation_duration, :redis_update_duration, :submission_duration, :optimistic_submission, :payload_parsed)
+	(received_at, eligible_at, execution_payload_id, sim_success, sim_error, signature, slot, parent_hash, block_hash, builder_pubkey, proposer_pubkey, proposer_fee_recipient, gas_used, gas_limit, num_tx, value, epoch, block_number, unzip_duration, read_header_duration, read_duration, decode_duration, cache_read_duration, randao_lock_1_duration, duties_lock_duration, checks_duration, randao_lock_2_duration, simulation_queue_duration, simulation_duration, redis_update_duration, submission_duration, optimistic_submission, payload_parsed, profile, remote_addr, parent_timestamp, parent_gas_limit, redis_error, sim_error_detail) VALUES
+	(:received_at, :eligible_at, :execution_payload_id, :sim_success, :sim_error, :signature, :slot, :parent_hash, :block_hash, :builder_pubkey, :proposer_pubkey, :proposer_fee_recipient, :gas_used, :gas_limit, :num_tx, :value, :epoch, :block_number, :unzip_duration, :read_header_duration, :read_duration, :decode_duration, :cache_read_duration, :randao_lock_1_duration, :duties_lock_duration, :checks_duration, :randao_lock_2_duration, :simulation_queue_duration, :simulation_duration, :redis_update_duration, :submission_duration, :optimistic_submission, :payload_parsed, :profile, :remote_addr, :parent_timestamp, :parent_gas_limit, :redis_error, :sim_error_detail)
 	RETURNING id`
 	s.nstmtInsertBlockBuilderSubmission, err = s.DB.PrepareNamed(query)
 	return err
 }
 
 func (s *DatabaseService) Close() error {
+	if s.readDB != s.DB {
+		if err := s.readDB.Close(); err != nil {
+			return err
+		}
+	}
 	return s.DB.Close()
 }
 
@@ -144,6 +201,38 @@ func (s *DatabaseService) GetValidatorRegistration(pubkey string) (*ValidatorReg
 	return entry, err
 }
 
+// GetValidatorRegistrationHistory returns all stored registrations of a validator, oldest first. Registrations are only
+// stored if the fee recipient or gas limit changed.
+func (s *DatabaseService) GetValidatorRegistrationHistory(pubkey string) ([]*ValidatorRegistrationEntry, error) {
+	query := `SELECT pubkey, fee_recipient, timestamp, gas_limit, signature
+		FROM ` + vars.TableValidatorRegistration + `
+		WHERE pubkey=$1
+		ORDER BY timestamp ASC;`
+	entries := []*ValidatorRegistrationEntry{}
+	err := s.readDB.Select(&entries, query, pubkey)
+	return entries, err
+}
+
+func (s *DatabaseService) InsertFeeRecipientChange(entry *FeeRecipientChangeEntry) error {
+	query := `INSERT INTO ` + vars.TableFeeRecipientChanges + `
+		(pubkey, old_fee_recipient, new_fee_recipient, timestamp) VALUES
+		(:pubkey, :old_fee_recipient, :new_fee_recipient, :timestamp);`
+	_, err := s.DB.NamedExec(query, entry)
+	return err
+}
+
+// GetFeeRecipientChanges returns the most recent fee recipient changes, newest first, optionally only of a single validator
+func (s *DatabaseService) GetFeeRecipientChanges(pubkey string, limit uint64) ([]*FeeRecipientChangeEntry, error) {
+	query := `SELECT id, inserted_at, pubkey, old_fee_recipient, new_fee_recipient, timestamp
+		FROM ` + vars.TableFeeRecipientChanges + `
+		WHERE $1 = '' OR pubkey = $1
+		ORDER BY id DESC
+		LIMIT $2;`
+	entries := []*FeeRecipientChangeEntry{}
+	err := s.readDB.Select(&entries, query, pubkey, limit)
+	return entries, err
+}
+
 func (s *DatabaseService) GetValidatorRegistrationsForPubkeys(pubkeys []string) (entries []*ValidatorRegistrationEntry, err error) {
 	query := `SELECT DISTINCT ON (pubkey) pubkey, fee_recipient, timestamp, gas_limit, signature
 		FROM ` + vars.TableValidatorRegistration + `
@@ -171,7 +260,7 @@ func (s *DatabaseService) GetLatestValidatorRegistrations(timestampOnly bool) ([
 	return registrations, err
 }
 
-func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError error, receivedAt, eligibleAt time.Time, profile common.Profile, optimisticSubmission, payloadParsed bool) (entry *BuilderBlockSubmissionEntry, err error) {
+func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError, redisError error, receivedAt, eligibleAt time.Time, profile common.Profile, optimisticSubmission, payloadParsed bool, remoteAddr string, parentTimestamp, parentGasLimit uint64) (entry *BuilderBlockSubmissionEntry, err error) {
 	// Save execution_payload: insert, or if already exists update to be able to return the id ('on conflict do nothing' doesn't return an id)
 	execPayloadEntry, err := PayloadToExecPayloadEntry(payload)
 	if err != nil {
@@ -189,6 +278,22 @@ func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmi
 		simErrStr = simError.Error()
 	}
 
+	simErrDetail := sql.NullString{}
+	var simErrWithDetail SimErrorDetailer
+	if errors.As(simError, &simErrWithDetail) {
+		simErrDetail = NewNullString(simErrWithDetail.SimErrorDetail())
+	}
+
+	redisErrStr := ""
+	if redisError != nil {
+		redisErrStr = redisError.Error()
+	}
+
+	_profile, err := json.Marshal(profile)
+	if err != nil {
+		return nil, err
+	}
+
 	blockSubmissionEntry := &BuilderBlockSubmissionEntry{
 		ReceivedAt:         NewNullTime(receivedAt),
 		EligibleAt:         NewNullTime(eligibleAt),
@@ -196,6 +301,9 @@ func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmi
 
 		SimSuccess: simError == nil,
 		SimError:   simErrStr,
+		RedisError: redisErrStr,
+
+		SimErrorDetail: simErrDetail,
 
 		Signature: payload.Signature.String(),
 
@@ -213,7 +321,7 @@ func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmi
 		NumTx: uint64(len(payload.ExecutionPayload.Transactions)),
 		Value: payload.Message.Value.String(),
 
-		Epoch:       payload.Message.Slot / uint64(common.SlotsPerEpoch),
+		Epoch:       s.epoch(payload.Message.Slot),
 		BlockNumber: payload.ExecutionPayload.BlockNumber,
 
 		UnzipDuration:       profile.Unzip,
@@ -226,18 +334,42 @@ func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmi
 		ChecksDuration:      profile.Checks,
 		RandaoLock2Duration: profile.RandaoLock2,
 
-		SimulationDuration:   profile.Simulation,
-		RedisUpdateDuration:  profile.RedisUpdate,
-		SubmissionDuration:   profile.Submission,
-		OptimisticSubmission: optimisticSubmission,
-		PayloadParsed:        payloadParsed,
+		SimulationQueueDuration: profile.SimulationQueue,
+		SimulationDuration:      profile.Simulation,
+		RedisUpdateDuration:     profile.RedisUpdate,
+		SubmissionDuration:      profile.Submission,
+		OptimisticSubmission:    optimisticSubmission,
+		PayloadParsed:           payloadParsed,
+
+		Profile:    NewNullString(string(_profile)),
+		RemoteAddr: remoteAddr,
+
+		ParentTimestamp: parentTimestamp,
+		ParentGasLimit:  parentGasLimit,
+	}
+
+	if s.ffDisableProfileColumns {
+		blockSubmissionEntry.UnzipDuration = 0
+		blockSubmissionEntry.ReadHeaderDuration = 0
+		blockSubmissionEntry.ReadDuration = 0
+		blockSubmissionEntry.DecodeDuration = 0
+		blockSubmissionEntry.CacheReadDuration = 0
+		blockSubmissionEntry.RandaoLock1Duration = 0
+		blockSubmissionEntry.DutiesLockDuration = 0
+		blockSubmissionEntry.ChecksDuration = 0
+		blockSubmissionEntry.RandaoLock2Duration = 0
+		blockSubmissionEntry.SimulationQueueDuration = 0
+		blockSubmissionEntry.SimulationDuration = 0
+		blockSubmissionEntry.RedisUpdateDuration = 0
+		blockSubmissionEntry.SubmissionDuration = 0
 	}
+
 	err = s.nstmtInsertBlockBuilderSubmission.QueryRow(blockSubmissionEntry).Scan(&blockSubmissionEntry.ID)
 	return blockSubmissionEntry, err
 }
 
 func (s *DatabaseService) GetBlockSubmissionEntry(slot uint64, proposerPubkey, blockHash string) (entry *BuilderBlockSubmissionEntry, err error) {
-	query := `SELECT id, inserted_at, received_at, eligible_at, execution_payload_id, sim_success, sim_error, signature, slot, parent_hash, block_hash, builder_pubkey, proposer_pubkey, proposer_fee_recipient, gas_used, gas_limit, num_tx, value, epoch, block_number, unzip_duration, read_header_duration, read_duration, decode_duration, cache_read_duration, randao_lock_1_duration, duties_lock_duration, checks_duration, randao_lock_2_duration, simulation_duration, redis_update_duration, submission_duration, optimistic_submission, payload_parsed
+	query := `SELECT id, inserted_at, received_at, eligible_at, execution_payload_id, sim_success, sim_error, signature, slot, parent_hash, block_hash, builder_pubkey, proposer_pubkey, proposer_fee_recipient, gas_used, gas_limit, num_tx, value, epoch, block_number, unzip_duration, read_header_duration, read_duration, decode_duration, cache_read_duration, randao_lock_1_duration, duties_lock_duration, checks_duration, randao_lock_2_duration, simulation_queue_duration, simulation_duration, redis_update_duration, submission_duration, optimistic_submission, payload_parsed, profile, remote_addr, parent_timestamp, parent_gas_limit, redis_error, sim_error_detail
 	FROM ` + vars.TableBuilderBlockSubmission + `
 	WHERE slot=$1 AND proposer_pubkey=$2 AND block_hash=$3
 	ORDER BY builder_pubkey ASC
@@ -247,6 +379,44 @@ func (s *DatabaseService) GetBlockSubmissionEntry(slot uint64, proposerPubkey, b
 	return entry, err
 }
 
+// SaveRawBlockSubmission saves the request body of a block submission, only the first one per slot, builder and block hash
+func (s *DatabaseService) SaveRawBlockSubmission(entry *RawBlockSubmissionEntry) error {
+	query := `INSERT INTO ` + vars.TableRawBlockSubmission + `
+		(slot, builder_pubkey, block_hash, body) VALUES
+		(:slot, :builder_pubkey, :block_hash, :body)
+		ON CONFLICT (slot, builder_pubkey, block_hash) DO NOTHING;`
+	_, err := s.DB.NamedExec(query, entry)
+	return err
+}
+
+func (s *DatabaseService) GetRawBlockSubmission(slot uint64, builderPubkey, blockHash string) (*RawBlockSubmissionEntry, error) {
+	query := `SELECT id, inserted_at, slot, builder_pubkey, block_hash, body
+		FROM ` + vars.TableRawBlockSubmission + `
+		WHERE slot=$1 AND builder_pubkey=$2 AND block_hash=$3;`
+	entry := &RawBlockSubmissionEntry{}
+	err := s.DB.Get(entry, query, slot, builderPubkey, blockHash)
+	return entry, err
+}
+
+// GetBestBlockSubmissionEntry returns the highest value of the latest eligible submissions of each builder which isn't
+// demoted, the earliest one on ties. As with the bids in redis, a builder's later submission replaces its earlier ones.
+func (s *DatabaseService) GetBestBlockSubmissionEntry(slot uint64, parentHash, proposerPubkey string) (entry *BuilderBlockSubmissionEntry, err error) {
+	fields := "id, inserted_at, received_at, eligible_at, execution_payload_id, sim_success, sim_error, signature, slot, parent_hash, block_hash, builder_pubkey, proposer_pubkey, proposer_fee_recipient, gas_used, gas_limit, num_tx, value, epoch, block_number"
+	query := `SELECT ` + fields + ` FROM (
+		SELECT DISTINCT ON (builder_pubkey) ` + fields + `
+		FROM ` + vars.TableBuilderBlockSubmission + `
+		WHERE slot=$1 AND parent_hash=$2 AND proposer_pubkey=$3 AND sim_success AND execution_payload_id IS NOT NULL AND eligible_at IS NOT NULL
+		ORDER BY builder_pubkey, received_at DESC
+	) latest
+	WHERE NOT EXISTS (SELECT 1 FROM ` + vars.TableBlockBuilder + ` b WHERE b.builder_pubkey = latest.builder_pubkey AND b.is_demoted)
+	ORDER BY value DESC, received_at ASC
+	LIMIT 1`
+	entry = &BuilderBlockSubmissionEntry{}
+	// the getHeader fallback runs at the slot boundary, a lagging replica could miss the latest submissions
+	err = s.DB.Get(entry, query, slot, parentHash, proposerPubkey)
+	return entry, err
+}
+
 func (s *DatabaseService) GetExecutionPayloadEntryByID(executionPayloadID int64) (entry *ExecutionPayloadEntry, err error) {
 	query := `SELECT id, inserted_at, slot, proposer_pubkey, block_hash, version, payload FROM ` + vars.TableExecutionPayload + ` WHERE id=$1`
 	entry = &ExecutionPayloadEntry{}
@@ -263,6 +433,7 @@ func (s *DatabaseService) GetExecutionPayloadEntryBySlotPkHash(slot uint64, prop
 	return entry, err
 }
 
+// SaveDeliveredPayload is idempotent, retried getPayload calls for the same block don't insert another row
 func (s *DatabaseService) SaveDeliveredPayload(validatedAt time.Time, bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock) error {
 	_signedBlindedBeaconBlock, err := json.Marshal(signedBlindedBeaconBlock)
 	if err != nil {
@@ -274,7 +445,7 @@ func (s *DatabaseService) SaveDeliveredPayload(validatedAt time.Time, bidTrace *
 		SignedBlindedBeaconBlock: NewNullString(string(_signedBlindedBeaconBlock)),
 
 		Slot:  bidTrace.Slot,
-		Epoch: bidTrace.Slot / uint64(common.SlotsPerEpoch),
+		Epoch: s.epoch(bidTrace.Slot),
 
 		BuilderPubkey:        bidTrace.BuilderPubkey.String(),
 		ProposerPubkey:       bidTrace.ProposerPubkey.String(),
@@ -294,7 +465,7 @@ func (s *DatabaseService) SaveDeliveredPayload(validatedAt time.Time, bidTrace *
 	query := `INSERT INTO ` + vars.TableDeliveredPayload + `
 		(validated_at, signed_blinded_beacon_block, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, gas_used, gas_limit, num_tx, value) VALUES
 		(:validated_at, :signed_blinded_beacon_block, :slot, :epoch, :builder_pubkey, :proposer_pubkey, :proposer_fee_recipient, :parent_hash, :block_hash, :block_number, :gas_used, :gas_limit, :num_tx, :value)
-		ON CONFLICT DO NOTHING`
+		ON CONFLICT (slot, proposer_pubkey, block_hash) DO NOTHING`
 	_, err = s.DB.NamedExec(query, deliveredPayloadEntry)
 	return err
 }
@@ -303,6 +474,8 @@ func (s *DatabaseService) GetRecentDeliveredPayloads(queryArgs GetPayloadsFilter
 	arg := map[string]interface{}{
 		"limit":           queryArgs.Limit,
 		"slot":            queryArgs.Slot,
+		"slot_from":       queryArgs.SlotFrom,
+		"slot_to":         queryArgs.SlotTo,
 		"cursor":          queryArgs.Cursor,
 		"block_hash":      queryArgs.BlockHash,
 		"block_number":    queryArgs.BlockNumber,
@@ -310,11 +483,13 @@ func (s *DatabaseService) GetRecentDeliveredPayloads(queryArgs GetPayloadsFilter
 		"builder_pubkey":  queryArgs.BuilderPubkey,
 	}
 
-	fields := "id, inserted_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit"
+	fields := "id, inserted_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit, reorged"
 
 	whereConds := []string{}
 	if queryArgs.Slot > 0 {
 		whereConds = append(whereConds, "slot = :slot")
+	} else if queryArgs.SlotTo > 0 {
+		whereConds = append(whereConds, "slot BETWEEN :slot_from AND :slot_to")
 	} else if queryArgs.Cursor > 0 {
 		whereConds = append(whereConds, "slot <= :cursor")
 	}
@@ -348,7 +523,7 @@ func (s *DatabaseService) GetRecentDeliveredPayloads(queryArgs GetPayloadsFilter
 	defer cancel()
 
 	entries := []*DeliveredPayloadEntry{}
-	rows, err := s.DB.NamedQueryContext(ctx, query, arg)
+	rows, err := s.readDB.NamedQueryContext(ctx, query, arg)
 	if err != nil {
 		return nil, err
 	}
@@ -364,7 +539,7 @@ func (s *DatabaseService) GetRecentDeliveredPayloads(queryArgs GetPayloadsFilter
 }
 
 func (s *DatabaseService) GetDeliveredPayloads(idFirst, idLast uint64) (entries []*DeliveredPayloadEntry, err error) {
-	query := `SELECT id, inserted_at, validated_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit
+	query := `SELECT id, inserted_at, validated_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit, reorged
 	FROM ` + vars.TableDeliveredPayload + `
 	WHERE id >= $1 AND id <= $2
 	ORDER BY slot ASC`
@@ -373,6 +548,26 @@ func (s *DatabaseService) GetDeliveredPayloads(idFirst, idLast uint64) (entries
 	return entries, err
 }
 
+// GetDeliveredPayloadBySlot returns the payload delivered for a slot, preferring one which wasn't reorged if several were
+// delivered, or sql.ErrNoRows if there is none
+func (s *DatabaseService) GetDeliveredPayloadBySlot(slot uint64) (*DeliveredPayloadEntry, error) {
+	query := `SELECT id, inserted_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit, reorged
+	FROM ` + vars.TableDeliveredPayload + `
+	WHERE slot=$1
+	ORDER BY reorged, id
+	LIMIT 1`
+	entry := &DeliveredPayloadEntry{}
+	err := s.readDB.Get(entry, query, slot)
+	return entry, err
+}
+
+// SetDeliveredPayloadReorged marks a delivered payload as not being part of the canonical chain
+func (s *DatabaseService) SetDeliveredPayloadReorged(slot uint64, blockHash string) error {
+	query := `UPDATE ` + vars.TableDeliveredPayload + ` SET reorged=true WHERE slot=$1 AND block_hash=$2;`
+	_, err := s.DB.Exec(query, slot, blockHash)
+	return err
+}
+
 func (s *DatabaseService) GetNumDeliveredPayloads() (uint64, error) {
 	var count uint64
 	err := s.DB.QueryRow("SELECT COUNT(*) FROM " + vars.TableDeliveredPayload).Scan(&count)
@@ -386,6 +581,7 @@ func (s *DatabaseService) GetBuilderSubmissions(filters GetBuilderSubmissionsFil
 		"block_hash":     filters.BlockHash,
 		"block_number":   filters.BlockNumber,
 		"builder_pubkey": filters.BuilderPubkey,
+		"cursor":         filters.Cursor,
 	}
 
 	fields := "id, inserted_at, received_at, eligible_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit"
@@ -409,18 +605,30 @@ func (s *DatabaseService) GetBuilderSubmissions(filters GetBuilderSubmissionsFil
 	if filters.BuilderPubkey != "" {
 		whereConds = append(whereConds, "builder_pubkey = :builder_pubkey")
 	}
+	if filters.Cursor > 0 {
+		// The cursor is the id of the last submission of the previous page, which continues after it in the (slot, id) order
+		whereConds = append(whereConds, "(slot, id) < (SELECT slot, id FROM "+vars.TableBuilderBlockSubmission+" WHERE id = :cursor)")
+	}
 
 	where := ""
 	if len(whereConds) > 0 {
 		where = "WHERE " + strings.Join(whereConds, " AND ")
 	}
 
-	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY slot DESC, inserted_at DESC %s", fields, vars.TableBuilderBlockSubmission, where, limit)
+	// Blocks without a gas limit have a fill ratio of 0, as in common.GasFillRatio
+	orderBy := "slot DESC, id DESC"
+	if filters.OrderByFillRatio == 1 {
+		orderBy = "COALESCE(gas_used::numeric / NULLIF(gas_limit, 0), 0) ASC, " + orderBy
+	} else if filters.OrderByFillRatio == -1 {
+		orderBy = "COALESCE(gas_used::numeric / NULLIF(gas_limit, 0), 0) DESC, " + orderBy
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s %s", fields, vars.TableBuilderBlockSubmission, where, orderBy, limit)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	entries := []*BuilderBlockSubmissionEntry{}
-	rows, err := s.DB.NamedQueryContext(ctx, query, arg)
+	rows, err := s.readDB.NamedQueryContext(ctx, query, arg)
 	if err != nil {
 		return nil, err
 	}
@@ -445,6 +653,87 @@ func (s *DatabaseService) GetBuilderSubmissionsBySlots(slotFrom, slotTo uint64)
 	return entries, err
 }
 
+// GetRecentRejectedSubmissions returns the most recent submissions which failed simulation, newest first
+func (s *DatabaseService) GetRecentRejectedSubmissions(limit uint64) (entries []*BuilderBlockSubmissionEntry, err error) {
+	query := `SELECT id, inserted_at, received_at, eligible_at, sim_success, sim_error, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit, remote_addr
+	FROM ` + vars.TableBuilderBlockSubmission + `
+	WHERE sim_success = false
+	ORDER BY id DESC
+	LIMIT $1`
+
+	err = s.DB.Select(&entries, query, limit)
+	return entries, err
+}
+
+// GetSubmissionDurationStats returns the p50/p90/p99 profile durations of all submissions in the slot range (inclusive).
+// Submissions saved with DB_DISABLE_PROFILE_COLUMNS have zero durations and are included as such.
+func (s *DatabaseService) GetSubmissionDurationStats(slotFrom, slotTo uint64) (*SubmissionDurationStatsEntry, error) {
+	columns := []string{"count(*) AS num_submissions"}
+	for _, field := range []string{"decode", "simulation", "redis_update", "submission"} {
+		for _, p := range []int{50, 90, 99} {
+			columns = append(columns, fmt.Sprintf("COALESCE(percentile_cont(%.2f) WITHIN GROUP (ORDER BY %s_duration), 0) AS %s_p%d", float64(p)/100, field, field, p))
+		}
+	}
+
+	query := `SELECT ` + strings.Join(columns, ", ") + `
+	FROM ` + vars.TableBuilderBlockSubmission + `
+	WHERE slot >= $1 AND slot <= $2`
+
+	entry := new(SubmissionDurationStatsEntry)
+	err := s.DB.Get(entry, query, slotFrom, slotTo)
+	return entry, err
+}
+
+// GetBuilderStats returns the submission and delivery counts and values per builder in the slot range (inclusive),
+// of all builders with submissions if builderPubkey is empty, ordered by the number of submissions.
+func (s *DatabaseService) GetBuilderStats(builderPubkey string, slotFrom, slotTo uint64) ([]*BuilderStatsEntry, error) {
+	arg := map[string]interface{}{
+		"builder_pubkey": builderPubkey,
+		"slot_from":      slotFrom,
+		"slot_to":        slotTo,
+	}
+
+	whereConds := "slot >= :slot_from AND slot <= :slot_to"
+	if builderPubkey != "" {
+		whereConds += " AND builder_pubkey = :builder_pubkey"
+	}
+
+	query := `SELECT s.builder_pubkey, s.num_submissions, s.num_sim_errors, s.avg_value, s.max_value,
+		COALESCE(d.num_delivered, 0) AS num_delivered, COALESCE(d.value_delivered, 0) AS value_delivered
+	FROM (
+		SELECT builder_pubkey, count(*) AS num_submissions, count(*) FILTER (WHERE NOT sim_success) AS num_sim_errors,
+			COALESCE(round(avg(value)), 0) AS avg_value, COALESCE(max(value), 0) AS max_value
+		FROM ` + vars.TableBuilderBlockSubmission + `
+		WHERE ` + whereConds + `
+		GROUP BY builder_pubkey
+	) s LEFT JOIN (
+		SELECT builder_pubkey, count(*) AS num_delivered, sum(value) AS value_delivered
+		FROM ` + vars.TableDeliveredPayload + `
+		WHERE ` + whereConds + `
+		GROUP BY builder_pubkey
+	) d ON s.builder_pubkey = d.builder_pubkey
+	ORDER BY s.num_submissions DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries := []*BuilderStatsEntry{}
+	rows, err := s.readDB.NamedQueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry := new(BuilderStatsEntry)
+		err = rows.StructScan(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
 func (s *DatabaseService) UpsertBlockBuilderEntryAfterSubmission(lastSubmission *BuilderBlockSubmissionEntry, isError bool) error {
 	entry := BlockBuilderEntry{
 		BuilderPubkey:          lastSubmission.BuilderPubkey,
@@ -472,14 +761,64 @@ func (s *DatabaseService) UpsertBlockBuilderEntryAfterSubmission(lastSubmission
 }
 
 func (s *DatabaseService) GetBlockBuilders() ([]*BlockBuilderEntry, error) {
-	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, is_demoted, collateral_value, collateral_id, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload FROM ` + vars.TableBlockBuilder + ` ORDER BY id ASC;`
+	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, is_demoted, demoted_at, collateral_value, collateral_id, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload FROM ` + vars.TableBlockBuilder + ` ORDER BY id ASC;`
 	entries := []*BlockBuilderEntry{}
 	err := s.DB.Select(&entries, query)
 	return entries, err
 }
 
+func (s *DatabaseService) GetBlockBuildersWithFilters(filters GetBlockBuildersFilters) ([]*BlockBuilderEntry, error) {
+	arg := map[string]interface{}{
+		"limit":  filters.Limit,
+		"offset": filters.Offset,
+	}
+
+	fields := "id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, is_demoted, demoted_at, collateral_value, collateral_id, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload"
+
+	whereConds := []string{}
+	if filters.IsHighPrio {
+		whereConds = append(whereConds, "is_high_prio = true")
+	}
+	if filters.IsBlacklisted {
+		whereConds = append(whereConds, "is_blacklisted = true")
+	}
+	if filters.IsDemoted {
+		whereConds = append(whereConds, "is_demoted = true")
+	}
+
+	where := ""
+	if len(whereConds) > 0 {
+		where = "WHERE " + strings.Join(whereConds, " AND ")
+	}
+
+	limit := ""
+	if filters.Limit > 0 {
+		limit = "LIMIT :limit"
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY id ASC %s OFFSET :offset", fields, vars.TableBlockBuilder, where, limit)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries := []*BlockBuilderEntry{}
+	rows, err := s.DB.NamedQueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry := new(BlockBuilderEntry)
+		err = rows.StructScan(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
 func (s *DatabaseService) GetBlockBuilderByPubkey(pubkey string) (*BlockBuilderEntry, error) {
-	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, is_demoted, collateral_value, collateral_id, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload FROM ` + vars.TableBlockBuilder + ` WHERE builder_pubkey=$1;`
+	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, is_demoted, demoted_at, collateral_value, collateral_id, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload FROM ` + vars.TableBlockBuilder + ` WHERE builder_pubkey=$1;`
 	entry := &BlockBuilderEntry{}
 	err := s.DB.Get(entry, query, pubkey)
 	return entry, err
@@ -491,7 +830,9 @@ func (s *DatabaseService) SetBlockBuilderStatus(pubkey string, status common.Bui
 		return fmt.Errorf("unable to read block builder: %v, %v", pubkey, err)
 	}
 	var query string
-	queryPrefix := `UPDATE ` + vars.TableBlockBuilder + ` SET is_high_prio=$1, is_blacklisted=$2, is_demoted=$3 `
+	// demoted_at is kept while the builder stays demoted, and cleared when the demotion is lifted
+	queryPrefix := `UPDATE ` + vars.TableBlockBuilder + ` SET is_high_prio=$1, is_blacklisted=$2, is_demoted=$3,
+		demoted_at=CASE WHEN NOT $3 THEN NULL WHEN is_demoted THEN demoted_at ELSE now() END `
 	// If no collateral ID is present, just update the status of the single builder pubkey.
 	if builder.CollateralID == "" {
 		query = queryPrefix + "WHERE builder_pubkey=$4;"
@@ -504,6 +845,13 @@ func (s *DatabaseService) SetBlockBuilderStatus(pubkey string, status common.Bui
 	return err
 }
 
+// SetBlockBuilderHighPrio only changes the priority of a single builder pubkey, keeping its other status fields
+func (s *DatabaseService) SetBlockBuilderHighPrio(pubkey string, isHighPrio bool) error {
+	query := `UPDATE ` + vars.TableBlockBuilder + ` SET is_high_prio=$1 WHERE builder_pubkey=$2;`
+	_, err := s.DB.Exec(query, isHighPrio, pubkey)
+	return err
+}
+
 func (s *DatabaseService) SetBlockBuilderCollateral(pubkey, collateralID, collateralValue string) error {
 	query := `UPDATE ` + vars.TableBlockBuilder + ` SET collateral_id=$1, collateral_value=$2 WHERE builder_pubkey=$3;`
 	_, err := s.DB.Exec(query, collateralID, collateralValue, pubkey)
@@ -530,7 +878,7 @@ func (s *DatabaseService) DeleteExecutionPayloads(idFirst, idLast uint64) error
 	return err
 }
 
-func (s *DatabaseService) InsertBuilderDemotion(submitBlockRequest *types.BuilderSubmitBlockRequest, simError error) error {
+func (s *DatabaseService) InsertBuilderDemotion(submitBlockRequest *types.BuilderSubmitBlockRequest, simError error, simErrorResponse string) error {
 	_submitBlockRequest, err := json.Marshal(submitBlockRequest)
 	if err != nil {
 		return err
@@ -539,7 +887,7 @@ func (s *DatabaseService) InsertBuilderDemotion(submitBlockRequest *types.Builde
 	builderDemotionEntry := BuilderDemotionEntry{
 		SubmitBlockRequest: NewNullString(string(_submitBlockRequest)),
 
-		Epoch: bidTrace.Slot / uint64(common.SlotsPerEpoch),
+		Epoch: s.epoch(bidTrace.Slot),
 		Slot:  bidTrace.Slot,
 
 		BuilderPubkey:  bidTrace.BuilderPubkey.String(),
@@ -548,13 +896,14 @@ func (s *DatabaseService) InsertBuilderDemotion(submitBlockRequest *types.Builde
 		Value:        bidTrace.Value.String(),
 		FeeRecipient: bidTrace.ProposerFeeRecipient.String(),
 
-		BlockHash:           bidTrace.BlockHash.String(),
-		SubmitBlockSimError: simError.Error(),
+		BlockHash:                   bidTrace.BlockHash.String(),
+		SubmitBlockSimError:         simError.Error(),
+		SubmitBlockSimErrorResponse: simErrorResponse,
 	}
 
 	query := `INSERT INTO ` + vars.TableBuilderDemotions + `
-		(submit_block_request, epoch, slot, builder_pubkey, proposer_pubkey, value, fee_recipient, block_hash, submit_block_sim_error) VALUES
-		(:submit_block_request, :epoch, :slot, :builder_pubkey, :proposer_pubkey, :value, :fee_recipient, :block_hash, :submit_block_sim_error);
+		(submit_block_request, epoch, slot, builder_pubkey, proposer_pubkey, value, fee_recipient, block_hash, submit_block_sim_error, submit_block_sim_error_response) VALUES
+		(:submit_block_request, :epoch, :slot, :builder_pubkey, :proposer_pubkey, :value, :fee_recipient, :block_hash, :submit_block_sim_error, :submit_block_sim_error_response);
 	`
 	_, err = s.DB.NamedExec(query, builderDemotionEntry)
 	return err
@@ -580,7 +929,7 @@ func (s *DatabaseService) UpdateBuilderDemotion(trace *types.BidTrace, signedBlo
 }
 
 func (s *DatabaseService) GetBuilderDemotion(trace *types.BidTrace) (*BuilderDemotionEntry, error) {
-	query := `SELECT submit_block_request, signed_beacon_block, signed_validator_registration, epoch, slot, builder_pubkey, proposer_pubkey, value, fee_recipient, block_hash, submit_block_sim_error FROM ` + vars.TableBuilderDemotions + `
+	query := `SELECT submit_block_request, signed_beacon_block, signed_validator_registration, epoch, slot, builder_pubkey, proposer_pubkey, value, fee_recipient, block_hash, submit_block_sim_error, submit_block_sim_error_response FROM ` + vars.TableBuilderDemotions + `
 	WHERE slot=$1 AND builder_pubkey=$2 AND block_hash=$3`
 	entry := &BuilderDemotionEntry{}
 	err := s.DB.Get(entry, query, trace.Slot, trace.BuilderPubkey.String(), trace.BlockHash.String())
@@ -589,3 +938,63 @@ func (s *DatabaseService) GetBuilderDemotion(trace *types.BidTrace) (*BuilderDem
 	}
 	return entry, nil
 }
+
+// GetBuilderDemotions returns the most recently inserted demotions. The refund justification columns are only
+// checked for presence (empty string if set, NULL otherwise) to avoid loading full blocks.
+func (s *DatabaseService) GetBuilderDemotions(filters GetBuilderDemotionsFilters) ([]*BuilderDemotionEntry, error) {
+	arg := map[string]interface{}{
+		"limit":          filters.Limit,
+		"slot_from":      filters.SlotFrom,
+		"slot_to":        filters.SlotTo,
+		"builder_pubkey": filters.BuilderPubkey,
+	}
+
+	fields := `id, inserted_at, epoch, slot, builder_pubkey, proposer_pubkey, value, fee_recipient, block_hash, submit_block_sim_error, submit_block_sim_error_response,
+		CASE WHEN signed_beacon_block IS NULL THEN NULL ELSE '' END AS signed_beacon_block,
+		CASE WHEN signed_validator_registration IS NULL THEN NULL ELSE '' END AS signed_validator_registration`
+
+	whereConds := []string{}
+	if filters.SlotFrom > 0 {
+		whereConds = append(whereConds, "slot >= :slot_from")
+	}
+	if filters.SlotTo > 0 {
+		whereConds = append(whereConds, "slot <= :slot_to")
+	}
+	if filters.BuilderPubkey != "" {
+		whereConds = append(whereConds, "builder_pubkey = :builder_pubkey")
+	}
+
+	where := ""
+	if len(whereConds) > 0 {
+		where = "WHERE " + strings.Join(whereConds, " AND ")
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY inserted_at DESC LIMIT :limit", fields, vars.TableBuilderDemotions, where)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries := []*BuilderDemotionEntry{}
+	rows, err := s.DB.NamedQueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry := new(BuilderDemotionEntry)
+		err = rows.StructScan(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *DatabaseService) SaveMetricsSnapshot(entry *MetricsSnapshotEntry) error {
+	query := `INSERT INTO ` + vars.TableMetricsSnapshot + `
+		(num_submissions, num_payloads_delivered, num_builder_demotions, num_active_validators_dropped, num_validator_regs_dropped) VALUES
+		(:num_submissions, :num_payloads_delivered, :num_builder_demotions, :num_active_validators_dropped, :num_validator_regs_dropped);
+	`
+	_, err := s.DB.NamedExec(query, entry)
+	return err
+}
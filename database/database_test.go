@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -24,6 +25,9 @@ const (
 	randao               = "01234567890123456789012345678901"
 	optimisticSubmission = true
 	payloadParsed        = true
+	remoteAddr           = "10.0.0.1"
+	parentTimestamp      = uint64(1680000000)
+	parentGasLimit       = uint64(30000000)
 )
 
 var (
@@ -45,6 +49,8 @@ var (
 		Simulation:  50,
 		RedisUpdate: 51,
 		Submission:  52,
+
+		SimulationQueue: 53,
 	}
 	receivedAt = time.Now().UTC()
 	eligibleAt = receivedAt.Add(time.Second)
@@ -84,7 +90,7 @@ func insertTestBuilder(t *testing.T, db IDatabaseService) string {
 		ProposerFeeRecipient: feeRecipient,
 		Value:                types.IntToU256(uint64(collateral)),
 	})
-	entry, err := db.SaveBuilderBlockSubmission(&req, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed)
+	entry, err := db.SaveBuilderBlockSubmission(&req, nil, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
 	require.NoError(t, err)
 	err = db.UpsertBlockBuilderEntryAfterSubmission(entry, false)
 	require.NoError(t, err)
@@ -103,7 +109,7 @@ func resetDatabase(t *testing.T) *DatabaseService {
 	_, err = _db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public;`)
 	require.NoError(t, err)
 
-	db, err := NewDatabaseService(testDBDSN)
+	db, err := NewDatabaseService(testDBDSN, "")
 	require.NoError(t, err)
 	return db
 }
@@ -194,6 +200,57 @@ func TestSaveValidatorRegistration(t *testing.T) {
 	require.Equal(t, uint64(3), cnt)
 }
 
+func TestGetValidatorRegistrationHistory(t *testing.T) {
+	db := resetDatabase(t)
+	pubkey := "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+
+	reg1 := createValidatorRegistration(pubkey)
+	reg2 := createValidatorRegistration(pubkey)
+	reg2.Timestamp = reg1.Timestamp + 1
+	reg2.FeeRecipient = "0xafbb8996515293fcd87ca09b5c6ffe5c17f043c6"
+	for _, reg := range []ValidatorRegistrationEntry{reg2, reg1} {
+		err := db.SaveValidatorRegistration(reg)
+		require.NoError(t, err)
+	}
+	err := db.SaveValidatorRegistration(createValidatorRegistration("0xa996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"))
+	require.NoError(t, err)
+
+	// reg1 is older than reg2 and isn't stored, registrations are only stored if they are newer
+	entries, err := db.GetValidatorRegistrationHistory(pubkey)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	require.Equal(t, reg2.FeeRecipient, entries[0].FeeRecipient)
+
+	reg3 := createValidatorRegistration(pubkey)
+	reg3.Timestamp = reg2.Timestamp + 1
+	err = db.SaveValidatorRegistration(reg3)
+	require.NoError(t, err)
+
+	entries, err = db.GetValidatorRegistrationHistory(pubkey)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, reg2.Timestamp, entries[0].Timestamp)
+	require.Equal(t, reg3.Timestamp, entries[1].Timestamp)
+	require.Equal(t, reg3.FeeRecipient, entries[1].FeeRecipient)
+}
+
+func TestReadReplica(t *testing.T) {
+	_ = resetDatabase(t)
+
+	// The test database stands in for the replica
+	db, err := NewDatabaseService(testDBDSN, testDBDSN)
+	require.NoError(t, err)
+	require.NotSame(t, db.DB, db.readDB)
+
+	pubkey := "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+	err = db.SaveValidatorRegistration(createValidatorRegistration(pubkey))
+	require.NoError(t, err)
+	entries, err := db.GetValidatorRegistrationHistory(pubkey)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	require.NoError(t, db.Close())
+}
+
 func TestMigrations(t *testing.T) {
 	db := resetDatabase(t)
 	query := `SELECT COUNT(*) FROM ` + vars.TableMigrations + `;`
@@ -254,6 +311,58 @@ func TestSetBlockBuilderStatus(t *testing.T) {
 	require.False(t, builder.IsBlacklisted)
 }
 
+func TestSetBlockBuilderHighPrio(t *testing.T) {
+	db := resetDatabase(t)
+	pubkey1 := insertTestBuilder(t, db)
+	pubkey2 := insertTestBuilder(t, db)
+	for _, pubkey := range []string{pubkey1, pubkey2} {
+		err := db.SetBlockBuilderCollateral(pubkey, collateralID, collateralStr)
+		require.NoError(t, err)
+		err = db.SetBlockBuilderStatus(pubkey, common.BuilderStatus{IsHighPrio: true, IsDemoted: true})
+		require.NoError(t, err)
+	}
+	builder, err := db.GetBlockBuilderByPubkey(pubkey1)
+	require.NoError(t, err)
+	demotedAt := builder.DemotedAt.Time
+
+	// Only the priority of the single builder changes, not the demotion or builders sharing the collateral
+	err = db.SetBlockBuilderHighPrio(pubkey1, false)
+	require.NoError(t, err)
+	builder, err = db.GetBlockBuilderByPubkey(pubkey1)
+	require.NoError(t, err)
+	require.False(t, builder.IsHighPrio)
+	require.True(t, builder.IsDemoted)
+	require.Equal(t, demotedAt, builder.DemotedAt.Time)
+	builder, err = db.GetBlockBuilderByPubkey(pubkey2)
+	require.NoError(t, err)
+	require.True(t, builder.IsHighPrio)
+}
+
+func TestSetBlockBuilderStatusDemotedAt(t *testing.T) {
+	db := resetDatabase(t)
+	pubkey := insertTestBuilder(t, db)
+
+	err := db.SetBlockBuilderStatus(pubkey, common.BuilderStatus{IsDemoted: true})
+	require.NoError(t, err)
+	builder, err := db.GetBlockBuilderByPubkey(pubkey)
+	require.NoError(t, err)
+	require.True(t, builder.DemotedAt.Valid)
+	demotedAt := builder.DemotedAt.Time
+
+	// Staying demoted keeps the time of the demotion
+	err = db.SetBlockBuilderStatus(pubkey, common.BuilderStatus{IsHighPrio: true, IsDemoted: true})
+	require.NoError(t, err)
+	builder, err = db.GetBlockBuilderByPubkey(pubkey)
+	require.NoError(t, err)
+	require.Equal(t, demotedAt, builder.DemotedAt.Time)
+
+	err = db.SetBlockBuilderStatus(pubkey, common.BuilderStatus{IsHighPrio: true})
+	require.NoError(t, err)
+	builder, err = db.GetBlockBuilderByPubkey(pubkey)
+	require.NoError(t, err)
+	require.False(t, builder.DemotedAt.Valid)
+}
+
 func TestSetBlockBuilderCollateral(t *testing.T) {
 	db := resetDatabase(t)
 	pubkey := insertTestBuilder(t, db)
@@ -289,7 +398,8 @@ func TestInsertBuilderDemotion(t *testing.T) {
 	}
 	req := common.TestBuilderSubmitBlockRequest(pk, sk, trace)
 
-	err = db.InsertBuilderDemotion(&req, errFoo)
+	simErrorResponse := `{"jsonrpc":"2.0","id":"1","error":{"code":-32000,"message":"foo"}}`
+	err = db.InsertBuilderDemotion(&req, errFoo, simErrorResponse)
 	require.NoError(t, err)
 
 	entry, err := db.GetBuilderDemotion(trace)
@@ -297,6 +407,7 @@ func TestInsertBuilderDemotion(t *testing.T) {
 	require.Equal(t, slot, entry.Slot)
 	require.Equal(t, pk.String(), entry.BuilderPubkey)
 	require.Equal(t, blockHashStr, entry.BlockHash)
+	require.Equal(t, simErrorResponse, entry.SubmitBlockSimErrorResponse)
 }
 
 func TestUpdateBuilderDemotion(t *testing.T) {
@@ -319,7 +430,7 @@ func TestUpdateBuilderDemotion(t *testing.T) {
 	require.Nil(t, demotion)
 
 	// Insert demotion
-	err = db.InsertBuilderDemotion(&req, errFoo)
+	err = db.InsertBuilderDemotion(&req, errFoo, "")
 	require.NoError(t, err)
 
 	// Now demotion should show up.
@@ -345,6 +456,46 @@ func TestUpdateBuilderDemotion(t *testing.T) {
 	require.NotEmpty(t, demotion.SignedValidatorRegistration.String)
 }
 
+func TestGetBuilderDemotions(t *testing.T) {
+	db := resetDatabase(t)
+	pk, sk := getTestKeyPair(t)
+	for i := uint64(0); i < 3; i++ {
+		req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+			BlockHash:            types.Hash{byte(i)},
+			Slot:                 slot + i,
+			BuilderPubkey:        *pk,
+			ProposerFeeRecipient: feeRecipient,
+			Value:                types.IntToU256(uint64(collateral)),
+		})
+		err := db.InsertBuilderDemotion(&req, errFoo, "")
+		require.NoError(t, err)
+		if i == 0 {
+			err = db.UpdateBuilderDemotion(req.Message, &types.SignedBeaconBlock{}, &types.SignedValidatorRegistration{})
+			require.NoError(t, err)
+		}
+	}
+
+	// Newest first, the refund justification is only reported as present
+	entries, err := db.GetBuilderDemotions(GetBuilderDemotionsFilters{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(entries))
+	require.Equal(t, slot+2, entries[0].Slot)
+	require.False(t, entries[0].SignedBeaconBlock.Valid)
+	require.Equal(t, slot, entries[2].Slot)
+	require.True(t, entries[2].SignedBeaconBlock.Valid)
+	require.True(t, entries[2].SignedValidatorRegistration.Valid)
+	require.Empty(t, entries[2].SignedBeaconBlock.String)
+
+	entries, err = db.GetBuilderDemotions(GetBuilderDemotionsFilters{SlotFrom: slot + 1, SlotTo: slot + 1, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	require.Equal(t, slot+1, entries[0].Slot)
+
+	entries, err = db.GetBuilderDemotions(GetBuilderDemotionsFilters{BuilderPubkey: "0xb1", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(entries))
+}
+
 func TestGetBlockSubmissionEntry(t *testing.T) {
 	db := resetDatabase(t)
 	pubkey := insertTestBuilder(t, db)
@@ -359,6 +510,7 @@ func TestGetBlockSubmissionEntry(t *testing.T) {
 	require.Equal(t, profile.DutiesLock, entry.DutiesLockDuration)
 	require.Equal(t, profile.Checks, entry.ChecksDuration)
 	require.Equal(t, profile.RandaoLock2, entry.RandaoLock2Duration)
+	require.Equal(t, profile.SimulationQueue, entry.SimulationQueueDuration)
 	require.Equal(t, profile.Simulation, entry.SimulationDuration)
 	require.Equal(t, profile.RedisUpdate, entry.RedisUpdateDuration)
 	require.Equal(t, profile.Submission, entry.SubmissionDuration)
@@ -368,4 +520,537 @@ func TestGetBlockSubmissionEntry(t *testing.T) {
 
 	require.True(t, entry.OptimisticSubmission)
 	require.True(t, entry.PayloadParsed)
+	require.Equal(t, remoteAddr, entry.RemoteAddr)
+
+	// The full profile is also stored as JSON.
+	require.True(t, entry.Profile.Valid)
+	entryProfile := common.Profile{}
+	err = json.Unmarshal([]byte(entry.Profile.String), &entryProfile)
+	require.NoError(t, err)
+	require.Equal(t, profile, entryProfile)
+}
+
+func TestGetRecentRejectedSubmissions(t *testing.T) {
+	db := resetDatabase(t)
+	pk, sk := getTestKeyPair(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+		BlockHash:            testBlockHash,
+		Slot:                 slot,
+		BuilderPubkey:        *pk,
+		ProposerPubkey:       *pk,
+		ProposerFeeRecipient: feeRecipient,
+		Value:                types.IntToU256(uint64(collateral)),
+	})
+
+	// A successful submission is not listed.
+	_, err = db.SaveBuilderBlockSubmission(&req, nil, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+	require.NoError(t, err)
+	entries, err := db.GetRecentRejectedSubmissions(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+
+	// Failed submissions are listed, newest first.
+	_, err = db.SaveBuilderBlockSubmission(&req, errFoo, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+	require.NoError(t, err)
+	req.Message.Slot = slot + 1
+	_, err = db.SaveBuilderBlockSubmission(&req, errFoo, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+	require.NoError(t, err)
+
+	entries, err = db.GetRecentRejectedSubmissions(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, slot+1, entries[0].Slot)
+	require.Equal(t, slot, entries[1].Slot)
+	require.Equal(t, pk.String(), entries[0].BuilderPubkey)
+	require.Equal(t, errFoo.Error(), entries[0].SimError)
+	require.Equal(t, remoteAddr, entries[0].RemoteAddr)
+
+	entries, err = db.GetRecentRejectedSubmissions(1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestGetBuilderSubmissionsCursor(t *testing.T) {
+	db := resetDatabase(t)
+	pk, sk := getTestKeyPair(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+		BlockHash:            testBlockHash,
+		Slot:                 slot,
+		BuilderPubkey:        *pk,
+		ProposerPubkey:       *pk,
+		ProposerFeeRecipient: feeRecipient,
+		Value:                types.IntToU256(uint64(collateral)),
+	})
+
+	// The submission for the earlier slot is inserted between the two of the later slot
+	ids := []int64{}
+	for _, submissionSlot := range []uint64{slot + 1, slot, slot + 1} {
+		req.Message.Slot = submissionSlot
+		entry, err := db.SaveBuilderBlockSubmission(&req, nil, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+		require.NoError(t, err)
+		ids = append(ids, entry.ID)
+	}
+
+	filters := GetBuilderSubmissionsFilters{BuilderPubkey: pk.String(), Limit: 2}
+	entries, err := db.GetBuilderSubmissions(filters)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, ids[2], entries[0].ID)
+	require.Equal(t, ids[0], entries[1].ID)
+
+	// The next page continues after the last submission, without skipping the earlier slot
+	filters.Cursor = uint64(entries[1].ID)
+	entries, err = db.GetBuilderSubmissions(filters)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, ids[1], entries[0].ID)
+}
+
+func TestGetBuilderSubmissionsOrderByFillRatio(t *testing.T) {
+	db := resetDatabase(t)
+	pk, sk := getTestKeyPair(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+		BlockHash:            testBlockHash,
+		Slot:                 slot,
+		BuilderPubkey:        *pk,
+		ProposerPubkey:       *pk,
+		ProposerFeeRecipient: feeRecipient,
+		Value:                types.IntToU256(uint64(collateral)),
+	})
+
+	ids := []int64{}
+	for _, gas := range [][2]uint64{{15_000_000, 30_000_000}, {29_000_000, 30_000_000}, {0, 0}} {
+		req.Message.GasUsed, req.Message.GasLimit = gas[0], gas[1]
+		entry, err := db.SaveBuilderBlockSubmission(&req, nil, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+		require.NoError(t, err)
+		ids = append(ids, entry.ID)
+	}
+
+	filters := GetBuilderSubmissionsFilters{BuilderPubkey: pk.String(), Limit: 2, OrderByFillRatio: -1}
+	entries, err := db.GetBuilderSubmissions(filters)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, ids[1], entries[0].ID)
+	require.Equal(t, ids[0], entries[1].ID)
+
+	filters.OrderByFillRatio = 1
+	entries, err = db.GetBuilderSubmissions(filters)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, ids[2], entries[0].ID)
+	require.Equal(t, ids[0], entries[1].ID)
+}
+
+func TestGetBlockBuildersWithFilters(t *testing.T) {
+	db := resetDatabase(t)
+	pubkey1 := insertTestBuilder(t, db)
+	pubkey2 := insertTestBuilder(t, db)
+	pubkey3 := insertTestBuilder(t, db)
+
+	err := db.SetBlockBuilderStatus(pubkey1, common.BuilderStatus{IsHighPrio: true})
+	require.NoError(t, err)
+	err = db.SetBlockBuilderStatus(pubkey2, common.BuilderStatus{IsHighPrio: true, IsDemoted: true})
+	require.NoError(t, err)
+	err = db.SetBlockBuilderStatus(pubkey3, common.BuilderStatus{IsBlacklisted: true})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		filters  GetBlockBuildersFilters
+		expected []string
+	}{
+		{GetBlockBuildersFilters{}, []string{pubkey1, pubkey2, pubkey3}},
+		{GetBlockBuildersFilters{IsHighPrio: true}, []string{pubkey1, pubkey2}},
+		{GetBlockBuildersFilters{IsHighPrio: true, IsDemoted: true}, []string{pubkey2}},
+		{GetBlockBuildersFilters{IsBlacklisted: true}, []string{pubkey3}},
+		{GetBlockBuildersFilters{Limit: 2}, []string{pubkey1, pubkey2}},
+		{GetBlockBuildersFilters{Limit: 2, Offset: 2}, []string{pubkey3}},
+	}
+
+	for _, tc := range testCases {
+		entries, err := db.GetBlockBuildersWithFilters(tc.filters)
+		require.NoError(t, err)
+		builders := []string{}
+		for _, entry := range entries {
+			builders = append(builders, entry.BuilderPubkey)
+		}
+		require.Equal(t, tc.expected, builders)
+	}
+}
+
+func TestSaveMetricsSnapshot(t *testing.T) {
+	db := resetDatabase(t)
+
+	for i := uint64(1); i <= 3; i++ {
+		err := db.SaveMetricsSnapshot(&MetricsSnapshotEntry{
+			NumSubmissions:       i * 10,
+			NumPayloadsDelivered: i,
+		})
+		require.NoError(t, err)
+
+		entries := []*MetricsSnapshotEntry{}
+		err = db.DB.Select(&entries, `SELECT * FROM `+vars.TableMetricsSnapshot+` ORDER BY id`)
+		require.NoError(t, err)
+		require.Len(t, entries, int(i))
+		require.Equal(t, i*10, entries[i-1].NumSubmissions)
+		require.Equal(t, i, entries[i-1].NumPayloadsDelivered)
+	}
+}
+
+func TestSaveBuilderBlockSubmissionParentBlock(t *testing.T) {
+	db := resetDatabase(t)
+	pubkey := insertTestBuilder(t, db)
+
+	entry, err := db.GetBlockSubmissionEntry(slot, pubkey, blockHashStr)
+	require.NoError(t, err)
+	require.Equal(t, parentTimestamp, entry.ParentTimestamp)
+	require.Equal(t, parentGasLimit, entry.ParentGasLimit)
+}
+
+func TestSaveBuilderBlockSubmissionRedisError(t *testing.T) {
+	db := resetDatabase(t)
+	pk, sk := getTestKeyPair(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+		BlockHash:      testBlockHash,
+		Slot:           slot,
+		BuilderPubkey:  *pk,
+		ProposerPubkey: *pk,
+		Value:          types.IntToU256(uint64(collateral)),
+	})
+
+	redisErr := fmt.Errorf("redis: connection refused")
+	_, err = db.SaveBuilderBlockSubmission(&req, nil, redisErr, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+	require.NoError(t, err)
+
+	entry, err := db.GetBlockSubmissionEntry(slot, pk.String(), blockHashStr)
+	require.NoError(t, err)
+	require.True(t, entry.SimSuccess)
+	require.Equal(t, redisErr.Error(), entry.RedisError)
+}
+
+func TestSaveRawBlockSubmission(t *testing.T) {
+	db := resetDatabase(t)
+	entry := &RawBlockSubmissionEntry{
+		Slot:          slot,
+		BuilderPubkey: "0xb1",
+		BlockHash:     blockHashStr,
+		Body:          []byte(`{"message":{}}`),
+	}
+	err := db.SaveRawBlockSubmission(entry)
+	require.NoError(t, err)
+
+	// Only the first body is kept
+	err = db.SaveRawBlockSubmission(&RawBlockSubmissionEntry{Slot: slot, BuilderPubkey: "0xb1", BlockHash: blockHashStr, Body: []byte("{}")})
+	require.NoError(t, err)
+
+	saved, err := db.GetRawBlockSubmission(slot, "0xb1", blockHashStr)
+	require.NoError(t, err)
+	require.Equal(t, entry.Body, saved.Body)
+
+	_, err = db.GetRawBlockSubmission(slot+1, "0xb1", blockHashStr)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestGetBestBlockSubmissionEntry(t *testing.T) {
+	db := resetDatabase(t)
+	pubkey := insertTestBuilder(t, db)
+
+	entry, err := db.GetBestBlockSubmissionEntry(slot, types.Hash{}.String(), pubkey)
+	require.NoError(t, err)
+	require.Equal(t, blockHashStr, entry.BlockHash)
+	require.True(t, entry.ExecutionPayloadID.Valid)
+
+	payloadEntry, err := db.GetExecutionPayloadEntryByID(entry.ExecutionPayloadID.Int64)
+	require.NoError(t, err)
+	require.Equal(t, blockHashStr, payloadEntry.BlockHash)
+
+	_, err = db.GetBestBlockSubmissionEntry(slot+1, types.Hash{}.String(), pubkey)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestGetBestBlockSubmissionEntryLatestPerBuilder(t *testing.T) {
+	db := resetDatabase(t)
+	proposerPubkey, _ := getTestKeyPair(t)
+	pk1, sk1 := getTestKeyPair(t)
+	pk2, sk2 := getTestKeyPair(t)
+
+	submit := func(pk *types.PublicKey, sk *blst.SecretKey, value uint64, receivedAt time.Time) string {
+		req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+			BlockHash:            types.Hash{byte(value)},
+			Slot:                 slot,
+			BuilderPubkey:        *pk,
+			ProposerPubkey:       *proposerPubkey,
+			ProposerFeeRecipient: feeRecipient,
+			Value:                types.IntToU256(value),
+		})
+		entry, err := db.SaveBuilderBlockSubmission(&req, nil, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+		require.NoError(t, err)
+		err = db.UpsertBlockBuilderEntryAfterSubmission(entry, false)
+		require.NoError(t, err)
+		return entry.BlockHash
+	}
+
+	// The first builder lowered its bid, its earlier higher submission isn't served anymore
+	submit(pk1, sk1, 10, receivedAt)
+	blockHash1 := submit(pk1, sk1, 5, receivedAt.Add(time.Second))
+	blockHash2 := submit(pk2, sk2, 7, receivedAt.Add(time.Second))
+
+	entry, err := db.GetBestBlockSubmissionEntry(slot, types.Hash{}.String(), proposerPubkey.String())
+	require.NoError(t, err)
+	require.Equal(t, blockHash2, entry.BlockHash)
+
+	// Bids of demoted builders are skipped
+	err = db.SetBlockBuilderStatus(pk2.String(), common.BuilderStatus{IsDemoted: true})
+	require.NoError(t, err)
+	entry, err = db.GetBestBlockSubmissionEntry(slot, types.Hash{}.String(), proposerPubkey.String())
+	require.NoError(t, err)
+	require.Equal(t, blockHash1, entry.BlockHash)
+}
+
+func TestSaveBuilderBlockSubmissionNotEligible(t *testing.T) {
+	db := resetDatabase(t)
+	pk, sk := getTestKeyPair(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+		BlockHash:      testBlockHash,
+		Slot:           slot,
+		BuilderPubkey:  *pk,
+		ProposerPubkey: *pk,
+		Value:          types.IntToU256(uint64(collateral)),
+	})
+
+	_, err = db.SaveBuilderBlockSubmission(&req, errFoo, nil, receivedAt, time.Time{}, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+	require.NoError(t, err)
+
+	entry, err := db.GetBlockSubmissionEntry(slot, pk.String(), blockHashStr)
+	require.NoError(t, err)
+	require.True(t, entry.ReceivedAt.Valid)
+	require.False(t, entry.EligibleAt.Valid)
+}
+
+type testSimErrorWithDetail struct{}
+
+func (testSimErrorWithDetail) Error() string { return "simulation failed: invalid gas used" }
+
+func (testSimErrorWithDetail) SimErrorDetail() string {
+	return `{"code":-32000,"message":"invalid gas used"}`
+}
+
+func TestSaveBuilderBlockSubmissionSimErrorDetail(t *testing.T) {
+	db := resetDatabase(t)
+	pk, sk := getTestKeyPair(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	req := common.TestBuilderSubmitBlockRequest(pk, sk, &types.BidTrace{
+		BlockHash:      testBlockHash,
+		Slot:           slot,
+		BuilderPubkey:  *pk,
+		ProposerPubkey: *pk,
+		Value:          types.IntToU256(uint64(collateral)),
+	})
+
+	// Errors without detail leave the column empty
+	_, err = db.SaveBuilderBlockSubmission(&req, errFoo, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+	require.NoError(t, err)
+	entry, err := db.GetBlockSubmissionEntry(slot, pk.String(), blockHashStr)
+	require.NoError(t, err)
+	require.False(t, entry.SimErrorDetail.Valid)
+
+	req.Message.ProposerPubkey = types.PublicKey{0x01}
+	simErr := fmt.Errorf("wrapped: %w", testSimErrorWithDetail{})
+	_, err = db.SaveBuilderBlockSubmission(&req, simErr, nil, receivedAt, eligibleAt, profile, optimisticSubmission, payloadParsed, remoteAddr, parentTimestamp, parentGasLimit)
+	require.NoError(t, err)
+	entry, err = db.GetBlockSubmissionEntry(slot, req.Message.ProposerPubkey.String(), blockHashStr)
+	require.NoError(t, err)
+	require.JSONEq(t, testSimErrorWithDetail{}.SimErrorDetail(), entry.SimErrorDetail.String)
+}
+
+func TestGetSubmissionDurationStats(t *testing.T) {
+	db := resetDatabase(t)
+
+	// No submissions in range
+	stats, err := db.GetSubmissionDurationStats(slot+1, slot+10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), stats.NumSubmissions)
+	require.Equal(t, float64(0), stats.SubmissionP99)
+
+	// A single submission, all percentiles equal its durations
+	insertTestBuilder(t, db)
+	stats, err = db.GetSubmissionDurationStats(slot, slot)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), stats.NumSubmissions)
+	require.Equal(t, float64(profile.Decode), stats.DecodeP50)
+	require.Equal(t, float64(profile.Simulation), stats.SimulationP90)
+	require.Equal(t, float64(profile.RedisUpdate), stats.RedisUpdateP99)
+	require.Equal(t, float64(profile.Submission), stats.SubmissionP50)
+}
+
+func TestGetBuilderStats(t *testing.T) {
+	db := resetDatabase(t)
+
+	// No submissions in range
+	entries, err := db.GetBuilderStats("", slot+1, slot+10)
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+
+	// A single submission, which was also delivered
+	builderPubkey := insertTestBuilder(t, db)
+	var testBlockHash types.Hash
+	err = testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	var pk types.PublicKey
+	err = pk.UnmarshalText([]byte(builderPubkey))
+	require.NoError(t, err)
+	bidTrace := &common.BidTraceV2{
+		BidTrace: types.BidTrace{
+			Slot:          slot,
+			BlockHash:     testBlockHash,
+			BuilderPubkey: pk,
+			Value:         types.IntToU256(uint64(collateral)),
+		},
+	}
+	err = db.SaveDeliveredPayload(time.Now(), bidTrace, &types.SignedBlindedBeaconBlock{})
+	require.NoError(t, err)
+
+	entries, err = db.GetBuilderStats(builderPubkey, slot, slot)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, builderPubkey, entries[0].BuilderPubkey)
+	require.Equal(t, uint64(1), entries[0].NumSubmissions)
+	require.Equal(t, uint64(0), entries[0].NumSimErrors)
+	require.Equal(t, collateralStr, entries[0].AvgValue)
+	require.Equal(t, collateralStr, entries[0].MaxValue)
+	require.Equal(t, uint64(1), entries[0].NumDelivered)
+	require.Equal(t, collateralStr, entries[0].ValueDelivered)
+}
+
+func TestGetFeeRecipientChanges(t *testing.T) {
+	db := resetDatabase(t)
+
+	for i, pubkey := range []string{"0xa1", "0xa2", "0xa1"} {
+		err := db.InsertFeeRecipientChange(&FeeRecipientChangeEntry{
+			Pubkey:          pubkey,
+			OldFeeRecipient: "0xf1",
+			NewFeeRecipient: "0xf2",
+			Timestamp:       uint64(i),
+		})
+		require.NoError(t, err)
+	}
+
+	entries, err := db.GetFeeRecipientChanges("", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, uint64(2), entries[0].Timestamp)
+
+	entries, err = db.GetFeeRecipientChanges("0xa1", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "0xf1", entries[0].OldFeeRecipient)
+	require.Equal(t, "0xf2", entries[0].NewFeeRecipient)
+
+	entries, err = db.GetFeeRecipientChanges("", 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestSaveDeliveredPayloadTwice(t *testing.T) {
+	db := resetDatabase(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	bidTrace := &common.BidTraceV2{
+		BidTrace: types.BidTrace{
+			Slot:      slot,
+			BlockHash: testBlockHash,
+			Value:     types.IntToU256(uint64(collateral)),
+		},
+	}
+
+	// A retried getPayload saves the same payload again
+	for i := 0; i < 2; i++ {
+		err = db.SaveDeliveredPayload(time.Now(), bidTrace, &types.SignedBlindedBeaconBlock{})
+		require.NoError(t, err)
+	}
+
+	entries, err := db.GetRecentDeliveredPayloads(GetPayloadsFilters{Slot: slot, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestSetDeliveredPayloadReorged(t *testing.T) {
+	db := resetDatabase(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	bidTrace := &common.BidTraceV2{
+		BidTrace: types.BidTrace{
+			Slot:      slot,
+			BlockHash: testBlockHash,
+			Value:     types.IntToU256(uint64(collateral)),
+		},
+	}
+	err = db.SaveDeliveredPayload(time.Now(), bidTrace, &types.SignedBlindedBeaconBlock{})
+	require.NoError(t, err)
+
+	filters := GetPayloadsFilters{Slot: slot, Limit: 1}
+	entries, err := db.GetRecentDeliveredPayloads(filters)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.False(t, entries[0].Reorged)
+
+	err = db.SetDeliveredPayloadReorged(slot, blockHashStr)
+	require.NoError(t, err)
+	entries, err = db.GetRecentDeliveredPayloads(filters)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.True(t, entries[0].Reorged)
+}
+
+func TestGetDeliveredPayloadBySlot(t *testing.T) {
+	db := resetDatabase(t)
+	var testBlockHash types.Hash
+	err := testBlockHash.UnmarshalText([]byte(blockHashStr))
+	require.NoError(t, err)
+	bidTrace := &common.BidTraceV2{
+		BidTrace: types.BidTrace{
+			Slot:      slot,
+			BlockHash: testBlockHash,
+			Value:     types.IntToU256(uint64(collateral)),
+		},
+	}
+	err = db.SaveDeliveredPayload(time.Now(), bidTrace, &types.SignedBlindedBeaconBlock{})
+	require.NoError(t, err)
+
+	entry, err := db.GetDeliveredPayloadBySlot(slot)
+	require.NoError(t, err)
+	require.Equal(t, blockHashStr, entry.BlockHash)
+	require.Equal(t, bidTrace.Value.String(), entry.Value)
+
+	_, err = db.GetDeliveredPayloadBySlot(slot + 1)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// The first payload was reorged, the one delivered after it is canonical
+	bidTrace.BlockHash = types.Hash{0x01}
+	err = db.SaveDeliveredPayload(time.Now(), bidTrace, &types.SignedBlindedBeaconBlock{})
+	require.NoError(t, err)
+	err = db.SetDeliveredPayloadReorged(slot, blockHashStr)
+	require.NoError(t, err)
+	entry, err = db.GetDeliveredPayloadBySlot(slot)
+	require.NoError(t, err)
+	require.Equal(t, bidTrace.BlockHash.String(), entry.BlockHash)
+	require.False(t, entry.Reorged)
 }
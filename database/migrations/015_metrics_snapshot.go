@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration015MetricsSnapshot = &migrate.Migration{
+	Id: "015-metrics-snapshot",
+	Up: []string{`
+		CREATE TABLE IF NOT EXISTS ` + vars.TableMetricsSnapshot + ` (
+			id          bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			inserted_at timestamp NOT NULL default current_timestamp,
+
+			num_submissions        bigint NOT NULL,
+			num_payloads_delivered bigint NOT NULL,
+			num_builder_demotions  bigint NOT NULL,
+
+			num_active_validators_dropped bigint NOT NULL,
+			num_validator_regs_dropped    bigint NOT NULL
+		);
+	`},
+	Down: []string{`
+		DROP TABLE IF EXISTS ` + vars.TableMetricsSnapshot + `;
+	`},
+
+	DisableTransactionUp:   true,
+	DisableTransactionDown: true,
+}
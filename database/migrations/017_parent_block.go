@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration017ParentBlock = &migrate.Migration{
+	Id: "017-parent-block",
+	Up: []string{`
+		ALTER TABLE ` + vars.TableBuilderBlockSubmission + ` ADD parent_timestamp bigint NOT NULL default 0;
+		ALTER TABLE ` + vars.TableBuilderBlockSubmission + ` ADD parent_gas_limit bigint NOT NULL default 0;
+	`},
+	Down: []string{},
+
+	DisableTransactionUp:   true,
+	DisableTransactionDown: true,
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration019FeeRecipientChanges = &migrate.Migration{
+	Id: "019-fee-recipient-changes",
+	Up: []string{`
+		CREATE TABLE IF NOT EXISTS ` + vars.TableFeeRecipientChanges + ` (
+			id          bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			inserted_at timestamp NOT NULL default current_timestamp,
+
+			pubkey            varchar(98) NOT NULL,
+			old_fee_recipient varchar(42) NOT NULL,
+			new_fee_recipient varchar(42) NOT NULL,
+			timestamp         bigint NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS ` + vars.TableFeeRecipientChanges + `_pubkey_idx ON ` + vars.TableFeeRecipientChanges + `("pubkey");
+	`},
+	Down: []string{`
+		DROP TABLE IF EXISTS ` + vars.TableFeeRecipientChanges + `;
+	`},
+
+	DisableTransactionUp:   true,
+	DisableTransactionDown: true,
+}
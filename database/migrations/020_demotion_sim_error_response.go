@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration020DemotionSimErrorResponse = &migrate.Migration{
+	Id: "020-demotion-sim-error-response",
+	Up: []string{`
+		ALTER TABLE ` + vars.TableBuilderDemotions + ` ADD submit_block_sim_error_response text NOT NULL default '';
+	`},
+	Down: []string{},
+
+	DisableTransactionUp:   true,
+	DisableTransactionDown: true,
+}
@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration021DeliveredPayloadReorged = &migrate.Migration{
+	Id: "021-delivered-payload-reorged",
+	Up: []string{`
+		ALTER TABLE ` + vars.TableDeliveredPayload + ` ADD reorged boolean NOT NULL default false;
+	`},
+	Down: []string{},
+
+	DisableTransactionUp:   true,
+	DisableTransactionDown: true,
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration023RawBlockSubmission = &migrate.Migration{
+	Id: "023-raw-block-submission",
+	Up: []string{`
+		CREATE TABLE IF NOT EXISTS ` + vars.TableRawBlockSubmission + ` (
+			id          bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			inserted_at timestamp NOT NULL default current_timestamp,
+
+			slot           bigint NOT NULL,
+			builder_pubkey varchar(98) NOT NULL,
+			block_hash     varchar(66) NOT NULL,
+			body           bytea NOT NULL,
+
+			UNIQUE (slot, builder_pubkey, block_hash)
+		);
+	`},
+	Down: []string{`
+		DROP TABLE IF EXISTS ` + vars.TableRawBlockSubmission + `;
+	`},
+
+	DisableTransactionUp:   true,
+	DisableTransactionDown: true,
+}
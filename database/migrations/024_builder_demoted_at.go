@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration024BuilderDemotedAt = &migrate.Migration{
+	Id: "024-builder-demoted-at",
+	Up: []string{`
+		ALTER TABLE ` + vars.TableBlockBuilder + ` ADD demoted_at timestamp;
+	`},
+	Down: []string{},
+
+	DisableTransactionUp:   true,
+	DisableTransactionDown: true,
+}
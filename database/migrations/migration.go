@@ -19,5 +19,17 @@ var Migrations = migrate.MemoryMigrationSource{
 		Migration010Read,
 		Migration011BidEligible,
 		Migration012Payload,
+		Migration013ProfileJSON,
+		Migration014SimulationQueue,
+		Migration015MetricsSnapshot,
+		Migration016RemoteAddr,
+		Migration017ParentBlock,
+		Migration018RedisError,
+		Migration019FeeRecipientChanges,
+		Migration020DemotionSimErrorResponse,
+		Migration021DeliveredPayloadReorged,
+		Migration022SimErrorDetail,
+		Migration023RawBlockSubmission,
+		Migration024BuilderDemotedAt,
 	},
 }
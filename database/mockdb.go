@@ -1,7 +1,9 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/flashbots/go-boost-utils/types"
@@ -12,6 +14,19 @@ type MockDB struct {
 	Builders  map[string]*BlockBuilderEntry
 	Demotions map[string]bool
 	Refunds   map[string]bool
+
+	BuilderSubmissions  []*BuilderBlockSubmissionEntry
+	ExecutionPayloads   map[int64]*ExecutionPayloadEntry
+	RawSubmissions      map[string]*RawBlockSubmissionEntry
+	RejectedSubmissions []*BuilderBlockSubmissionEntry
+	DeliveredPayloads   []*DeliveredPayloadEntry
+	BuilderStats        []*BuilderStatsEntry
+	MetricsSnapshots    chan *MetricsSnapshotEntry
+
+	ValidatorRegistrations map[string]*ValidatorRegistrationEntry
+	RegistrationHistory    map[string][]*ValidatorRegistrationEntry
+	FeeRecipientChanges    map[string][]*FeeRecipientChangeEntry
+	DemotionEntries        map[string]*BuilderDemotionEntry
 }
 
 func (db MockDB) NumRegisteredValidators() (count uint64, err error) {
@@ -23,7 +38,32 @@ func (db MockDB) SaveValidatorRegistration(entry ValidatorRegistrationEntry) err
 }
 
 func (db MockDB) GetValidatorRegistration(pubkey string) (*ValidatorRegistrationEntry, error) {
-	return nil, nil
+	return db.ValidatorRegistrations[pubkey], nil
+}
+
+func (db MockDB) GetValidatorRegistrationHistory(pubkey string) ([]*ValidatorRegistrationEntry, error) {
+	entries := []*ValidatorRegistrationEntry{}
+	return append(entries, db.RegistrationHistory[pubkey]...), nil
+}
+
+func (db MockDB) InsertFeeRecipientChange(entry *FeeRecipientChangeEntry) error {
+	if db.FeeRecipientChanges != nil {
+		db.FeeRecipientChanges[entry.Pubkey] = append(db.FeeRecipientChanges[entry.Pubkey], entry)
+	}
+	return nil
+}
+
+func (db MockDB) GetFeeRecipientChanges(pubkey string, limit uint64) ([]*FeeRecipientChangeEntry, error) {
+	entries := []*FeeRecipientChangeEntry{}
+	for _pubkey, changes := range db.FeeRecipientChanges {
+		if pubkey == "" || pubkey == _pubkey {
+			entries = append(entries, changes...)
+		}
+	}
+	if uint64(len(entries)) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
 }
 
 func (db MockDB) GetValidatorRegistrationsForPubkeys(pubkeys []string) (entries []*ValidatorRegistrationEntry, err error) {
@@ -34,12 +74,38 @@ func (db MockDB) GetLatestValidatorRegistrations(timestampOnly bool) ([]*Validat
 	return nil, nil
 }
 
-func (db MockDB) SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError error, receivedAt, eligibleAt time.Time, profile common.Profile, optimisticSubmission, payloadParsed bool) (entry *BuilderBlockSubmissionEntry, err error) {
+func (db MockDB) SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError, redisError error, receivedAt, eligibleAt time.Time, profile common.Profile, optimisticSubmission, payloadParsed bool, remoteAddr string, parentTimestamp, parentGasLimit uint64) (entry *BuilderBlockSubmissionEntry, err error) {
 	return nil, nil
 }
 
+func (db MockDB) GetBestBlockSubmissionEntry(slot uint64, parentHash, proposerPubkey string) (entry *BuilderBlockSubmissionEntry, err error) {
+	for _, e := range db.BuilderSubmissions {
+		if e.Slot == slot && e.ParentHash == parentHash && e.ProposerPubkey == proposerPubkey && e.SimSuccess && e.ExecutionPayloadID.Valid {
+			return e, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (db MockDB) SaveRawBlockSubmission(entry *RawBlockSubmissionEntry) error {
+	if db.RawSubmissions != nil {
+		db.RawSubmissions[fmt.Sprintf("%d/%s/%s", entry.Slot, entry.BuilderPubkey, entry.BlockHash)] = entry
+	}
+	return nil
+}
+
+func (db MockDB) GetRawBlockSubmission(slot uint64, builderPubkey, blockHash string) (*RawBlockSubmissionEntry, error) {
+	if entry, ok := db.RawSubmissions[fmt.Sprintf("%d/%s/%s", slot, builderPubkey, blockHash)]; ok {
+		return entry, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
 func (db MockDB) GetExecutionPayloadEntryByID(executionPayloadID int64) (entry *ExecutionPayloadEntry, err error) {
-	return nil, nil
+	if entry, ok := db.ExecutionPayloads[executionPayloadID]; ok {
+		return entry, nil
+	}
+	return nil, sql.ErrNoRows
 }
 
 func (db MockDB) GetExecutionPayloadEntryBySlotPkHash(slot uint64, proposerPubkey, blockHash string) (entry *ExecutionPayloadEntry, err error) {
@@ -59,25 +125,83 @@ func (db MockDB) GetBlockSubmissionEntry(slot uint64, proposerPubkey, blockHash
 }
 
 func (db MockDB) GetRecentDeliveredPayloads(filters GetPayloadsFilters) ([]*DeliveredPayloadEntry, error) {
-	return nil, nil
+	if uint64(len(db.DeliveredPayloads)) > filters.Limit {
+		return db.DeliveredPayloads[:filters.Limit], nil
+	}
+	return db.DeliveredPayloads, nil
 }
 
 func (db MockDB) GetDeliveredPayloads(idFirst, idLast uint64) (entries []*DeliveredPayloadEntry, err error) {
 	return nil, nil
 }
 
+func (db MockDB) GetDeliveredPayloadBySlot(slot uint64) (*DeliveredPayloadEntry, error) {
+	var reorgedEntry *DeliveredPayloadEntry
+	for _, entry := range db.DeliveredPayloads {
+		if entry.Slot != slot {
+			continue
+		} else if !entry.Reorged {
+			return entry, nil
+		} else if reorgedEntry == nil {
+			reorgedEntry = entry
+		}
+	}
+	if reorgedEntry != nil {
+		return reorgedEntry, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (db MockDB) SetDeliveredPayloadReorged(slot uint64, blockHash string) error {
+	return nil
+}
+
 func (db MockDB) GetNumDeliveredPayloads() (uint64, error) {
 	return 0, nil
 }
 
 func (db MockDB) GetBuilderSubmissions(filters GetBuilderSubmissionsFilters) ([]*BuilderBlockSubmissionEntry, error) {
-	return nil, nil
+	if filters.OrderByFillRatio == 0 {
+		return db.BuilderSubmissions, nil
+	}
+	entries := make([]*BuilderBlockSubmissionEntry, len(db.BuilderSubmissions))
+	copy(entries, db.BuilderSubmissions)
+	sort.SliceStable(entries, func(i, j int) bool {
+		fillRatioI := common.GasFillRatio(entries[i].GasUsed, entries[i].GasLimit)
+		fillRatioJ := common.GasFillRatio(entries[j].GasUsed, entries[j].GasLimit)
+		if filters.OrderByFillRatio == 1 {
+			return fillRatioI < fillRatioJ
+		}
+		return fillRatioI > fillRatioJ
+	})
+	return entries, nil
 }
 
 func (db MockDB) GetBuilderSubmissionsBySlots(slotFrom, slotTo uint64) (entries []*BuilderBlockSubmissionEntry, err error) {
 	return nil, nil
 }
 
+func (db MockDB) GetRecentRejectedSubmissions(limit uint64) (entries []*BuilderBlockSubmissionEntry, err error) {
+	if uint64(len(db.RejectedSubmissions)) > limit {
+		return db.RejectedSubmissions[:limit], nil
+	}
+	return db.RejectedSubmissions, nil
+}
+
+func (db MockDB) GetSubmissionDurationStats(slotFrom, slotTo uint64) (*SubmissionDurationStatsEntry, error) {
+	return &SubmissionDurationStatsEntry{}, nil
+}
+
+func (db MockDB) GetBuilderStats(builderPubkey string, slotFrom, slotTo uint64) ([]*BuilderStatsEntry, error) {
+	entries := []*BuilderStatsEntry{}
+	for _, entry := range db.BuilderStats {
+		if builderPubkey == "" || entry.BuilderPubkey == builderPubkey {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
 func (db MockDB) SaveDeliveredPayload(validatedAt time.Time, bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock) error {
 	return nil
 }
@@ -94,6 +218,25 @@ func (db MockDB) GetBlockBuilders() ([]*BlockBuilderEntry, error) {
 	return res, nil
 }
 
+func (db MockDB) GetBlockBuildersWithFilters(filters GetBlockBuildersFilters) ([]*BlockBuilderEntry, error) {
+	res := []*BlockBuilderEntry{}
+	for _, v := range db.Builders {
+		if (filters.IsHighPrio && !v.IsHighPrio) || (filters.IsBlacklisted && !v.IsBlacklisted) || (filters.IsDemoted && !v.IsDemoted) {
+			continue
+		}
+		res = append(res, v)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].BuilderPubkey < res[j].BuilderPubkey })
+	if filters.Offset >= uint64(len(res)) {
+		return []*BlockBuilderEntry{}, nil
+	}
+	res = res[filters.Offset:]
+	if filters.Limit > 0 && filters.Limit < uint64(len(res)) {
+		res = res[:filters.Limit]
+	}
+	return res, nil
+}
+
 func (db MockDB) GetBlockBuilderByPubkey(pubkey string) (*BlockBuilderEntry, error) {
 	builder, ok := db.Builders[pubkey]
 	if !ok {
@@ -107,24 +250,39 @@ func (db MockDB) SetBlockBuilderStatus(pubkey string, status common.BuilderStatu
 	if !ok {
 		return fmt.Errorf("builder with pubkey %v not in Builders map", pubkey)
 	}
+	setStatus := func(v *BlockBuilderEntry) {
+		if !status.IsDemoted {
+			v.DemotedAt = sql.NullTime{}
+		} else if !v.IsDemoted {
+			v.DemotedAt = NewNullTime(time.Now())
+		}
+		v.IsHighPrio = status.IsHighPrio
+		v.IsBlacklisted = status.IsBlacklisted
+		v.IsDemoted = status.IsDemoted
+	}
 	// Single builder update.
 	if builder.CollateralID == "" {
-		builder.IsHighPrio = status.IsHighPrio
-		builder.IsBlacklisted = status.IsBlacklisted
-		builder.IsDemoted = status.IsDemoted
+		setStatus(builder)
 		return nil
 	}
 	// All matching collateral IDs updated.
 	for _, v := range db.Builders {
 		if v.CollateralID == builder.CollateralID {
-			v.IsHighPrio = status.IsHighPrio
-			v.IsBlacklisted = status.IsBlacklisted
-			v.IsDemoted = status.IsDemoted
+			setStatus(v)
 		}
 	}
 	return nil
 }
 
+func (db MockDB) SetBlockBuilderHighPrio(pubkey string, isHighPrio bool) error {
+	builder, ok := db.Builders[pubkey]
+	if !ok {
+		return fmt.Errorf("builder with pubkey %v not in Builders map", pubkey)
+	}
+	builder.IsHighPrio = isHighPrio
+	return nil
+}
+
 func (db MockDB) SetBlockBuilderCollateral(pubkey, collateralID, collateralValue string) error {
 	builder, ok := db.Builders[pubkey]
 	if !ok {
@@ -143,9 +301,19 @@ func (db MockDB) IncBlockBuilderStatsAfterGetPayload(builderPubkey string) error
 	return nil
 }
 
-func (db MockDB) InsertBuilderDemotion(submitBlockRequest *types.BuilderSubmitBlockRequest, simError error) error {
+func (db MockDB) InsertBuilderDemotion(submitBlockRequest *types.BuilderSubmitBlockRequest, simError error, simErrorResponse string) error {
 	pubkey := submitBlockRequest.Message.BuilderPubkey.String()
 	db.Demotions[pubkey] = true
+	if db.DemotionEntries != nil {
+		db.DemotionEntries[pubkey] = &BuilderDemotionEntry{
+			InsertedAt:                  time.Now().UTC(),
+			Slot:                        submitBlockRequest.Message.Slot,
+			BuilderPubkey:               pubkey,
+			BlockHash:                   submitBlockRequest.Message.BlockHash.String(),
+			SubmitBlockSimError:         simError.Error(),
+			SubmitBlockSimErrorResponse: simErrorResponse,
+		}
+	}
 	return nil
 }
 
@@ -159,6 +327,10 @@ func (db MockDB) UpdateBuilderDemotion(trace *types.BidTrace, signedBlock *types
 		return fmt.Errorf("builder with pubkey %v is not demoted", pubkey)
 	}
 	db.Refunds[pubkey] = true
+	if entry, ok := db.DemotionEntries[pubkey]; ok {
+		entry.SignedBeaconBlock = sql.NullString{String: "", Valid: signedBlock != nil}
+		entry.SignedValidatorRegistration = sql.NullString{String: "", Valid: signedRegistration != nil}
+	}
 	return nil
 }
 
@@ -168,8 +340,38 @@ func (db MockDB) GetBuilderDemotion(trace *types.BidTrace) (*BuilderDemotionEntr
 	if !ok {
 		return nil, fmt.Errorf("builder with pubkey %v not in Builders map", pubkey)
 	}
+	if entry, ok := db.DemotionEntries[pubkey]; ok {
+		return entry, nil
+	}
 	if db.Demotions[pubkey] {
 		return &BuilderDemotionEntry{}, nil
 	}
 	return nil, nil
 }
+
+func (db MockDB) GetBuilderDemotions(filters GetBuilderDemotionsFilters) ([]*BuilderDemotionEntry, error) {
+	entries := []*BuilderDemotionEntry{}
+	for _, entry := range db.DemotionEntries {
+		if filters.BuilderPubkey != "" && entry.BuilderPubkey != filters.BuilderPubkey {
+			continue
+		}
+		if (filters.SlotFrom > 0 && entry.Slot < filters.SlotFrom) || (filters.SlotTo > 0 && entry.Slot > filters.SlotTo) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].InsertedAt.After(entries[j].InsertedAt)
+	})
+	if uint64(len(entries)) > filters.Limit {
+		entries = entries[:filters.Limit]
+	}
+	return entries, nil
+}
+
+func (db MockDB) SaveMetricsSnapshot(entry *MetricsSnapshotEntry) error {
+	if db.MetricsSnapshots != nil {
+		db.MetricsSnapshots <- entry
+	}
+	return nil
+}
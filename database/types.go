@@ -22,15 +22,23 @@ func NewNullString(s string) sql.NullString {
 	}
 }
 
+// NewNullTime returns a NULL for the zero time, e.g. the eligible_at of a submission that never became eligible
 func NewNullTime(t time.Time) sql.NullTime {
 	return sql.NullTime{
 		Time:  t,
-		Valid: true,
+		Valid: !t.IsZero(),
 	}
 }
 
+// SimErrorDetailer is implemented by simulation errors carrying the structured error object of the simulation node
+type SimErrorDetailer interface {
+	SimErrorDetail() string
+}
+
 type GetPayloadsFilters struct {
 	Slot           uint64
+	SlotFrom       uint64
+	SlotTo         uint64
 	Cursor         uint64
 	Limit          uint64
 	BlockHash      string
@@ -41,14 +49,30 @@ type GetPayloadsFilters struct {
 }
 
 type GetBuilderSubmissionsFilters struct {
-	Slot        uint64
-	Limit       uint64
-	BlockHash   string
-	BlockNumber uint64
-	// Cursor      uint64
+	Slot             uint64
+	Limit            uint64
+	BlockHash        string
+	BlockNumber      uint64
+	Cursor           uint64
+	BuilderPubkey    string
+	OrderByFillRatio int8
+}
+
+type GetBuilderDemotionsFilters struct {
+	SlotFrom      uint64
+	SlotTo        uint64
+	Limit         uint64
 	BuilderPubkey string
 }
 
+type GetBlockBuildersFilters struct {
+	IsHighPrio    bool
+	IsBlacklisted bool
+	IsDemoted     bool
+	Limit         uint64
+	Offset        uint64
+}
+
 type ValidatorRegistrationEntry struct {
 	ID         int64     `db:"id"`
 	InsertedAt time.Time `db:"inserted_at"`
@@ -136,6 +160,12 @@ type BuilderBlockSubmissionEntry struct {
 	SimSuccess bool   `db:"sim_success"`
 	SimError   string `db:"sim_error"`
 
+	// Error object returned by the simulation node as JSON, if the simulation was rejected by it
+	SimErrorDetail sql.NullString `db:"sim_error_detail"`
+
+	// Set if the bid couldn't be saved in Redis after retries, and so never became eligible
+	RedisError string `db:"redis_error"`
+
 	// BidTrace data
 	Signature string `db:"signature"`
 
@@ -168,12 +198,23 @@ type BuilderBlockSubmissionEntry struct {
 	ChecksDuration      uint64 `db:"checks_duration"`
 	RandaoLock2Duration uint64 `db:"randao_lock_2_duration"`
 
-	PrecheckDuration     uint64 `db:"precheck_duration"`
-	SimulationDuration   uint64 `db:"simulation_duration"`
-	RedisUpdateDuration  uint64 `db:"redis_update_duration"`
-	SubmissionDuration   uint64 `db:"submission_duration"`
-	OptimisticSubmission bool   `db:"optimistic_submission"`
-	PayloadParsed        bool   `db:"payload_parsed"`
+	PrecheckDuration        uint64 `db:"precheck_duration"`
+	SimulationQueueDuration uint64 `db:"simulation_queue_duration"`
+	SimulationDuration      uint64 `db:"simulation_duration"`
+	RedisUpdateDuration     uint64 `db:"redis_update_duration"`
+	SubmissionDuration      uint64 `db:"submission_duration"`
+	OptimisticSubmission    bool   `db:"optimistic_submission"`
+	PayloadParsed           bool   `db:"payload_parsed"`
+
+	// Full profile as JSON, the individual duration columns above are only kept for backwards compatibility.
+	Profile sql.NullString `db:"profile"`
+
+	// Origin of the submission, only served by the internal API
+	RemoteAddr string `db:"remote_addr"`
+
+	// Parent execution block as known during validation, zero if unknown or not recorded
+	ParentTimestamp uint64 `db:"parent_timestamp"`
+	ParentGasLimit  uint64 `db:"parent_gas_limit"`
 }
 
 type DeliveredPayloadEntry struct {
@@ -199,6 +240,8 @@ type DeliveredPayloadEntry struct {
 
 	NumTx uint64 `db:"num_tx"`
 	Value string `db:"value"`
+
+	Reorged bool `db:"reorged"`
 }
 
 type BlockBuilderEntry struct {
@@ -212,6 +255,9 @@ type BlockBuilderEntry struct {
 	IsBlacklisted bool `db:"is_blacklisted" json:"is_blacklisted"`
 	IsDemoted     bool `db:"is_demoted"     json:"is_demoted"`
 
+	// DemotedAt is when the builder was demoted, NULL if it isn't demoted
+	DemotedAt sql.NullTime `db:"demoted_at" json:"-"`
+
 	CollateralValue string `db:"collateral_value"  json:"collateral_value"`
 	CollateralID    string `db:"collateral_id"     json:"collateral_id"`
 
@@ -244,5 +290,73 @@ type BuilderDemotionEntry struct {
 
 	BlockHash string `db:"block_hash"`
 
-	SubmitBlockSimError string `db:"submit_block_sim_error"`
+	SubmitBlockSimError         string `db:"submit_block_sim_error"`
+	SubmitBlockSimErrorResponse string `db:"submit_block_sim_error_response"`
+}
+
+// FeeRecipientChangeEntry is a validator registration with a different fee recipient than the previous one
+type FeeRecipientChangeEntry struct {
+	ID         int64     `db:"id"`
+	InsertedAt time.Time `db:"inserted_at"`
+
+	Pubkey          string `db:"pubkey"`
+	OldFeeRecipient string `db:"old_fee_recipient"`
+	NewFeeRecipient string `db:"new_fee_recipient"`
+	Timestamp       uint64 `db:"timestamp"`
+}
+
+// RawBlockSubmissionEntry is the decompressed request body of a block submission, as sent by the builder
+type RawBlockSubmissionEntry struct {
+	ID         int64     `db:"id"`
+	InsertedAt time.Time `db:"inserted_at"`
+
+	Slot          uint64 `db:"slot"`
+	BuilderPubkey string `db:"builder_pubkey"`
+	BlockHash     string `db:"block_hash"`
+	Body          []byte `db:"body"`
+}
+
+// BuilderStatsEntry aggregates the submissions and delivered payloads of a builder in a slot range, values in wei
+type BuilderStatsEntry struct {
+	BuilderPubkey  string `db:"builder_pubkey"`
+	NumSubmissions uint64 `db:"num_submissions"`
+	NumSimErrors   uint64 `db:"num_sim_errors"`
+	AvgValue       string `db:"avg_value"`
+	MaxValue       string `db:"max_value"`
+	NumDelivered   uint64 `db:"num_delivered"`
+	ValueDelivered string `db:"value_delivered"`
+}
+
+// SubmissionDurationStatsEntry holds duration percentiles of block submissions in a slot range, in microseconds
+type SubmissionDurationStatsEntry struct {
+	NumSubmissions uint64 `db:"num_submissions"`
+
+	DecodeP50 float64 `db:"decode_p50"`
+	DecodeP90 float64 `db:"decode_p90"`
+	DecodeP99 float64 `db:"decode_p99"`
+
+	SimulationP50 float64 `db:"simulation_p50"`
+	SimulationP90 float64 `db:"simulation_p90"`
+	SimulationP99 float64 `db:"simulation_p99"`
+
+	RedisUpdateP50 float64 `db:"redis_update_p50"`
+	RedisUpdateP90 float64 `db:"redis_update_p90"`
+	RedisUpdateP99 float64 `db:"redis_update_p99"`
+
+	SubmissionP50 float64 `db:"submission_p50"`
+	SubmissionP90 float64 `db:"submission_p90"`
+	SubmissionP99 float64 `db:"submission_p99"`
+}
+
+// MetricsSnapshotEntry holds the counters of a relay instance since its start
+type MetricsSnapshotEntry struct {
+	ID         int64     `db:"id"`
+	InsertedAt time.Time `db:"inserted_at"`
+
+	NumSubmissions       uint64 `db:"num_submissions"`
+	NumPayloadsDelivered uint64 `db:"num_payloads_delivered"`
+	NumBuilderDemotions  uint64 `db:"num_builder_demotions"`
+
+	NumActiveValidatorsDropped uint64 `db:"num_active_validators_dropped"`
+	NumValidatorRegsDropped    uint64 `db:"num_validator_regs_dropped"`
 }
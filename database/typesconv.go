@@ -1,6 +1,7 @@
 package database
 
 import (
+	"encoding"
 	"encoding/json"
 
 	"github.com/flashbots/go-boost-utils/types"
@@ -36,18 +37,58 @@ func DeliveredPayloadEntryToBidTraceV2JSON(payload *DeliveredPayloadEntry) commo
 		Value:                payload.Value,
 		NumTx:                payload.NumTx,
 		BlockNumber:          payload.BlockNumber,
+		FillRatio:            common.GasFillRatio(payload.GasUsed, payload.GasLimit),
 	}
 }
 
+// BuilderSubmissionEntryToBidTraceV2 restores the bid trace of a saved block submission
+func BuilderSubmissionEntryToBidTraceV2(entry *BuilderBlockSubmissionEntry) (*common.BidTraceV2, error) {
+	bidTrace := &common.BidTraceV2{
+		BidTrace: types.BidTrace{
+			Slot:     entry.Slot,
+			GasLimit: entry.GasLimit,
+			GasUsed:  entry.GasUsed,
+		},
+		BlockNumber: entry.BlockNumber,
+		NumTx:       entry.NumTx,
+	}
+	for _, field := range []struct {
+		dst encoding.TextUnmarshaler
+		src string
+	}{
+		{&bidTrace.ParentHash, entry.ParentHash},
+		{&bidTrace.BlockHash, entry.BlockHash},
+		{&bidTrace.BuilderPubkey, entry.BuilderPubkey},
+		{&bidTrace.ProposerPubkey, entry.ProposerPubkey},
+		{&bidTrace.ProposerFeeRecipient, entry.ProposerFeeRecipient},
+		{&bidTrace.Value, entry.Value},
+	} {
+		if err := field.dst.UnmarshalText([]byte(field.src)); err != nil {
+			return nil, err
+		}
+	}
+	return bidTrace, nil
+}
+
 func BuilderSubmissionEntryToBidTraceV2WithTimestampJSON(payload *BuilderBlockSubmissionEntry) common.BidTraceV2WithTimestampJSON {
 	timestamp := payload.InsertedAt
 	if payload.ReceivedAt.Valid {
 		timestamp = payload.ReceivedAt.Time
 	}
 
+	var receivedAtMs, eligibleAtMs int64
+	if payload.ReceivedAt.Valid {
+		receivedAtMs = payload.ReceivedAt.Time.UnixMilli()
+	}
+	if payload.EligibleAt.Valid {
+		eligibleAtMs = payload.EligibleAt.Time.UnixMilli()
+	}
+
 	return common.BidTraceV2WithTimestampJSON{
-		Timestamp:   timestamp.Unix(),
-		TimestampMs: timestamp.UnixMilli(),
+		Timestamp:    timestamp.Unix(),
+		TimestampMs:  timestamp.UnixMilli(),
+		ReceivedAtMs: receivedAtMs,
+		EligibleAtMs: eligibleAtMs,
 		BidTraceV2JSON: common.BidTraceV2JSON{
 			Slot:                 payload.Slot,
 			ParentHash:           payload.ParentHash,
@@ -60,6 +101,33 @@ func BuilderSubmissionEntryToBidTraceV2WithTimestampJSON(payload *BuilderBlockSu
 			Value:                payload.Value,
 			NumTx:                payload.NumTx,
 			BlockNumber:          payload.BlockNumber,
+			FillRatio:            common.GasFillRatio(payload.GasUsed, payload.GasLimit),
 		},
 	}
 }
+
+func FeeRecipientChangeEntryToJSON(entry *FeeRecipientChangeEntry) common.FeeRecipientChangeJSON {
+	return common.FeeRecipientChangeJSON{
+		Pubkey:          entry.Pubkey,
+		OldFeeRecipient: entry.OldFeeRecipient,
+		NewFeeRecipient: entry.NewFeeRecipient,
+		Timestamp:       entry.Timestamp,
+	}
+}
+
+func BuilderSubmissionEntryToRejectedSubmissionJSON(payload *BuilderBlockSubmissionEntry) common.RejectedSubmissionJSON {
+	timestamp := payload.InsertedAt
+	if payload.ReceivedAt.Valid {
+		timestamp = payload.ReceivedAt.Time
+	}
+
+	return common.RejectedSubmissionJSON{
+		Slot:          payload.Slot,
+		BuilderPubkey: payload.BuilderPubkey,
+		BlockHash:     payload.BlockHash,
+		Value:         payload.Value,
+		Reason:        payload.SimError,
+		TimestampMs:   timestamp.UnixMilli(),
+		RemoteAddr:    payload.RemoteAddr,
+	}
+}
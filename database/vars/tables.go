@@ -13,4 +13,7 @@ var (
 	TableDeliveredPayload       = tableBase + "_payload_delivered"
 	TableBlockBuilder           = tableBase + "_blockbuilder"
 	TableBuilderDemotions       = tableBase + "_builder_demotions"
+	TableMetricsSnapshot        = tableBase + "_metrics_snapshot"
+	TableFeeRecipientChanges    = tableBase + "_fee_recipient_changes"
+	TableRawBlockSubmission     = tableBase + "_raw_block_submission"
 )
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,9 +23,13 @@ var (
 	expiryBidCache = 45 * time.Second
 
 	activeValidatorsHours  = cli.GetEnvInt("ACTIVE_VALIDATOR_HOURS", 3)
-	expiryActiveValidators = time.Duration(activeValidatorsHours) * time.Hour // careful with this setting - for each hour a hash set is created with each active proposer as field. for a lot of hours this can take a lot of space in redis.
+	expiryActiveValidators = time.Duration(activeValidatorsHours) * time.Hour // careful with this setting - for each hour a hash set and a sorted set are created with each active proposer as member. for a lot of hours this can take a lot of space in redis.
 
 	RedisConfigFieldPubkey                  = "pubkey"
+	RedisConfigFieldOptimisticEnabled       = "optimistic-enabled"
+	RedisConfigFieldForceGetHeader204       = "force-get-header-204"
+	RedisConfigFieldDisableBlockPublishing  = "disable-block-publishing"
+	RedisConfigFieldDisableLowPrioBuilders  = "disable-low-prio-builders"
 	RedisStatsFieldLatestSlot               = "latest-slot"
 	RedisStatsFieldValidatorsTotal          = "validators-total"
 	RedisStatsFieldSlotLastPayloadDelivered = "slot-last-payload-delivered"
@@ -32,6 +37,16 @@ var (
 	ErrFailedUpdatingTopBidNoBids = errors.New("failed to update top bid because no bids were found")
 )
 
+// TieBreakPolicy decides which builder's bid becomes the top bid if several have exactly the top value.
+// The value always dominates, the policy only applies to exact ties.
+type TieBreakPolicy string
+
+const (
+	TieBreakFirstSeen     TieBreakPolicy = "first-seen"      // earliest received latest bid
+	TieBreakHighPrioFirst TieBreakPolicy = "high-prio-first" // high-prio builders, then first-seen
+	TieBreakIncumbent     TieBreakPolicy = "incumbent"       // builder of the current top bid, then first-seen
+)
+
 func PubkeyHexToLowerStr(pk types.PubkeyHex) string {
 	return strings.ToLower(string(pk))
 }
@@ -61,9 +76,13 @@ type RedisCache struct {
 	prefixGetPayloadResponse          string
 	prefixBidTrace                    string
 	prefixActiveValidators            string
+	prefixActiveValidatorsSorted      string // active validators sorted by pubkey, for pagination
 	prefixBlockBuilderLatestBids      string // latest bid for a given slot
 	prefixBlockBuilderLatestBidsValue string // value of latest bid for a given slot
 	prefixBlockBuilderLatestBidsTime  string // when the request was received, to avoid older requests overwriting newer ones after a slot validation
+	prefixSubmitBlockResponse         string // response to a block submission, to answer retries with the same idempotency key
+	prefixTopBidBuilder               string // builder of the top bid, for the incumbent tie-break policy
+	prefixBuilderSubmissionCount      string // number of blocks submitted by a builder for a slot
 
 	// keys
 	keyKnownValidators                string
@@ -72,6 +91,9 @@ type RedisCache struct {
 	keyRelayConfig    string
 	keyStats          string
 	keyProposerDuties string
+	keySlotBlocklist  string // set of slots for which no bids are served
+
+	keyFeeRecipientAllowlist string // hashmap with the allowed fee recipients (comma separated) per proposer pubkey
 }
 
 func NewRedisCache(redisURI, prefix string) (*RedisCache, error) {
@@ -88,9 +110,14 @@ func NewRedisCache(redisURI, prefix string) (*RedisCache, error) {
 		prefixBidTrace:           fmt.Sprintf("%s/%s:cache-bid-trace", redisPrefix, prefix),
 		prefixActiveValidators:   fmt.Sprintf("%s/%s:active-validators", redisPrefix, prefix), // one entry per hour
 
+		prefixActiveValidatorsSorted: fmt.Sprintf("%s/%s:active-validators-sorted", redisPrefix, prefix), // one sorted set per hour, all with score 0 to be ordered by pubkey
+
 		prefixBlockBuilderLatestBids:      fmt.Sprintf("%s/%s:block-builder-latest-bid", redisPrefix, prefix),       // hashmap for slot+parentHash+proposerPubkey with builderPubkey as field
 		prefixBlockBuilderLatestBidsValue: fmt.Sprintf("%s/%s:block-builder-latest-bid-value", redisPrefix, prefix), // hashmap for slot+parentHash+proposerPubkey with builderPubkey as field
 		prefixBlockBuilderLatestBidsTime:  fmt.Sprintf("%s/%s:block-builder-latest-bid-time", redisPrefix, prefix),  // hashmap for slot+parentHash+proposerPubkey with builderPubkey as field
+		prefixSubmitBlockResponse:         fmt.Sprintf("%s/%s:submit-block-response", redisPrefix, prefix),
+		prefixTopBidBuilder:               fmt.Sprintf("%s/%s:top-bid-builder", redisPrefix, prefix),
+		prefixBuilderSubmissionCount:      fmt.Sprintf("%s/%s:builder-submission-count", redisPrefix, prefix),
 
 		keyKnownValidators:                fmt.Sprintf("%s/%s:known-validators", redisPrefix, prefix),
 		keyValidatorRegistrationTimestamp: fmt.Sprintf("%s/%s:validator-registration-timestamp", redisPrefix, prefix),
@@ -98,9 +125,17 @@ func NewRedisCache(redisURI, prefix string) (*RedisCache, error) {
 
 		keyStats:          fmt.Sprintf("%s/%s:stats", redisPrefix, prefix),
 		keyProposerDuties: fmt.Sprintf("%s/%s:proposer-duties", redisPrefix, prefix),
+		keySlotBlocklist:  fmt.Sprintf("%s/%s:slot-blocklist", redisPrefix, prefix),
+
+		keyFeeRecipientAllowlist: fmt.Sprintf("%s/%s:fee-recipient-allowlist", redisPrefix, prefix),
 	}, nil
 }
 
+// keyActiveValidatorsSorted returns the key of the sorted set for the date + hour of the given time
+func (r *RedisCache) keyActiveValidatorsSorted(t time.Time) string {
+	return fmt.Sprintf("%s:%s", r.prefixActiveValidatorsSorted, t.UTC().Format("2006-01-02T15"))
+}
+
 func (r *RedisCache) keyCacheGetHeaderResponse(slot uint64, parentHash, proposerPubkey string) string {
 	return fmt.Sprintf("%s:%d_%s_%s", r.prefixGetHeaderResponse, slot, parentHash, proposerPubkey)
 }
@@ -133,6 +168,18 @@ func (r *RedisCache) keyBlockBuilderLatestBidsTime(slot uint64, parentHash, prop
 	return fmt.Sprintf("%s:%d_%s_%s", r.prefixBlockBuilderLatestBidsTime, slot, parentHash, proposerPubkey)
 }
 
+func (r *RedisCache) keyTopBidBuilder(slot uint64, parentHash, proposerPubkey string) string {
+	return fmt.Sprintf("%s:%d_%s_%s", r.prefixTopBidBuilder, slot, parentHash, proposerPubkey)
+}
+
+func (r *RedisCache) keyBuilderSubmissionCount(slot uint64, builderPubkey string) string {
+	return fmt.Sprintf("%s:%d_%s", r.prefixBuilderSubmissionCount, slot, builderPubkey)
+}
+
+func (r *RedisCache) keySubmitBlockResponse(builderPubkey, idempotencyKey string) string {
+	return fmt.Sprintf("%s:%s_%s", r.prefixSubmitBlockResponse, builderPubkey, idempotencyKey)
+}
+
 func (r *RedisCache) GetObj(key string, obj any) (err error) {
 	value, err := r.client.Get(context.Background(), key).Result()
 	if err != nil {
@@ -212,14 +259,20 @@ func (r *RedisCache) SetValidatorRegistrationTimestamp(proposerPubkey types.Pubk
 }
 
 func (r *RedisCache) SetActiveValidator(pubkeyHex types.PubkeyHex) error {
-	key := r.keyActiveValidators(time.Now())
-	err := r.client.HSet(context.Background(), key, PubkeyHexToLowerStr(pubkeyHex), "1").Err()
-	if err != nil {
-		return err
-	}
-
-	// set expiry
-	return r.client.Expire(context.Background(), key, expiryActiveValidators).Err()
+	now := time.Now()
+	key := r.keyActiveValidators(now)
+	keySorted := r.keyActiveValidatorsSorted(now)
+	pubkey := PubkeyHexToLowerStr(pubkeyHex)
+
+	ctx := context.Background()
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, pubkey, "1")
+		pipe.Expire(ctx, key, expiryActiveValidators)
+		pipe.ZAdd(ctx, keySorted, redis.Z{Score: 0, Member: pubkey})
+		pipe.Expire(ctx, keySorted, expiryActiveValidators)
+		return nil
+	})
+	return err
 }
 
 func (r *RedisCache) GetActiveValidators() (map[types.PubkeyHex]bool, error) {
@@ -240,6 +293,47 @@ func (r *RedisCache) GetActiveValidators() (map[types.PubkeyHex]bool, error) {
 	return validators, nil
 }
 
+// GetActiveValidatorsPage returns up to limit active validators sorted by pubkey, starting after the cursor pubkey.
+// nextCursor is empty if there are no more validators.
+func (r *RedisCache) GetActiveValidatorsPage(cursor types.PubkeyHex, limit int) (pubkeys []types.PubkeyHex, nextCursor types.PubkeyHex, err error) {
+	min := "-"
+	if cursor != "" {
+		min = "(" + PubkeyHexToLowerStr(cursor)
+	}
+
+	// The first limit+1 validators after the cursor of each hour are enough to tell if there is another page
+	ctx := context.Background()
+	now := time.Now()
+	cmds := make([]*redis.StringSliceCmd, activeValidatorsHours)
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range cmds {
+			key := r.keyActiveValidatorsSorted(now.Add(time.Duration(-i) * time.Hour))
+			cmds[i] = pipe.ZRangeByLex(ctx, key, &redis.ZRangeBy{Min: min, Max: "+", Count: int64(limit + 1)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	seen := make(map[string]bool)
+	for _, cmd := range cmds {
+		for _, pubkey := range cmd.Val() {
+			if !seen[pubkey] {
+				seen[pubkey] = true
+				pubkeys = append(pubkeys, types.PubkeyHex(pubkey))
+			}
+		}
+	}
+	sort.Slice(pubkeys, func(i, j int) bool { return pubkeys[i] < pubkeys[j] })
+
+	if len(pubkeys) > limit {
+		pubkeys = pubkeys[:limit]
+		nextCursor = pubkeys[limit-1]
+	}
+	return pubkeys, nextCursor, nil
+}
+
 func (r *RedisCache) SetStats(field string, value any) (err error) {
 	return r.client.HSet(context.Background(), r.keyStats, field, value).Err()
 }
@@ -273,6 +367,60 @@ func (r *RedisCache) GetRelayConfig(field string) (string, error) {
 	return res, err
 }
 
+func (r *RedisCache) AddBlocklistedSlot(slot uint64) error {
+	return r.client.SAdd(context.Background(), r.keySlotBlocklist, slot).Err()
+}
+
+func (r *RedisCache) RemoveBlocklistedSlots(slots ...uint64) error {
+	if len(slots) == 0 {
+		return nil
+	}
+	members := make([]any, len(slots))
+	for i, slot := range slots {
+		members[i] = slot
+	}
+	return r.client.SRem(context.Background(), r.keySlotBlocklist, members...).Err()
+}
+
+// GetBlocklistedSlots returns the slots for which no bids are served, in ascending order
+func (r *RedisCache) GetBlocklistedSlots() ([]uint64, error) {
+	members, err := r.client.SMembers(context.Background(), r.keySlotBlocklist).Result()
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]uint64, 0, len(members))
+	for _, member := range members {
+		slot, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots, nil
+}
+
+// SetFeeRecipientAllowlist saves the allowed fee recipients of a proposer, an empty list removes the entry
+func (r *RedisCache) SetFeeRecipientAllowlist(proposerPubkey string, feeRecipients []string) error {
+	if len(feeRecipients) == 0 {
+		return r.client.HDel(context.Background(), r.keyFeeRecipientAllowlist, proposerPubkey).Err()
+	}
+	return r.client.HSet(context.Background(), r.keyFeeRecipientAllowlist, proposerPubkey, strings.Join(feeRecipients, ",")).Err()
+}
+
+// GetFeeRecipientAllowlists returns the allowed fee recipients by proposer pubkey
+func (r *RedisCache) GetFeeRecipientAllowlists() (map[string][]string, error) {
+	entries, err := r.client.HGetAll(context.Background(), r.keyFeeRecipientAllowlist).Result()
+	if err != nil {
+		return nil, err
+	}
+	allowlists := make(map[string][]string, len(entries))
+	for proposerPubkey, feeRecipients := range entries {
+		allowlists[proposerPubkey] = strings.Split(feeRecipients, ",")
+	}
+	return allowlists, nil
+}
+
 func (r *RedisCache) GetBestBid(slot uint64, parentHash, proposerPubkey string) (*types.GetHeaderResponse, error) {
 	key := r.keyCacheGetHeaderResponse(slot, parentHash, proposerPubkey)
 	resp := new(types.GetHeaderResponse)
@@ -283,6 +431,33 @@ func (r *RedisCache) GetBestBid(slot uint64, parentHash, proposerPubkey string)
 	return resp, err
 }
 
+// GetTopBids returns the latest bids of up to n builders for a slot, ordered by value (highest first)
+func (r *RedisCache) GetTopBids(slot uint64, parentHash, proposerPubkey string, n int) ([]*types.GetHeaderResponse, error) {
+	keyLatestBids := r.keyBlockBuilderLatestBids(slot, parentHash, proposerPubkey)
+	bidMap, err := r.client.HGetAll(context.Background(), keyLatestBids).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	bids := make([]*types.GetHeaderResponse, 0, len(bidMap))
+	for builderPubkey, bidStr := range bidMap {
+		bid := new(types.GetHeaderResponse)
+		err = json.Unmarshal([]byte(bidStr), bid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latest bid of builder %s: %w", builderPubkey, err)
+		}
+		bids = append(bids, bid)
+	}
+
+	sort.Slice(bids, func(i, j int) bool {
+		return bids[i].Data.Message.Value.Cmp(&bids[j].Data.Message.Value) > 0
+	})
+	if len(bids) > n {
+		bids = bids[:n]
+	}
+	return bids, nil
+}
+
 func (r *RedisCache) SaveExecutionPayload(slot uint64, proposerPubkey, blockHash string, resp *types.GetPayloadResponse) (err error) {
 	key := r.keyCacheGetPayloadResponse(slot, proposerPubkey, blockHash)
 	return r.SetObj(key, resp, expiryBidCache)
@@ -298,6 +473,35 @@ func (r *RedisCache) GetExecutionPayload(slot uint64, proposerPubkey, blockHash
 	return resp, err
 }
 
+func (r *RedisCache) SaveSubmitBlockResponse(builderPubkey, idempotencyKey string, resp *common.SubmitBlockResponse) (err error) {
+	key := r.keySubmitBlockResponse(builderPubkey, idempotencyKey)
+	return r.SetObj(key, resp, expiryBidCache)
+}
+
+// IncrBuilderSubmissionCount counts a block submission of the builder for the slot, and returns the number of submissions so far
+func (r *RedisCache) IncrBuilderSubmissionCount(slot uint64, builderPubkey string) (int64, error) {
+	key := r.keyBuilderSubmissionCount(slot, builderPubkey)
+	count, err := r.client.Incr(context.Background(), key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		err = r.client.Expire(context.Background(), key, expiryBidCache).Err()
+	}
+	return count, err
+}
+
+// GetSubmitBlockResponse returns the cached response to a submission with the given idempotency key, or nil if there is none
+func (r *RedisCache) GetSubmitBlockResponse(builderPubkey, idempotencyKey string) (*common.SubmitBlockResponse, error) {
+	key := r.keySubmitBlockResponse(builderPubkey, idempotencyKey)
+	resp := new(common.SubmitBlockResponse)
+	err := r.GetObj(key, resp)
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return resp, err
+}
+
 func (r *RedisCache) SaveBidTrace(trace *common.BidTraceV2) (err error) {
 	key := r.keyCacheBidTrace(trace.Slot, trace.ProposerPubkey.String(), trace.BlockHash.String())
 	return r.SetObj(key, trace, expiryBidCache)
@@ -322,6 +526,23 @@ func (r *RedisCache) GetBuilderLatestPayloadReceivedAt(slot uint64, builderPubke
 	return timestamp, err
 }
 
+// GetBuilderLatestValue returns the value of the latest bid by a specific builder, or nil if there is none
+func (r *RedisCache) GetBuilderLatestValue(slot uint64, builderPubkey, parentHash, proposerPubkey string) (*big.Int, error) {
+	keyLatestBidsValue := r.keyBlockBuilderLatestBidsValue(slot, parentHash, proposerPubkey)
+	valueStr, err := r.client.HGet(context.Background(), keyLatestBidsValue, builderPubkey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	value, ok := new(big.Int).SetString(valueStr, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid bid value %s", valueStr)
+	}
+	return value, nil
+}
+
 // SaveLatestBuilderBid saves the latest bid by a specific builder
 func (r *RedisCache) SaveLatestBuilderBid(slot uint64, builderPubkey, parentHash, proposerPubkey string, receivedAt time.Time, headerResp *types.GetHeaderResponse) (err error) {
 	keyLatestBids := r.keyBlockBuilderLatestBids(slot, parentHash, proposerPubkey)
@@ -350,7 +571,50 @@ func (r *RedisCache) SaveLatestBuilderBid(slot uint64, builderPubkey, parentHash
 	return r.client.Expire(context.Background(), keyLatestBidsValue, expiryBidCache).Err()
 }
 
-func (r *RedisCache) UpdateTopBid(slot uint64, parentHash, proposerPubkey string) (err error) {
+// SaveSubmission saves the bid trace, execution payload and latest builder bid of a block submission in one round
+// trip. The writes are applied atomically, so the bid is never visible without its payload.
+func (r *RedisCache) SaveSubmission(trace *common.BidTraceV2, receivedAt time.Time, headerResp *types.GetHeaderResponse, payloadResp *types.GetPayloadResponse) (err error) {
+	traceBytes, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	payloadBytes, err := json.Marshal(payloadResp)
+	if err != nil {
+		return err
+	}
+	headerBytes, err := json.Marshal(headerResp)
+	if err != nil {
+		return err
+	}
+
+	slot := trace.Slot
+	builderPubkey := trace.BuilderPubkey.String()
+	parentHash := trace.ParentHash.String()
+	proposerPubkey := trace.ProposerPubkey.String()
+	blockHash := trace.BlockHash.String()
+
+	keyLatestBids := r.keyBlockBuilderLatestBids(slot, parentHash, proposerPubkey)
+	keyLatestBidsTime := r.keyBlockBuilderLatestBidsTime(slot, parentHash, proposerPubkey)
+	keyLatestBidsValue := r.keyBlockBuilderLatestBidsValue(slot, parentHash, proposerPubkey)
+
+	ctx := context.Background()
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.keyCacheBidTrace(slot, proposerPubkey, blockHash), traceBytes, expiryBidCache)
+		pipe.Set(ctx, r.keyCacheGetPayloadResponse(slot, proposerPubkey, blockHash), payloadBytes, expiryBidCache)
+		pipe.HSet(ctx, keyLatestBids, builderPubkey, headerBytes)
+		pipe.Expire(ctx, keyLatestBids, expiryBidCache)
+		pipe.HSet(ctx, keyLatestBidsTime, builderPubkey, receivedAt.UnixMilli())
+		pipe.Expire(ctx, keyLatestBidsTime, expiryBidCache)
+		pipe.HSet(ctx, keyLatestBidsValue, builderPubkey, headerResp.Data.Message.Value.String())
+		pipe.Expire(ctx, keyLatestBidsValue, expiryBidCache)
+		return nil
+	})
+	return err
+}
+
+// UpdateTopBid sets the highest of the latest bids of all builders as the top bid, ties are broken by the policy.
+// isHighPrio is only used for TieBreakHighPrioFirst and may be nil.
+func (r *RedisCache) UpdateTopBid(slot uint64, parentHash, proposerPubkey string, tieBreak TieBreakPolicy, isHighPrio func(builderPubkey string) bool) (err error) {
 	// Get all builder's latest submission values
 	keyBidValues := r.keyBlockBuilderLatestBidsValue(slot, parentHash, proposerPubkey)
 	bidValueMap, err := r.client.HGetAll(context.Background(), keyBidValues).Result()
@@ -358,22 +622,40 @@ func (r *RedisCache) UpdateTopBid(slot uint64, parentHash, proposerPubkey string
 		return err
 	}
 
-	// Find bid with highest value among all the latest bids
+	// Find the builders with the highest value among all the latest bids
 	topBidValue := big.NewInt(0)
-	topBidBuilderPubkey := ""
+	topBidBuilderPubkeys := []string{}
 	for builderPubkey, bidValue := range bidValueMap {
 		val := new(big.Int)
 		val.SetString(bidValue, 10)
-		if val.Cmp(topBidValue) > 0 {
+		if cmp := val.Cmp(topBidValue); cmp > 0 {
 			topBidValue = val
-			topBidBuilderPubkey = builderPubkey
+			topBidBuilderPubkeys = []string{builderPubkey}
+		} else if cmp == 0 && len(topBidBuilderPubkeys) > 0 {
+			topBidBuilderPubkeys = append(topBidBuilderPubkeys, builderPubkey)
 		}
 	}
 
-	if topBidBuilderPubkey == "" {
+	if len(topBidBuilderPubkeys) == 0 {
 		return ErrFailedUpdatingTopBidNoBids
 	}
 
+	topBidBuilderPubkey := topBidBuilderPubkeys[0]
+	if len(topBidBuilderPubkeys) > 1 {
+		topBidBuilderPubkey, err = r.breakTopBidTie(slot, parentHash, proposerPubkey, topBidBuilderPubkeys, tieBreak, isHighPrio)
+		if err != nil {
+			return err
+		}
+	}
+
+	if tieBreak == TieBreakIncumbent {
+		keyTopBidBuilder := r.keyTopBidBuilder(slot, parentHash, proposerPubkey)
+		err = r.client.Set(context.Background(), keyTopBidBuilder, topBidBuilderPubkey, expiryBidCache).Err()
+		if err != nil {
+			return err
+		}
+	}
+
 	// Get the actual bid
 	keyBid := r.keyBlockBuilderLatestBids(slot, parentHash, proposerPubkey)
 	bidStr, err := r.client.HGet(context.Background(), keyBid, topBidBuilderPubkey).Result()
@@ -385,3 +667,49 @@ func (r *RedisCache) UpdateTopBid(slot uint64, parentHash, proposerPubkey string
 	keyTopBid := r.keyCacheGetHeaderResponse(slot, parentHash, proposerPubkey)
 	return r.client.Set(context.Background(), keyTopBid, bidStr, expiryBidCache).Err()
 }
+
+// breakTopBidTie picks one of the builders with equal top bid values, falling back to the earliest received bid
+func (r *RedisCache) breakTopBidTie(slot uint64, parentHash, proposerPubkey string, builderPubkeys []string, tieBreak TieBreakPolicy, isHighPrio func(builderPubkey string) bool) (string, error) {
+	switch tieBreak {
+	case TieBreakIncumbent:
+		incumbent, err := r.client.Get(context.Background(), r.keyTopBidBuilder(slot, parentHash, proposerPubkey)).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return "", err
+		}
+		for _, builderPubkey := range builderPubkeys {
+			if builderPubkey == incumbent {
+				return incumbent, nil
+			}
+		}
+	case TieBreakHighPrioFirst:
+		if isHighPrio != nil {
+			highPrio := []string{}
+			for _, builderPubkey := range builderPubkeys {
+				if isHighPrio(builderPubkey) {
+					highPrio = append(highPrio, builderPubkey)
+				}
+			}
+			if len(highPrio) > 0 {
+				builderPubkeys = highPrio
+			}
+		}
+	}
+
+	// First seen, by the time the latest bid of each builder was received, then by pubkey
+	keyLatestBidsTime := r.keyBlockBuilderLatestBidsTime(slot, parentHash, proposerPubkey)
+	receivedAtMap, err := r.client.HGetAll(context.Background(), keyLatestBidsTime).Result()
+	if err != nil {
+		return "", err
+	}
+	receivedAt := func(builderPubkey string) int64 {
+		ts, _ := strconv.ParseInt(receivedAtMap[builderPubkey], 10, 64)
+		return ts
+	}
+	sort.Slice(builderPubkeys, func(i, j int) bool {
+		if tsI, tsJ := receivedAt(builderPubkeys[i]), receivedAt(builderPubkeys[j]); tsI != tsJ {
+			return tsI < tsJ
+		}
+		return builderPubkeys[i] < builderPubkeys[j]
+	})
+	return builderPubkeys[0], nil
+}
@@ -1,12 +1,15 @@
 package datastore
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/flashbots/go-boost-utils/types"
 	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/go-redis/redis/v9"
 	"github.com/stretchr/testify/require"
 )
 
@@ -166,6 +169,50 @@ func TestActiveValidators(t *testing.T) {
 	require.True(t, vals[pk1])
 }
 
+func TestActiveValidatorsPage(t *testing.T) {
+	cache := setupTestRedis(t)
+	pubkeys := []types.PubkeyHex{}
+	for i := 0; i < 5; i++ {
+		pk := types.PubkeyHex(fmt.Sprintf("0x%096d", i))
+		pubkeys = append(pubkeys, pk)
+		err := cache.SetActiveValidator(pk)
+		require.NoError(t, err)
+	}
+
+	page, cursor, err := cache.GetActiveValidatorsPage("", 2)
+	require.NoError(t, err)
+	require.Equal(t, pubkeys[0:2], page)
+	require.Equal(t, pubkeys[1], cursor)
+
+	page, cursor, err = cache.GetActiveValidatorsPage(cursor, 2)
+	require.NoError(t, err)
+	require.Equal(t, pubkeys[2:4], page)
+	require.Equal(t, pubkeys[3], cursor)
+
+	page, cursor, err = cache.GetActiveValidatorsPage(cursor, 2)
+	require.NoError(t, err)
+	require.Equal(t, pubkeys[4:], page)
+	require.Equal(t, types.PubkeyHex(""), cursor)
+}
+
+func TestActiveValidatorsPageAcrossHours(t *testing.T) {
+	cache := setupTestRedis(t)
+	pk1 := types.PubkeyHex(fmt.Sprintf("0x%096d", 1))
+	pk2 := types.PubkeyHex(fmt.Sprintf("0x%096d", 2))
+	err := cache.SetActiveValidator(pk1)
+	require.NoError(t, err)
+
+	// Active in the previous hour as well, or only in the previous hour
+	keyPrevHour := cache.keyActiveValidatorsSorted(time.Now().Add(-time.Hour))
+	err = cache.client.ZAdd(context.Background(), keyPrevHour, redis.Z{Member: pk1.String()}, redis.Z{Member: pk2.String()}).Err()
+	require.NoError(t, err)
+
+	page, cursor, err := cache.GetActiveValidatorsPage("", 2)
+	require.NoError(t, err)
+	require.Equal(t, []types.PubkeyHex{pk1, pk2}, page)
+	require.Equal(t, types.PubkeyHex(""), cursor)
+}
+
 func _buildGetHeaderResponse(value uint64) *types.GetHeaderResponse {
 	return &types.GetHeaderResponse{
 		Version: "bellatrix",
@@ -197,7 +244,7 @@ func TestBuilderBids(t *testing.T) {
 	require.NoError(t, err)
 	err = cache.SaveLatestBuilderBid(slot, builder2pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(99))
 	require.NoError(t, err)
-	err = cache.UpdateTopBid(slot, parentHash, proposerPk)
+	err = cache.UpdateTopBid(slot, parentHash, proposerPk, TieBreakFirstSeen, nil)
 	require.NoError(t, err)
 	topBid, err := cache.GetBestBid(slot, parentHash, proposerPk)
 	require.NoError(t, err)
@@ -206,7 +253,7 @@ func TestBuilderBids(t *testing.T) {
 	// new top bid by builder3: 101
 	err = cache.SaveLatestBuilderBid(slot, builder3pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(101))
 	require.NoError(t, err)
-	err = cache.UpdateTopBid(slot, parentHash, proposerPk)
+	err = cache.UpdateTopBid(slot, parentHash, proposerPk, TieBreakFirstSeen, nil)
 	require.NoError(t, err)
 	topBid, err = cache.GetBestBid(slot, parentHash, proposerPk)
 	require.NoError(t, err)
@@ -215,7 +262,7 @@ func TestBuilderBids(t *testing.T) {
 	// builder3 cancels 101 bid, by sending 100 value
 	err = cache.SaveLatestBuilderBid(slot, builder3pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(99))
 	require.NoError(t, err)
-	err = cache.UpdateTopBid(slot, parentHash, proposerPk)
+	err = cache.UpdateTopBid(slot, parentHash, proposerPk, TieBreakFirstSeen, nil)
 	require.NoError(t, err)
 	topBid, err = cache.GetBestBid(slot, parentHash, proposerPk)
 	require.NoError(t, err)
@@ -225,6 +272,133 @@ func TestBuilderBids(t *testing.T) {
 	ts, err := cache.GetBuilderLatestPayloadReceivedAt(slot, builder3pk, parentHash, proposerPk)
 	require.NoError(t, err)
 	require.Equal(t, receivedAt.UnixMilli(), ts)
+
+	// latest bid values per builder
+	value, err := cache.GetBuilderLatestValue(slot, builder3pk, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, "99", value.String())
+	value, err = cache.GetBuilderLatestValue(slot, "0xb4", parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Nil(t, value)
+}
+
+func TestSaveSubmission(t *testing.T) {
+	cache := setupTestRedis(t)
+	receivedAt := time.Now()
+	trace := &common.BidTraceV2{
+		BidTrace: types.BidTrace{
+			Slot:           123,
+			ParentHash:     types.Hash{0xa1},
+			BlockHash:      types.Hash{0xa3},
+			BuilderPubkey:  types.PublicKey{0xb1},
+			ProposerPubkey: types.PublicKey{0xa2},
+			Value:          types.IntToU256(100),
+		},
+	}
+	payloadResp := &types.GetPayloadResponse{
+		Version: "bellatrix",
+		Data:    &types.ExecutionPayload{BlockHash: trace.BlockHash},
+	}
+	parentHash, proposerPk, blockHash := trace.ParentHash.String(), trace.ProposerPubkey.String(), trace.BlockHash.String()
+
+	err := cache.SaveSubmission(trace, receivedAt, _buildGetHeaderResponse(100), payloadResp)
+	require.NoError(t, err)
+
+	savedTrace, err := cache.GetBidTrace(trace.Slot, proposerPk, blockHash)
+	require.NoError(t, err)
+	require.Equal(t, trace.BuilderPubkey, savedTrace.BuilderPubkey)
+
+	savedPayload, err := cache.GetExecutionPayload(trace.Slot, proposerPk, blockHash)
+	require.NoError(t, err)
+	require.Equal(t, trace.BlockHash, savedPayload.Data.BlockHash)
+
+	ts, err := cache.GetBuilderLatestPayloadReceivedAt(trace.Slot, trace.BuilderPubkey.String(), parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, receivedAt.UnixMilli(), ts)
+
+	// The latest bid is picked up as the top bid
+	err = cache.UpdateTopBid(trace.Slot, parentHash, proposerPk, TieBreakFirstSeen, nil)
+	require.NoError(t, err)
+	topBid, err := cache.GetBestBid(trace.Slot, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, "100", topBid.Data.Message.Value.String())
+}
+
+func TestUpdateTopBidTieBreak(t *testing.T) {
+	slot := uint64(123)
+	parentHash := "0xa1"
+	proposerPk := "0xa2"
+	isHighPrio := func(builderPubkey string) bool { return builderPubkey == "0xb3" }
+
+	testCases := []struct {
+		tieBreak        TieBreakPolicy
+		expectedBuilder string
+	}{
+		{TieBreakFirstSeen, "0xb2"},
+		{TieBreakHighPrioFirst, "0xb3"},
+		{TieBreakIncumbent, "0xb1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.tieBreak), func(t *testing.T) {
+			cache := setupTestRedis(t)
+			receivedAt := time.Now()
+
+			// 0xb1 is the incumbent, then 0xb2 and 0xb3 tie with it (0xb2 first), the value always dominates
+			bids := []struct {
+				builderPubkey string
+				value         uint64
+			}{
+				{"0xb1", 100},
+				{"0xb2", 100},
+				{"0xb3", 100},
+				{"0xb4", 99},
+			}
+			for i, bid := range bids {
+				headerResp := _buildGetHeaderResponse(bid.value)
+				headerResp.Data.Message.Header.BlockNumber = uint64(i)
+				err := cache.SaveLatestBuilderBid(slot, bid.builderPubkey, parentHash, proposerPk, receivedAt.Add(time.Duration(i)*time.Second), headerResp)
+				require.NoError(t, err)
+				err = cache.UpdateTopBid(slot, parentHash, proposerPk, tc.tieBreak, isHighPrio)
+				require.NoError(t, err)
+			}
+
+			// 0xb1 resubmits the same value last, so it's no longer first seen
+			headerResp := _buildGetHeaderResponse(100)
+			err := cache.SaveLatestBuilderBid(slot, "0xb1", parentHash, proposerPk, receivedAt.Add(time.Minute), headerResp)
+			require.NoError(t, err)
+			err = cache.UpdateTopBid(slot, parentHash, proposerPk, tc.tieBreak, isHighPrio)
+			require.NoError(t, err)
+
+			topBid, err := cache.GetBestBid(slot, parentHash, proposerPk)
+			require.NoError(t, err)
+			expectedBlockNumber := map[string]uint64{"0xb1": 0, "0xb2": 1, "0xb3": 2}[tc.expectedBuilder]
+			require.Equal(t, expectedBlockNumber, topBid.Data.Message.Header.BlockNumber)
+		})
+	}
+}
+
+func TestGetTopBids(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	slot := uint64(123)
+	parentHash := "0xa1"
+	proposerPk := "0xa2"
+
+	bids, err := cache.GetTopBids(slot, parentHash, proposerPk, 2)
+	require.NoError(t, err)
+	require.Len(t, bids, 0)
+
+	for i, value := range []uint64{99, 101, 100} {
+		err = cache.SaveLatestBuilderBid(slot, fmt.Sprintf("0xb%d", i), parentHash, proposerPk, time.Now(), _buildGetHeaderResponse(value))
+		require.NoError(t, err)
+	}
+
+	bids, err = cache.GetTopBids(slot, parentHash, proposerPk, 2)
+	require.NoError(t, err)
+	require.Len(t, bids, 2)
+	require.Equal(t, "101", bids[0].Data.Message.Value.String())
+	require.Equal(t, "100", bids[1].Data.Message.Value.String())
 }
 
 func TestRedisURIs(t *testing.T) {
@@ -256,3 +430,63 @@ func TestRedisURIs(t *testing.T) {
 	_, err = NewRedisCache(malformURL, "")
 	require.Error(t, err)
 }
+
+func TestSubmitBlockResponse(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	resp, err := cache.GetSubmitBlockResponse("0xb1", "key")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	err = cache.SaveSubmitBlockResponse("0xb1", "key", &common.SubmitBlockResponse{Code: 400, Body: "invalid"})
+	require.NoError(t, err)
+
+	resp, err = cache.GetSubmitBlockResponse("0xb1", "key")
+	require.NoError(t, err)
+	require.Equal(t, &common.SubmitBlockResponse{Code: 400, Body: "invalid"}, resp)
+
+	// Keys are scoped to the builder
+	resp, err = cache.GetSubmitBlockResponse("0xb2", "key")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+}
+
+func TestSlotBlocklist(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	slots, err := cache.GetBlocklistedSlots()
+	require.NoError(t, err)
+	require.Empty(t, slots)
+
+	for _, slot := range []uint64{12, 10, 11} {
+		require.NoError(t, cache.AddBlocklistedSlot(slot))
+	}
+	slots, err = cache.GetBlocklistedSlots()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{10, 11, 12}, slots)
+
+	require.NoError(t, cache.RemoveBlocklistedSlots(10, 12))
+	slots, err = cache.GetBlocklistedSlots()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{11}, slots)
+}
+
+func TestFeeRecipientAllowlist(t *testing.T) {
+	cache := setupTestRedis(t)
+	proposerPubkey := "0x8a1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca249"
+
+	allowlists, err := cache.GetFeeRecipientAllowlists()
+	require.NoError(t, err)
+	require.Empty(t, allowlists)
+
+	feeRecipients := []string{"0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002"}
+	require.NoError(t, cache.SetFeeRecipientAllowlist(proposerPubkey, feeRecipients))
+	allowlists, err = cache.GetFeeRecipientAllowlists()
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{proposerPubkey: feeRecipients}, allowlists)
+
+	require.NoError(t, cache.SetFeeRecipientAllowlist(proposerPubkey, nil))
+	allowlists, err = cache.GetFeeRecipientAllowlists()
+	require.NoError(t, err)
+	require.Empty(t, allowlists)
+}
@@ -4,9 +4,16 @@ import (
 	"github.com/flashbots/mev-boost-relay/cmd"
 )
 
-var Version = "dev" // is set during build process
+// set during build process
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
 
 func main() {
 	cmd.Version = Version
+	cmd.Commit = Commit
+	cmd.BuildTime = BuildTime
 	cmd.Execute()
 }
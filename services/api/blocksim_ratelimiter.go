@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -17,15 +18,51 @@ import (
 )
 
 var (
-	ErrRequestClosed    = errors.New("request context closed")
-	ErrSimulationFailed = errors.New("simulation failed")
+	ErrRequestClosed     = errors.New("request context closed")
+	ErrSimulationFailed  = errors.New("simulation failed")
+	ErrSimulationTimeout = errors.New("simulation timed out")
 
 	maxConcurrentBlocks = int64(cli.GetEnvInt("BLOCKSIM_MAX_CONCURRENT", 4)) // 0 for no maximum
 	simRequestTimeout   = time.Duration(cli.GetEnvInt("BLOCKSIM_TIMEOUT_MS", 6000)) * time.Millisecond
 )
 
+// SimulationError is returned if the simulation node rejected the block, and keeps its full response for debugging
+type SimulationError struct {
+	Message     string
+	RawResponse string
+	Detail      string // JSON-RPC error object
+}
+
+func (e *SimulationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSimulationFailed, e.Message)
+}
+
+func (e *SimulationError) Unwrap() error {
+	return ErrSimulationFailed
+}
+
+// SimErrorDetail returns the JSON-RPC error object of the simulation node, stored with the block submission
+func (e *SimulationError) SimErrorDetail() string {
+	return e.Detail
+}
+
+// simErrorStatusCode returns the status code for a simulation error. Only blocks rejected by the simulation node get a
+// 422, a simulation node which is unreachable or doesn't respond in time is not the builder's fault.
+func simErrorStatusCode(err error) int {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrSimulationFailed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrSimulationTimeout), errors.Is(err, ErrRequestClosed), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, context.Canceled), errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
 type IBlockSimRateLimiter interface {
-	send(context context.Context, payload *BuilderBlockValidationRequest, isHighPrio bool) error
+	send(context context.Context, payload *BuilderBlockValidationRequest, isHighPrio bool) (queueDuration time.Duration, err error)
 	currentCounter() int64
 }
 
@@ -47,13 +84,16 @@ func NewBlockSimulationRateLimiter(blockSimURL string) *BlockSimulationRateLimit
 	}
 }
 
-func (b *BlockSimulationRateLimiter) send(context context.Context, payload *BuilderBlockValidationRequest, isHighPrio bool) error {
+// send sends the block to the simulation node, and returns the time spent waiting for a free simulation slot
+func (b *BlockSimulationRateLimiter) send(context context.Context, payload *BuilderBlockValidationRequest, isHighPrio bool) (queueDuration time.Duration, err error) {
+	queuedAt := time.Now()
 	b.cv.L.Lock()
 	cnt := atomic.AddInt64(&b.counter, 1)
 	if maxConcurrentBlocks > 0 && cnt > maxConcurrentBlocks {
 		b.cv.Wait()
 	}
 	b.cv.L.Unlock()
+	queueDuration = time.Since(queuedAt)
 
 	defer func() {
 		b.cv.L.Lock()
@@ -63,18 +103,20 @@ func (b *BlockSimulationRateLimiter) send(context context.Context, payload *Buil
 	}()
 
 	if err := context.Err(); err != nil {
-		return ErrRequestClosed
+		return queueDuration, ErrRequestClosed
 	}
 
 	simReq := jsonrpc.NewJSONRPCRequest("1", "flashbots_validateBuilderSubmissionV1", payload)
-	simResp, err := SendJSONRPCRequest(&b.client, *simReq, b.blockSimURL, isHighPrio)
+	simResp, err := SendJSONRPCRequest(context, &b.client, *simReq, b.blockSimURL, isHighPrio)
 	if err != nil {
-		return err
+		return queueDuration, err
 	} else if simResp.Error != nil {
-		return fmt.Errorf("%w: %s", ErrSimulationFailed, simResp.Error.Message)
+		rawResp, _ := json.Marshal(simResp)
+		detail, _ := json.Marshal(simResp.Error)
+		return queueDuration, &SimulationError{Message: simResp.Error.Message, RawResponse: string(rawResp), Detail: string(detail)}
 	}
 
-	return nil
+	return queueDuration, nil
 }
 
 // currentCounter returns the number of waiting and active requests
@@ -83,13 +125,13 @@ func (b *BlockSimulationRateLimiter) currentCounter() int64 {
 }
 
 // SendJSONRPCRequest sends the request to URL and returns the general JsonRpcResponse, or an error (note: not the JSONRPCError)
-func SendJSONRPCRequest(client *http.Client, req jsonrpc.JSONRPCRequest, url string, isHighPrio bool) (res *jsonrpc.JSONRPCResponse, err error) {
+func SendJSONRPCRequest(ctx context.Context, client *http.Client, req jsonrpc.JSONRPCRequest, url string, isHighPrio bool) (res *jsonrpc.JSONRPCResponse, err error) {
 	buf, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(buf))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/mev-boost-relay/database"
+	uberatomic "go.uber.org/atomic"
+)
+
+// relayMetrics are counters of this instance, exposed via the internal API and saved in the metrics snapshots
+type relayMetrics struct {
+	activeValidatorsDropped uberatomic.Uint64
+	validatorRegsDropped    uberatomic.Uint64
+
+	submissions       uberatomic.Uint64
+	payloadsDelivered uberatomic.Uint64
+	builderDemotions  uberatomic.Uint64
+	signingFailures   uberatomic.Uint64
+	valueAnomalies    uberatomic.Uint64
+}
+
+// valueWindow keeps the values of the most recent accepted submissions
+type valueWindow struct {
+	lock   sync.Mutex
+	values []*big.Int
+	next   int
+}
+
+func newValueWindow(size int) *valueWindow {
+	return &valueWindow{values: make([]*big.Int, 0, size)}
+}
+
+// add replaces the oldest value once the window is full
+func (vw *valueWindow) add(value *big.Int) {
+	vw.lock.Lock()
+	defer vw.lock.Unlock()
+	if len(vw.values) < cap(vw.values) {
+		vw.values = append(vw.values, value)
+		return
+	}
+	vw.values[vw.next] = value
+	vw.next = (vw.next + 1) % len(vw.values)
+}
+
+// median returns the (lower) median of the values in the window, or nil if it is empty
+func (vw *valueWindow) median() *big.Int {
+	vw.lock.Lock()
+	sorted := make([]*big.Int, len(vw.values))
+	copy(sorted, vw.values)
+	vw.lock.Unlock()
+
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	return sorted[(len(sorted)-1)/2]
+}
+
+// isValueAnomaly returns true if the value exceeds the median of the recent values by more than the factor,
+// and adds the value to the recent values.
+func (vw *valueWindow) isValueAnomaly(value *big.Int, factor int64) bool {
+	median := vw.median()
+	vw.add(value)
+	if median == nil || median.Sign() == 0 {
+		return false
+	}
+	return value.Cmp(new(big.Int).Mul(median, big.NewInt(factor))) > 0
+}
+
+// slotWindow keeps the most recent missed slots, in the order they were observed
+type slotWindow struct {
+	lock  sync.Mutex
+	slots []uint64
+	next  int
+}
+
+func newSlotWindow(size int) *slotWindow {
+	return &slotWindow{slots: make([]uint64, 0, size)}
+}
+
+// add replaces the oldest slot once the window is full
+func (sw *slotWindow) add(slot uint64) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if cap(sw.slots) == 0 {
+		return
+	}
+	if len(sw.slots) < cap(sw.slots) {
+		sw.slots = append(sw.slots, slot)
+		return
+	}
+	sw.slots[sw.next] = slot
+	sw.next = (sw.next + 1) % len(sw.slots)
+}
+
+// between returns the sorted slots in the window from slotFrom to slotTo (inclusive), slotTo 0 for no upper bound
+func (sw *slotWindow) between(slotFrom, slotTo uint64) []uint64 {
+	sw.lock.Lock()
+	slots := []uint64{}
+	for _, slot := range sw.slots {
+		if slot >= slotFrom && (slotTo == 0 || slot <= slotTo) {
+			slots = append(slots, slot)
+		}
+	}
+	sw.lock.Unlock()
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots
+}
+
+// simDurationWindows keeps the simulation durations (in microseconds) of the most recent submissions of each builder
+type simDurationWindows struct {
+	lock    sync.Mutex
+	size    int
+	windows map[string]*durationWindow
+}
+
+type durationWindow struct {
+	durations []uint64
+	next      int
+	sum       uint64
+}
+
+func newSimDurationWindows(size int) *simDurationWindows {
+	return &simDurationWindows{size: size, windows: make(map[string]*durationWindow)}
+}
+
+// add adds a simulation duration of the builder, replacing its oldest one once the window is full, and returns
+// the average duration of the window and whether the window is full
+func (sw *simDurationWindows) add(builderPubkey string, duration uint64) (average uint64, full bool) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if sw.size <= 0 {
+		return 0, false
+	}
+	window, ok := sw.windows[builderPubkey]
+	if !ok {
+		window = &durationWindow{durations: make([]uint64, 0, sw.size)}
+		sw.windows[builderPubkey] = window
+	}
+	if len(window.durations) < cap(window.durations) {
+		window.durations = append(window.durations, duration)
+	} else {
+		window.sum -= window.durations[window.next]
+		window.durations[window.next] = duration
+		window.next = (window.next + 1) % len(window.durations)
+	}
+	window.sum += duration
+	return window.sum / uint64(len(window.durations)), len(window.durations) == cap(window.durations)
+}
+
+// averages returns the average simulation duration of each builder
+func (sw *simDurationWindows) averages() map[string]uint64 {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	averages := make(map[string]uint64, len(sw.windows))
+	for builderPubkey, window := range sw.windows {
+		averages[builderPubkey] = window.sum / uint64(len(window.durations))
+	}
+	return averages
+}
+
+// requestDurationBuckets are the upper bounds (in seconds) of the request duration histogram buckets
+var requestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestHistogramKey struct {
+	route  string
+	method string
+	code   int
+}
+
+type requestHistogram struct {
+	buckets []uint64 // count of requests per bucket, the last one for requests above all bounds
+	sum     float64
+	count   uint64
+}
+
+// requestMetrics keeps a histogram of the request durations for each route, method and status code
+type requestMetrics struct {
+	lock       sync.Mutex
+	histograms map[requestHistogramKey]*requestHistogram
+}
+
+func newRequestMetrics() *requestMetrics {
+	return &requestMetrics{histograms: make(map[requestHistogramKey]*requestHistogram)}
+}
+
+func (rm *requestMetrics) observe(route, method string, code int, duration time.Duration) {
+	seconds := duration.Seconds()
+	key := requestHistogramKey{route: route, method: method, code: code}
+
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	histogram, ok := rm.histograms[key]
+	if !ok {
+		histogram = &requestHistogram{buckets: make([]uint64, len(requestDurationBuckets)+1)}
+		rm.histograms[key] = histogram
+	}
+	histogram.buckets[sort.SearchFloat64s(requestDurationBuckets, seconds)]++
+	histogram.sum += seconds
+	histogram.count++
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheus writes the histograms in the Prometheus text exposition format
+func (rm *requestMetrics) writePrometheus(w io.Writer) error {
+	rm.lock.Lock()
+	keys := make([]requestHistogramKey, 0, len(rm.histograms))
+	histograms := make(map[requestHistogramKey]requestHistogram, len(rm.histograms))
+	for key, histogram := range rm.histograms {
+		keys = append(keys, key)
+		buckets := make([]uint64, len(histogram.buckets))
+		copy(buckets, histogram.buckets)
+		histograms[key] = requestHistogram{buckets: buckets, sum: histogram.sum, count: histogram.count}
+	}
+	rm.lock.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+
+	var sb strings.Builder
+	sb.WriteString("# HELP relay_http_request_duration_seconds Duration of the HTTP requests by route, method and status code\n")
+	sb.WriteString("# TYPE relay_http_request_duration_seconds histogram\n")
+	for _, key := range keys {
+		histogram := histograms[key]
+		labels := fmt.Sprintf(`route="%s",method="%s",code="%d"`, prometheusLabelEscaper.Replace(key.route), prometheusLabelEscaper.Replace(key.method), key.code)
+		var cumulative uint64
+		for i, bound := range requestDurationBuckets {
+			cumulative += histogram.buckets[i]
+			fmt.Fprintf(&sb, "relay_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(bound, 'f', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&sb, "relay_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, histogram.count)
+		fmt.Fprintf(&sb, "relay_http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(histogram.sum, 'f', -1, 64))
+		fmt.Fprintf(&sb, "relay_http_request_duration_seconds_count{%s} %d\n", labels, histogram.count)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type RelayMetricsResponse struct {
+	ActiveValidatorChannelDepth int    `json:"active_validator_channel_depth"`
+	ActiveValidatorChannelSize  int    `json:"active_validator_channel_size"`
+	ActiveValidatorsDropped     uint64 `json:"active_validators_dropped"`
+	ValidatorRegChannelDepth    int    `json:"validator_reg_channel_depth"`
+	ValidatorRegChannelSize     int    `json:"validator_reg_channel_size"`
+	ValidatorRegsDropped        uint64 `json:"validator_regs_dropped"`
+
+	Submissions       uint64 `json:"submissions"`
+	PayloadsDelivered uint64 `json:"payloads_delivered"`
+	BuilderDemotions  uint64 `json:"builder_demotions"`
+	SigningFailures   uint64 `json:"signing_failures"`
+	ValueAnomalies    uint64 `json:"value_anomalies"`
+}
+
+func (api *RelayAPI) getMetrics() RelayMetricsResponse {
+	return RelayMetricsResponse{
+		ActiveValidatorChannelDepth: len(api.activeValidatorC),
+		ActiveValidatorChannelSize:  cap(api.activeValidatorC),
+		ActiveValidatorsDropped:     api.metrics.activeValidatorsDropped.Load(),
+		ValidatorRegChannelDepth:    len(api.validatorRegC),
+		ValidatorRegChannelSize:     cap(api.validatorRegC),
+		ValidatorRegsDropped:        api.metrics.validatorRegsDropped.Load(),
+
+		Submissions:       api.metrics.submissions.Load(),
+		PayloadsDelivered: api.metrics.payloadsDelivered.Load(),
+		BuilderDemotions:  api.metrics.builderDemotions.Load(),
+		SigningFailures:   api.metrics.signingFailures.Load(),
+		ValueAnomalies:    api.metrics.valueAnomalies.Load(),
+	}
+}
+
+// startMetricsSnapshotWriter saves a snapshot of the counters to the database on every interval
+func (api *RelayAPI) startMetricsSnapshotWriter(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := api.db.SaveMetricsSnapshot(&database.MetricsSnapshotEntry{
+			NumSubmissions:             api.metrics.submissions.Load(),
+			NumPayloadsDelivered:       api.metrics.payloadsDelivered.Load(),
+			NumBuilderDemotions:        api.metrics.builderDemotions.Load(),
+			NumActiveValidatorsDropped: api.metrics.activeValidatorsDropped.Load(),
+			NumValidatorRegsDropped:    api.metrics.validatorRegsDropped.Load(),
+		})
+		if err != nil {
+			api.log.WithError(err).Error("failed to save metrics snapshot")
+		}
+	}
+}
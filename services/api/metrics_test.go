@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendValidatorRegistration(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.validatorRegC = make(chan types.SignedValidatorRegistration, 1)
+
+	// First registration fits, the second one is dropped.
+	require.True(t, backend.relay.sendValidatorRegistration(common.ValidPayloadRegisterValidator))
+	require.False(t, backend.relay.sendValidatorRegistration(common.ValidPayloadRegisterValidator))
+
+	// With a timeout, the registration is queued once there is space in the channel.
+	validatorRegChannelTimeoutMs = 1000
+	defer func() { validatorRegChannelTimeoutMs = 0 }()
+	go func() { <-backend.relay.validatorRegC }()
+	require.True(t, backend.relay.sendValidatorRegistration(common.ValidPayloadRegisterValidator))
+
+	rr := backend.request(http.MethodGet, pathInternalMetrics, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := RelayMetricsResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), resp.ValidatorRegsDropped)
+	require.Equal(t, 1, resp.ValidatorRegChannelDepth)
+	require.Equal(t, 1, resp.ValidatorRegChannelSize)
+	require.Equal(t, uint64(0), resp.ActiveValidatorsDropped)
+}
+
+func TestMetricsSnapshotWriter(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	snapshots := make(chan *database.MetricsSnapshotEntry, 10)
+	backend.relay.db = database.MockDB{MetricsSnapshots: snapshots}
+
+	backend.relay.metrics.submissions.Add(3)
+	backend.relay.metrics.payloadsDelivered.Inc()
+	go backend.relay.startMetricsSnapshotWriter(10 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case snapshot := <-snapshots:
+			require.Equal(t, uint64(3), snapshot.NumSubmissions)
+			require.Equal(t, uint64(1), snapshot.NumPayloadsDelivered)
+			require.Equal(t, uint64(0), snapshot.NumBuilderDemotions)
+		case <-time.After(time.Second):
+			t.Fatal("no metrics snapshot written")
+		}
+	}
+}
+
+func TestValueWindow(t *testing.T) {
+	vw := newValueWindow(3)
+	require.Nil(t, vw.median())
+
+	// Values are flagged against the median of the previous values only
+	require.False(t, vw.isValueAnomaly(big.NewInt(100), 10))
+	require.False(t, vw.isValueAnomaly(big.NewInt(300), 10))
+	require.False(t, vw.isValueAnomaly(big.NewInt(200), 10))
+	require.Equal(t, big.NewInt(200), vw.median())
+	require.False(t, vw.isValueAnomaly(big.NewInt(2000), 10))
+	require.True(t, vw.isValueAnomaly(big.NewInt(3001), 10))
+
+	// The oldest values were replaced
+	require.Equal(t, big.NewInt(2000), vw.median())
+}
+
+func TestSlotWindow(t *testing.T) {
+	sw := newSlotWindow(3)
+	require.Equal(t, []uint64{}, sw.between(0, 0))
+
+	for _, slot := range []uint64{5, 3, 8, 9} {
+		sw.add(slot)
+	}
+
+	// The oldest slot was replaced
+	require.Equal(t, []uint64{3, 8, 9}, sw.between(0, 0))
+	require.Equal(t, []uint64{8}, sw.between(4, 8))
+	require.Equal(t, []uint64{9}, sw.between(9, 0))
+}
+
+func TestSimDurationWindows(t *testing.T) {
+	sw := newSimDurationWindows(3)
+	require.Equal(t, map[string]uint64{}, sw.averages())
+
+	average, full := sw.add("0x01", 10)
+	require.Equal(t, uint64(10), average)
+	require.False(t, full)
+	sw.add("0x01", 20)
+	average, full = sw.add("0x01", 30)
+	require.Equal(t, uint64(20), average)
+	require.True(t, full)
+
+	// The oldest duration was replaced
+	average, full = sw.add("0x01", 60)
+	require.Equal(t, uint64(36), average)
+	require.True(t, full)
+
+	sw.add("0x02", 5)
+	require.Equal(t, map[string]uint64{"0x01": 36, "0x02": 5}, sw.averages())
+}
+
+func TestRequestMetrics(t *testing.T) {
+	backend := newTestBackend(t, 1)
+
+	rr := backend.request(http.MethodGet, pathStatus, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = backend.request(http.MethodGet, "/internal/v1/builder/0x1234", nil)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	rr = backend.request(http.MethodGet, pathInternalPrometheusMetrics, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	require.Contains(t, body, "# TYPE relay_http_request_duration_seconds histogram\n")
+	require.Contains(t, body, `relay_http_request_duration_seconds_bucket{route="/eth/v1/builder/status",method="GET",code="200",le="+Inf"} 1`)
+	require.Contains(t, body, `relay_http_request_duration_seconds_count{route="/internal/v1/builder/{pubkey:0x[a-fA-F0-9]+}",method="GET",code="500"} 1`)
+}
+
+func TestRequestMetricsBuckets(t *testing.T) {
+	rm := newRequestMetrics()
+	rm.observe("/a", http.MethodGet, http.StatusOK, 5*time.Millisecond)
+	rm.observe("/a", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+	rm.observe("/a", http.MethodGet, http.StatusOK, 20*time.Second)
+
+	var sb strings.Builder
+	require.NoError(t, rm.writePrometheus(&sb))
+	body := sb.String()
+	labels := `route="/a",method="GET",code="200"`
+	require.Contains(t, body, `relay_http_request_duration_seconds_bucket{`+labels+`,le="0.005"} 1`)
+	require.Contains(t, body, `relay_http_request_duration_seconds_bucket{`+labels+`,le="0.025"} 1`)
+	require.Contains(t, body, `relay_http_request_duration_seconds_bucket{`+labels+`,le="0.05"} 2`)
+	require.Contains(t, body, `relay_http_request_duration_seconds_bucket{`+labels+`,le="10"} 2`)
+	require.Contains(t, body, `relay_http_request_duration_seconds_bucket{`+labels+`,le="+Inf"} 3`)
+	require.Contains(t, body, `relay_http_request_duration_seconds_count{`+labels+`} 3`)
+}
+
+func TestBuilderApiSubmitNewBlockValueAnomaly(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.recentValues = newValueWindow(10)
+	valueAnomalyFactor = 10
+	defer func() { valueAnomalyFactor = 0 }()
+	backend.relay.recentValues.add(big.NewInt(10))
+
+	// The anomaly is flagged, but the submission is still accepted
+	rr := runOptimisticBlockSubmission(t, blockRequestOpts{
+		secretkey:  secretkey,
+		pubkey:     *pubkey,
+		blockValue: collateral + 1,
+		domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+	}, nil, backend)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Equal(t, uint64(1), backend.relay.metrics.valueAnomalies.Load())
+}
+
+func TestValueAnomalyWindowValidation(t *testing.T) {
+	opts := newTestBackend(t, 1).relay.opts
+	valueAnomalyFactor = 10
+	defer func() {
+		valueAnomalyFactor = 0
+		valueAnomalyWindow = 1000
+	}()
+
+	for _, window := range []int{0, -1} {
+		valueAnomalyWindow = window
+		_, err := NewRelayAPI(opts)
+		require.ErrorIs(t, err, ErrInvalidValueAnomalyWindow)
+	}
+
+	valueAnomalyWindow = 1
+	relay, err := NewRelayAPI(opts)
+	require.NoError(t, err)
+	require.False(t, relay.recentValues.isValueAnomaly(big.NewInt(10), 10))
+}
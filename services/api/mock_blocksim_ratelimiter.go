@@ -2,14 +2,21 @@ package api
 
 import (
 	"context"
+	"time"
 )
 
 type MockBlockSimulationRateLimiter struct {
 	simulationError error
+	// hang until the context is done, like an unresponsive simulation node
+	hang bool
 }
 
-func (m *MockBlockSimulationRateLimiter) send(context context.Context, payload *BuilderBlockValidationRequest, isHighPrio bool) error {
-	return m.simulationError
+func (m *MockBlockSimulationRateLimiter) send(context context.Context, payload *BuilderBlockValidationRequest, isHighPrio bool) (time.Duration, error) {
+	if m.hang {
+		<-context.Done()
+		return 0, context.Err()
+	}
+	return 0, m.simulationError
 }
 
 func (m *MockBlockSimulationRateLimiter) currentCounter() int64 {
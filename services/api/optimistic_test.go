@@ -1,6 +1,8 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -18,8 +20,10 @@ import (
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
 	"github.com/flashbots/mev-boost-relay/datastore"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 	blst "github.com/supranational/blst/bindings/go"
+	uberatomic "go.uber.org/atomic"
 )
 
 const (
@@ -32,7 +36,7 @@ const (
 
 var (
 	feeRecipient = types.Address{0x02}
-	errFake      = fmt.Errorf("foo error")
+	errFake      = &SimulationError{Message: "foo error"}
 )
 
 func getTestBlockHash(t *testing.T) types.Hash {
@@ -156,6 +160,9 @@ func startTestBackend(t *testing.T) (*types.PublicKey, *blst.SecretKey, *testBac
 	go backend.relay.StartServer()
 	time.Sleep(100 * time.Millisecond)
 
+	// The mock beacon node reports head slot 1, the test requests are for the next slot
+	backend.relay.headSlot.Store(slot - 1)
+
 	return &pubkey, sk, backend
 }
 
@@ -228,7 +235,7 @@ func TestSimulateBlock(t *testing.T) {
 			backend.relay.blockSimRateLimiter = &MockBlockSimulationRateLimiter{
 				simulationError: tc.simulationError,
 			}
-			err := backend.relay.simulateBlock(blockSimOptions{
+			_, err := backend.relay.simulateBlock(blockSimOptions{
 				ctx:        context.Background(),
 				isHighPrio: true,
 				log:        backend.relay.log,
@@ -299,6 +306,36 @@ func TestProcessOptimisticBlock(t *testing.T) {
 	}
 }
 
+func TestSimulateBlockTimeout(t *testing.T) {
+	blockSimTimeoutMs = 10
+	defer func() { blockSimTimeoutMs = 0 }()
+
+	pubkey, secretkey, backend := startTestBackend(t)
+	pkStr := pubkey.String()
+	backend.relay.blockSimRateLimiter = &MockBlockSimulationRateLimiter{hang: true}
+
+	// Optimistic: the bid is already eligible without being validated, so the builder is demoted
+	backend.relay.processOptimisticBlock(blockSimOptions{
+		ctx:        context.Background(),
+		isHighPrio: true,
+		log:        backend.relay.log,
+		req: &BuilderBlockValidationRequest{
+			BuilderSubmitBlockRequest: common.TestBuilderSubmitBlockRequest(
+				pubkey, secretkey, getTestBidTrace(*pubkey, collateral)),
+		},
+	})
+	builder, err := backend.relay.db.GetBlockBuilderByPubkey(pkStr)
+	require.NoError(t, err)
+	require.True(t, builder.IsDemoted)
+	require.True(t, backend.relay.db.(*database.MockDB).Demotions[pkStr])
+
+	// Synchronous: the builder should retry
+	req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code, rr.Body.String())
+	require.Contains(t, rr.Body.String(), ErrSimulationTimeout.Error())
+}
+
 func TestDemoteBuilder(t *testing.T) {
 	wantStatus := common.BuilderStatus{
 		IsDemoted:  true,
@@ -320,6 +357,46 @@ func TestDemoteBuilder(t *testing.T) {
 	require.True(t, mockDB.Demotions[pkStr])
 }
 
+func TestDemoteBuilderSimErrorResponse(t *testing.T) {
+	simNodeResponse := `{"jsonrpc":"2.0","id":"1","error":{"code":-32000,"message":"incorrect gas limit"}}`
+	simNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(simNodeResponse))
+	}))
+	defer simNode.Close()
+
+	pubkey, secretkey, backend := startTestBackend(t)
+	pkStr := pubkey.String()
+	backend.relay.ffStoreSimErrorResponse = true
+	mockDB := backend.relay.db.(*database.MockDB)
+	mockDB.DemotionEntries = map[string]*database.BuilderDemotionEntry{}
+
+	// The simulation error keeps the full response of the sim node
+	req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	_, simErr := NewBlockSimulationRateLimiter(simNode.URL).send(context.Background(), &BuilderBlockValidationRequest{BuilderSubmitBlockRequest: req}, true)
+	require.ErrorIs(t, simErr, ErrSimulationFailed)
+	require.Equal(t, "simulation failed: incorrect gas limit", simErr.Error())
+
+	backend.relay.demoteBuilder(pkStr, &req, simErr)
+	entry := mockDB.DemotionEntries[pkStr]
+	require.Equal(t, simErr.Error(), entry.SubmitBlockSimError)
+	require.JSONEq(t, simNodeResponse, entry.SubmitBlockSimErrorResponse)
+
+	// Served by the demotion details endpoint
+	path := fmt.Sprintf("/internal/v1/demotion/%d/%s/%s", slot, pkStr, req.Message.BlockHash.String())
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	resp := BuilderDemotionResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, entry.SubmitBlockSimErrorResponse, resp.SimErrorResponse)
+
+	// Stored responses are bounded
+	maxSimErrorResponseBytes = 10
+	defer func() { maxSimErrorResponseBytes = 4096 }()
+	backend.relay.demoteBuilder(pkStr, &req, simErr)
+	require.Equal(t, entry.SubmitBlockSimErrorResponse[:10], mockDB.DemotionEntries[pkStr].SubmitBlockSimErrorResponse)
+}
+
 func TestUpdateOptimisticSlot(t *testing.T) {
 	pubkey, _, backend := startTestBackend(t)
 	pkStr := pubkey.String()
@@ -334,6 +411,56 @@ func TestUpdateOptimisticSlot(t *testing.T) {
 	require.Equal(t, types.IntToU256(uint64(collateral)), entry.collateral)
 }
 
+func TestUpdateOptimisticSlotDemotionExpiry(t *testing.T) {
+	pubkey, _, backend := startTestBackend(t)
+	pkStr := pubkey.String()
+	mockDB := backend.relay.db.(*database.MockDB)
+	err := mockDB.SetBlockBuilderStatus(pkStr, common.BuilderStatus{IsHighPrio: true, IsDemoted: true})
+	require.NoError(t, err)
+	require.True(t, mockDB.Builders[pkStr].DemotedAt.Valid)
+
+	// Disabled by default
+	mockDB.Builders[pkStr].DemotedAt.Time = time.Now().Add(-time.Hour)
+	backend.relay.updateOptimisticSlot(slot - 1)
+	require.True(t, backend.relay.blockBuildersCache[pkStr].status.IsDemoted)
+
+	demotionExpirySlots = 10
+	defer func() { demotionExpirySlots = 0 }()
+
+	// Not expired yet
+	mockDB.Builders[pkStr].DemotedAt.Time = time.Now().Add(-9 * common.DurationPerSlot)
+	backend.relay.updateOptimisticSlot(slot - 1)
+	require.True(t, backend.relay.blockBuildersCache[pkStr].status.IsDemoted)
+
+	// Expired
+	mockDB.Builders[pkStr].DemotedAt.Time = time.Now().Add(-11 * common.DurationPerSlot)
+	backend.relay.updateOptimisticSlot(slot - 1)
+	require.False(t, backend.relay.blockBuildersCache[pkStr].status.IsDemoted)
+	require.True(t, backend.relay.blockBuildersCache[pkStr].status.IsHighPrio)
+	require.False(t, mockDB.Builders[pkStr].IsDemoted)
+	require.False(t, mockDB.Builders[pkStr].DemotedAt.Valid)
+}
+
+func TestUpdateOptimisticSlotMaxCollateral(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	pkStr := pubkey.String()
+	maxCollateral := types.IntToU256(uint64(collateral) - 10)
+	backend.relay.maxCollateral = &maxCollateral
+
+	// The DB collateral is clamped to the cap.
+	backend.relay.updateOptimisticSlot(slot - 1)
+	require.Equal(t, maxCollateral, backend.relay.blockBuildersCache[pkStr].collateral)
+
+	// A block value between the cap and the DB collateral is simulated synchronously.
+	rr := runOptimisticBlockSubmission(t, blockRequestOpts{
+		secretkey:  secretkey,
+		pubkey:     *pubkey,
+		blockValue: collateral - 5,
+		domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+	}, errFake, backend)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
 func TestProposerApiGetPayloadOptimistic(t *testing.T) {
 	testCases := []struct {
 		description string
@@ -368,7 +495,7 @@ func TestProposerApiGetPayloadOptimistic(t *testing.T) {
 					Message: &types.BidTrace{
 						BuilderPubkey: *pubkey,
 					},
-				}, errFake)
+				}, errFake, "")
 			}
 
 			runOptimisticGetPayload(t, blockRequestOpts{
@@ -435,7 +562,7 @@ func TestBuilderApiSubmitNewBlockOptimistic(t *testing.T) {
 			},
 			simulationError: errFake,
 			expectDemotion:  false,
-			httpCode:        400, // failure (in pessimistic mode, block sim failure happens in response path)
+			httpCode:        422, // failure (in pessimistic mode, block sim failure happens in response path)
 			blockValue:      collateral + 1,
 		},
 	}
@@ -508,4 +635,1472 @@ func TestInternalBuilderCollateral(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, resp.CollateralID, "builder0x69")
 	require.Equal(t, resp.CollateralValue, "10000")
+
+	// Invalid values are rejected and don't overwrite the stored collateral.
+	for _, value := range []string{"", "abc", "-1", "1.5"} {
+		rr = backend.request(http.MethodPost, path+"?collateral_id=builder0x69&value="+value, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, value)
+	}
+	builder, err := backend.relay.db.GetBlockBuilderByPubkey(pubkey.String())
+	require.NoError(t, err)
+	require.Equal(t, "10000", builder.CollateralValue)
+}
+
+func TestInternalBuilderRefresh(t *testing.T) {
+	pubkey, _, backend := startTestBackend(t)
+	pkStr := pubkey.String()
+
+	// Status and collateral changes only reach the cache once refreshed
+	rr := backend.request(http.MethodPost, "/internal/v1/builder/"+pkStr+"?blacklisted=true", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = backend.request(http.MethodPost, "/internal/v1/builder/collateral/"+pkStr+"?collateral_id=builder0x69&value=5", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.False(t, backend.relay.blockBuildersCache[pkStr].status.IsBlacklisted)
+
+	rr = backend.request(http.MethodPost, "/internal/v1/builder/"+pkStr+"/refresh", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.True(t, backend.relay.blockBuildersCache[pkStr].status.IsBlacklisted)
+	require.Equal(t, "5", backend.relay.blockBuildersCache[pkStr].collateral.String())
+
+	rr = backend.request(http.MethodPost, "/internal/v1/builder/0x1234/refresh", nil)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestRecordSimDurationSlowBuilder(t *testing.T) {
+	pubkey, _, backend := startTestBackend(t)
+	pkStr := pubkey.String()
+	backend.relay.simDurations = newSimDurationWindows(2)
+	slowSimThresholdMs = 100
+	defer func() { slowSimThresholdMs = 0 }()
+
+	// The builder stays high-prio until the window is full
+	builderEntry, _ := backend.relay.getBlockBuilderCacheEntry(pkStr)
+	backend.relay.recordSimDuration(pkStr, builderEntry, 300_000)
+	builderEntry, _ = backend.relay.getBlockBuilderCacheEntry(pkStr)
+	require.True(t, builderEntry.status.IsHighPrio)
+
+	// The builder was demoted in the meantime, which the cached entry doesn't reflect yet
+	mockDB, ok := backend.relay.db.(*database.MockDB)
+	require.True(t, ok)
+	mockDB.Builders[pkStr].IsDemoted = true
+	otherPkStr := types.PublicKey{0x01}.String()
+	mockDB.Builders[otherPkStr] = &database.BlockBuilderEntry{BuilderPubkey: otherPkStr, IsHighPrio: true, CollateralID: collateralID}
+
+	// No second downgrade while one is in flight
+	backend.relay.slowBuilderDowngrades[pkStr] = true
+	backend.relay.recordSimDuration(pkStr, builderEntry, 300_000)
+	time.Sleep(50 * time.Millisecond)
+	require.True(t, mockDB.Builders[pkStr].IsHighPrio)
+	delete(backend.relay.slowBuilderDowngrades, pkStr)
+
+	backend.relay.recordSimDuration(pkStr, builderEntry, 200_000)
+	require.Eventually(t, func() bool {
+		builderEntry, _ := backend.relay.getBlockBuilderCacheEntry(pkStr)
+		return !builderEntry.status.IsHighPrio
+	}, time.Second, 10*time.Millisecond)
+	require.False(t, mockDB.Builders[pkStr].IsHighPrio)
+	builderEntry, _ = backend.relay.getBlockBuilderCacheEntry(pkStr)
+	require.True(t, builderEntry.status.IsDemoted)
+	require.True(t, mockDB.Builders[pkStr].IsDemoted)
+	require.True(t, mockDB.Builders[otherPkStr].IsHighPrio)
+
+	rr := backend.request(http.MethodGet, "/internal/v1/stats/simulation", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := []SimulationStatsEntry{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, []SimulationStatsEntry{{BuilderPubkey: pkStr, AvgSimulationUs: 250_000}}, resp)
+}
+
+func TestBuilderApiSubmitNewBlockSlotBlocklist(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.slotBlocklist[slot] = true
+
+	rr := runOptimisticBlockSubmission(t, blockRequestOpts{
+		secretkey:  secretkey,
+		pubkey:     *pubkey,
+		blockValue: 10,
+		domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+	}, nil, backend)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "bids for slot 41 are disabled")
+}
+
+func TestBuilderApiSubmitNewBlockFeeRecipientAllowlist(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	proposerPubkey := types.PublicKey{}.String()
+	opts := blockRequestOpts{
+		secretkey:  secretkey,
+		pubkey:     *pubkey,
+		blockValue: 10,
+		domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+	}
+
+	// The fee recipient of the slot duty is not in the allowlist of the proposer
+	backend.relay.feeRecipientAllowlists[proposerPubkey] = map[string]bool{"0x0000000000000000000000000000000000000001": true}
+	rr := runOptimisticBlockSubmission(t, opts, nil, backend)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "fee recipient is not allowed for the proposer")
+
+	backend.relay.feeRecipientAllowlists[proposerPubkey][feeRecipient.String()] = true
+	rr = runOptimisticBlockSubmission(t, opts, nil, backend)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestInternalOptimisticEnabled(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.optimisticSlot = slot
+	pkStr := pubkey.String()
+	path := "/internal/v1/optimistic/enabled"
+	require.True(t, backend.relay.optimisticEnabled.Load())
+
+	rr := backend.request(http.MethodPost, path+"?value=foo", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Disable optimistic processing.
+	rr = backend.request(http.MethodPost, path+"?value=false", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.False(t, backend.relay.optimisticEnabled.Load())
+	value, err := backend.relay.redis.GetRelayConfig(datastore.RedisConfigFieldOptimisticEnabled)
+	require.NoError(t, err)
+	require.Equal(t, "false", value)
+
+	// Submission is simulated synchronously, so a sim error fails it without demotion.
+	rr = runOptimisticBlockSubmission(t, blockRequestOpts{
+		secretkey:  secretkey,
+		pubkey:     *pubkey,
+		blockValue: collateral - 1,
+		domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+	}, errFake, backend)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	mockDB := backend.relay.db.(*database.MockDB)
+	require.False(t, mockDB.Demotions[pkStr])
+
+	// Re-enabling via redis (e.g. by another instance) is picked up on the next slot.
+	err = backend.relay.redis.SetRelayConfig(datastore.RedisConfigFieldOptimisticEnabled, "true")
+	require.NoError(t, err)
+	backend.relay.updateOptimisticSlot(slot - 1)
+	require.True(t, backend.relay.optimisticEnabled.Load())
+}
+
+func TestBuilderApiSubmitNewBlockRecheckDemotion(t *testing.T) {
+	testCases := []struct {
+		description          string
+		recheckDemotion      bool
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "stale_cache_optimistic",
+			recheckDemotion:      false,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "recheck_forces_synchronous",
+			recheckDemotion:      true,
+			expectedHTTPResponse: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.optimisticSlot = slot
+			backend.relay.ffRecheckDemotion = tc.recheckDemotion
+
+			// Demote the builder in the DB mid-slot, the cache still has the builder as not demoted with sufficient collateral.
+			err := backend.relay.db.SetBlockBuilderStatus(pubkey.String(), common.BuilderStatus{IsHighPrio: true, IsDemoted: true})
+			require.NoError(t, err)
+			require.False(t, backend.relay.blockBuildersCache[pubkey.String()].status.IsDemoted)
+
+			// A synchronous simulation surfaces the simulation error, an optimistic one returns 200.
+			rr := runOptimisticBlockSubmission(t, blockRequestOpts{
+				secretkey:  secretkey,
+				pubkey:     *pubkey,
+				blockValue: collateral - 1,
+				domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+			}, errFake, backend)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code)
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockVerifyBlockHashOptimistic(t *testing.T) {
+	testCases := []struct {
+		description          string
+		verifyBlockHash      bool
+		blockValue           uint64
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "optimistic_not_verified",
+			verifyBlockHash:      false,
+			blockValue:           collateral - 1,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "optimistic_forged_block_hash",
+			verifyBlockHash:      true,
+			blockValue:           collateral - 1,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "synchronous_not_verified",
+			verifyBlockHash:      true,
+			blockValue:           collateral + 1,
+			expectedHTTPResponse: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.optimisticSlot = slot
+			backend.relay.ffVerifyBlockHashOptimistic = tc.verifyBlockHash
+
+			// The test payload's block hash doesn't match its contents
+			rr := runOptimisticBlockSubmission(t, blockRequestOpts{
+				secretkey:  secretkey,
+				pubkey:     *pubkey,
+				blockValue: tc.blockValue,
+				domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+			}, nil, backend)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+
+			// A rejected submission never becomes eligible
+			bid, err := backend.relay.redis.GetBestBid(slot, types.Hash{}.String(), types.PublicKey{}.String())
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedHTTPResponse == http.StatusOK, bid != nil)
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockCancellations(t *testing.T) {
+	testCases := []struct {
+		description           string
+		explicitCancellations bool
+		query                 string
+		expectedHTTPResponse  int
+		expectedTopBid        string
+	}{
+		{
+			description:           "implicit_cancellation",
+			explicitCancellations: false,
+			query:                 "",
+			expectedHTTPResponse:  http.StatusOK,
+			expectedTopBid:        "1000",
+		},
+		{
+			description:           "explicit_without_flag",
+			explicitCancellations: true,
+			query:                 "",
+			expectedHTTPResponse:  http.StatusBadRequest,
+			expectedTopBid:        "1010",
+		},
+		{
+			description:           "explicit_with_flag",
+			explicitCancellations: true,
+			query:                 "?cancellations=1",
+			expectedHTTPResponse:  http.StatusOK,
+			expectedTopBid:        "1000",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.ffExplicitCancellations = tc.explicitCancellations
+
+			// The builder owns the current top bid
+			parentHash := types.Hash{}.String()
+			proposerPubkey := types.PublicKey{}.String()
+			topBid := &types.GetHeaderResponse{
+				Data: &types.SignedBuilderBid{
+					Message: &types.BuilderBid{
+						Header: &types.ExecutionPayloadHeader{},
+						Value:  types.IntToU256(collateral + 10),
+					},
+				},
+			}
+			err := backend.relay.redis.SaveLatestBuilderBid(slot, pubkey.String(), parentHash, proposerPubkey, time.Now().Add(-time.Second), topBid)
+			require.NoError(t, err)
+			err = backend.relay.redis.UpdateTopBid(slot, parentHash, proposerPubkey, datastore.TieBreakFirstSeen, nil)
+			require.NoError(t, err)
+
+			// Submit a lower bid, which is simulated synchronously
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock+tc.query, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+
+			bid, err := backend.relay.redis.GetBestBid(slot, parentHash, proposerPubkey)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedTopBid, bid.Data.Message.Value.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockSigningUnavailable(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.signer = nil
+
+	req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Contains(t, rr.Body.String(), ErrMissingSigner.Error())
+	require.Equal(t, uint64(1), backend.relay.getMetrics().SigningFailures)
+}
+
+func TestBuilderApiSubmitNewBlockDuplicate(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	// Value above the collateral, so all submissions are simulated synchronously.
+	opts := blockRequestOpts{
+		secretkey:  secretkey,
+		pubkey:     *pubkey,
+		blockValue: collateral + 1,
+		domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+	}
+
+	// A failed simulation does not mark the block as seen, so it can be retried.
+	rr := runOptimisticBlockSubmission(t, opts, errFake, backend)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	rr = runOptimisticBlockSubmission(t, opts, nil, backend)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, rr.Body.String(), "duplicate")
+
+	// The exact same block is skipped without simulation.
+	rr = runOptimisticBlockSubmission(t, opts, errFake, backend)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "duplicate")
+
+	// A resubmission while the first submission of a block is still being processed is not answered as accepted.
+	bidTrace := getTestBidTrace(*pubkey, collateral+2)
+	bidTrace.BlockHash = types.Hash{0x02}
+	seen, _ := backend.relay.markSubmissionInFlight(fmt.Sprintf("%d_%s_%s", slot, pubkey.String(), bidTrace.BlockHash.String()))
+	require.False(t, seen)
+	req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, bidTrace)
+	rr = backend.request(http.MethodPost, pathSubmitNewBlock, req)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.NotContains(t, rr.Body.String(), "duplicate")
+
+	// A different block with a higher value is processed.
+	bidTrace = getTestBidTrace(*pubkey, collateral+2)
+	bidTrace.BlockHash = types.Hash{0x01}
+	req = common.TestBuilderSubmitBlockRequest(pubkey, secretkey, bidTrace)
+	rr = backend.request(http.MethodPost, pathSubmitNewBlock, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), errFake.Error())
+
+	// The next head slot clears the seen submissions (without reloading the proposer duties in the background).
+	backend.relay.opts.BlockBuilderAPI = false
+	backend.relay.opts.ProposerAPI = false
+	backend.relay.headSlot.Store(slot - 2)
+	backend.relay.processNewSlot(slot - 1)
+	backend.relay.opts.BlockBuilderAPI = true
+	backend.relay.opts.ProposerAPI = true
+	rr = runOptimisticBlockSubmission(t, opts, errFake, backend)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Contains(t, rr.Body.String(), errFake.Error())
+}
+
+func TestBuilderApiSubmitNewBlockDuplicateAfterRedisError(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	opts := blockRequestOpts{
+		secretkey:  secretkey,
+		pubkey:     *pubkey,
+		blockValue: collateral + 1,
+		domain:     backend.relay.opts.EthNetDetails.DomainBuilder,
+	}
+	redisTestServer, err := miniredis.Run()
+	require.NoError(t, err)
+	backend.relay.redis, err = datastore.NewRedisCache(redisTestServer.Addr(), "")
+	require.NoError(t, err)
+
+	// A failed redis write does not mark the block as seen, so the retry is processed.
+	redisTestServer.SetError("redis unavailable")
+	rr := runOptimisticBlockSubmission(t, opts, nil, backend)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	redisTestServer.SetError("")
+	rr = runOptimisticBlockSubmission(t, opts, nil, backend)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, rr.Body.String(), "duplicate")
+
+	rr = runOptimisticBlockSubmission(t, opts, nil, backend)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "duplicate")
+}
+
+func TestBuilderApiSubmitNewBlockHeadSlotLag(t *testing.T) {
+	testCases := []struct {
+		description          string
+		maxHeadSlotLag       int
+		headSlot             uint64
+		noSlotDuty           bool
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "disabled",
+			maxHeadSlotLag:       -1,
+			headSlot:             slot - 5,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "synced",
+			maxHeadSlotLag:       0,
+			headSlot:             slot - 1,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "behind_within_lag",
+			maxHeadSlotLag:       2,
+			headSlot:             slot - 3,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "behind",
+			maxHeadSlotLag:       2,
+			headSlot:             slot - 4,
+			expectedHTTPResponse: http.StatusServiceUnavailable,
+		},
+		{
+			description:          "behind_without_duty",
+			maxHeadSlotLag:       2,
+			headSlot:             slot - 4,
+			noSlotDuty:           true,
+			expectedHTTPResponse: http.StatusServiceUnavailable,
+		},
+		{
+			description:          "synced_without_duty",
+			maxHeadSlotLag:       2,
+			headSlot:             slot - 1,
+			noSlotDuty:           true,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.headSlot.Store(tc.headSlot)
+			if tc.noSlotDuty {
+				backend.relay.proposerDutiesLock.Lock()
+				delete(backend.relay.proposerDutiesMap, slot)
+				backend.relay.proposerDutiesLock.Unlock()
+			}
+			submissionMaxHeadSlotLag = tc.maxHeadSlotLag
+			defer func() { submissionMaxHeadSlotLag = -1 }()
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockRejectionCodes(t *testing.T) {
+	testCases := []struct {
+		description          string
+		blockValue           uint64
+		setup                func(backend *testBackend, pkStr string)
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "zero_value",
+			blockValue:           0,
+			setup:                func(backend *testBackend, pkStr string) {},
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description: "blacklisted",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				backend.relay.blockBuildersCache[pkStr].status.IsBlacklisted = true
+			},
+			expectedHTTPResponse: http.StatusForbidden,
+		},
+		{
+			description: "low_prio",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				backend.relay.blockBuildersCache[pkStr].status.IsHighPrio = false
+				backend.relay.ffDisableLowPrioBuilders.Store(true)
+			},
+			expectedHTTPResponse: http.StatusForbidden,
+		},
+		{
+			description: "slot_delivered",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				err := backend.relay.redis.SetStats(datastore.RedisStatsFieldSlotLastPayloadDelivered, slot)
+				require.NoError(t, err)
+			},
+			expectedHTTPResponse: http.StatusConflict,
+		},
+		{
+			description: "newer_payload",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				bid := &types.GetHeaderResponse{
+					Data: &types.SignedBuilderBid{
+						Message: &types.BuilderBid{
+							Header: &types.ExecutionPayloadHeader{},
+							Value:  types.IntToU256(1),
+						},
+					},
+				}
+				err := backend.relay.redis.SaveLatestBuilderBid(slot, pkStr, types.Hash{}.String(), types.PublicKey{}.String(), time.Now().Add(time.Minute), bid)
+				require.NoError(t, err)
+			},
+			expectedHTTPResponse: http.StatusConflict,
+		},
+		{
+			description: "simulation_failed",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				backend.relay.blockSimRateLimiter = &MockBlockSimulationRateLimiter{simulationError: errFake}
+			},
+			expectedHTTPResponse: http.StatusUnprocessableEntity,
+		},
+		{
+			description: "simulation_request_closed",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				backend.relay.blockSimRateLimiter = &MockBlockSimulationRateLimiter{simulationError: ErrRequestClosed}
+			},
+			expectedHTTPResponse: http.StatusServiceUnavailable,
+		},
+		{
+			description: "simulation_node_unreachable",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				simNode := httptest.NewServer(http.NotFoundHandler())
+				simNode.Close()
+				backend.relay.blockSimRateLimiter = NewBlockSimulationRateLimiter(simNode.URL)
+			},
+			expectedHTTPResponse: http.StatusBadGateway,
+		},
+		{
+			description: "simulation_client_timeout",
+			blockValue:  collateral,
+			setup: func(backend *testBackend, pkStr string) {
+				simNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(100 * time.Millisecond)
+				}))
+				t.Cleanup(simNode.Close)
+				rateLimiter := NewBlockSimulationRateLimiter(simNode.URL)
+				rateLimiter.client.Timeout = 10 * time.Millisecond
+				backend.relay.blockSimRateLimiter = rateLimiter
+			},
+			expectedHTTPResponse: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			tc.setup(backend, pubkey.String())
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, tc.blockValue))
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockMinimums(t *testing.T) {
+	testCases := []struct {
+		description          string
+		minGasUsed           uint64
+		minTxCount           int
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "defaults",
+			minGasUsed:           0,
+			minTxCount:           1,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "too_few_txs",
+			minGasUsed:           0,
+			minTxCount:           2,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "too_little_gas",
+			minGasUsed:           21000,
+			minTxCount:           1,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			minBlockGasUsed = tc.minGasUsed
+			minBlockTxCount = tc.minTxCount
+			defer func() {
+				minBlockGasUsed = 0
+				minBlockTxCount = 1
+			}()
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockPerSlotMax(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	maxBuilderSubmissionsPerSlot = 2
+	defer func() { maxBuilderSubmissionsPerSlot = 0 }()
+
+	for i, expectedHTTPResponse := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		bidTrace := getTestBidTrace(*pubkey, collateral+uint64(i))
+		bidTrace.BlockHash = types.Hash{byte(i + 1)}
+		req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, bidTrace)
+		rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+		require.Equal(t, expectedHTTPResponse, rr.Code, rr.Body.String())
+	}
+
+	// The count is per slot
+	count, err := backend.relay.redis.IncrBuilderSubmissionCount(slot+1, pubkey.String())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+}
+
+func TestBuilderApiSubmitNewBlockAcceptAfter(t *testing.T) {
+	testCases := []struct {
+		description          string
+		acceptAfterMs        int
+		slotStartedAgo       time.Duration
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "disabled",
+			acceptAfterMs:        0,
+			slotStartedAgo:       0,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "after_offset",
+			acceptAfterMs:        1000,
+			slotStartedAgo:       time.Minute,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "before_offset",
+			acceptAfterMs:        60000,
+			slotStartedAgo:       0,
+			expectedHTTPResponse: http.StatusTooEarly,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			submissionAcceptAfterMs = tc.acceptAfterMs
+			defer func() { submissionAcceptAfterMs = 0 }()
+
+			// The slot before the submission slot started slotStartedAgo
+			genesisTime := uint64(time.Now().Add(-tc.slotStartedAgo).Unix()) - (slot-1)*12
+			backend.relay.genesisInfo.Data.GenesisTime = genesisTime
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			req.ExecutionPayload.Timestamp = genesisTime + slot*12
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockTimestampTolerance(t *testing.T) {
+	testCases := []struct {
+		description          string
+		toleranceSec         uint64
+		offsetSec            int64
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "exact",
+			toleranceSec:         0,
+			offsetSec:            0,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "strict",
+			toleranceSec:         0,
+			offsetSec:            1,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "exact_with_tolerance",
+			toleranceSec:         1,
+			offsetSec:            0,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "later_within_tolerance",
+			toleranceSec:         1,
+			offsetSec:            1,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "earlier_within_tolerance",
+			toleranceSec:         1,
+			offsetSec:            -1,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "outside_tolerance",
+			toleranceSec:         1,
+			offsetSec:            -12,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			timestampToleranceSec = tc.toleranceSec
+			defer func() { timestampToleranceSec = 0 }()
+
+			genesisTime := uint64(1_000)
+			backend.relay.genesisInfo.Data.GenesisTime = genesisTime
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			req.ExecutionPayload.Timestamp = uint64(int64(genesisTime+slot*12) + tc.offsetSec)
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+
+			// Blocks with an inexact timestamp are rejected before the simulation, even within the tolerance
+			bid, err := backend.relay.redis.GetBestBid(slot, req.Message.ParentHash.String(), req.Message.ProposerPubkey.String())
+			require.NoError(t, err)
+			if tc.expectedHTTPResponse == http.StatusOK {
+				require.NotNil(t, bid)
+			} else {
+				require.Nil(t, bid)
+				require.Contains(t, rr.Body.String(), fmt.Sprintf("expected %d", genesisTime+slot*12))
+			}
+		})
+	}
+}
+
+func TestTimestampToleranceCap(t *testing.T) {
+	timestampToleranceSec = 20
+	defer func() { timestampToleranceSec = 0 }()
+	newTestBackend(t, 1)
+	require.Equal(t, uint64(11), timestampToleranceSec)
+}
+
+func TestBuilderApiSubmitNewBlockSecondsPerSlot(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.opts.EthNetDetails.SecondsPerSlot = 6
+	genesisTime := uint64(1_000)
+	backend.relay.genesisInfo.Data.GenesisTime = genesisTime
+
+	// The mainnet slot time is rejected on a network with 6s slots
+	req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	req.ExecutionPayload.Timestamp = genesisTime + slot*12
+	rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	req = common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	req.ExecutionPayload.Timestamp = genesisTime + slot*6
+	rr = backend.request(http.MethodPost, pathSubmitNewBlock, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+// randaoBeaconClient returns a prev_randao for every slot
+type publishBeaconClient struct {
+	*beaconclient.MockMultiBeaconClient
+	numPublished uberatomic.Int64
+}
+
+func (c *publishBeaconClient) PublishBlock(block *types.SignedBeaconBlock) (code int, err error) {
+	c.numPublished.Inc()
+	return http.StatusOK, nil
+}
+
+func TestStopServerWaitsForBlockPublishing(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	beaconClient := &publishBeaconClient{MockMultiBeaconClient: beaconclient.NewMockMultiBeaconClient()}
+	backend.relay.beaconClient = beaconClient
+	backend.relay.publishDelay = 500 * time.Millisecond
+
+	runOptimisticGetPayload(t, blockRequestOpts{
+		secretkey: secretkey,
+		pubkey:    *pubkey,
+		domain:    backend.relay.opts.EthNetDetails.DomainBeaconProposer,
+	}, backend)
+	require.Equal(t, int64(0), beaconClient.numPublished.Load())
+
+	// The delayed block is published before the server stops
+	backend.relay.opts.ProposerAPI = false // skip waiting for getPayload calls
+	err := backend.relay.StopServer()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), beaconClient.numPublished.Load())
+}
+
+type randaoBeaconClient struct {
+	*beaconclient.MockMultiBeaconClient
+}
+
+func (c *randaoBeaconClient) GetRandao(slot uint64) (*beaconclient.GetRandaoResponse, error) {
+	resp := &beaconclient.GetRandaoResponse{}
+	resp.Data.Randao = types.Hash{byte(slot)}.String()
+	return resp, nil
+}
+
+func TestBuilderApiSubmitNewBlockHeadSlotGrace(t *testing.T) {
+	testCases := []struct {
+		description          string
+		graceMs              int
+		slotStartsIn         time.Duration
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "disabled",
+			graceMs:              0,
+			slotStartsIn:         time.Minute,
+			expectedHTTPResponse: http.StatusConflict,
+		},
+		{
+			description:          "within_grace",
+			graceMs:              1000,
+			slotStartsIn:         time.Minute,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "after_grace",
+			graceMs:              1000,
+			slotStartsIn:         -time.Minute,
+			expectedHTTPResponse: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			headSlotGraceMs = tc.graceMs
+			defer func() { headSlotGraceMs = 0 }()
+
+			// The head event for the submission slot arrived, the slot starts in slotStartsIn
+			genesisTime := uint64(time.Now().Add(tc.slotStartsIn).Unix()) - slot*12
+			backend.relay.genesisInfo.Data.GenesisTime = genesisTime
+			backend.relay.beaconClient = &randaoBeaconClient{MockMultiBeaconClient: beaconclient.NewMockMultiBeaconClient()}
+			backend.relay.headSlot.Store(slot - 1)
+			backend.relay.processNewSlot(slot)
+
+			// The expected prev_randao and the duties moved on to the next slot
+			require.Eventually(t, func() bool {
+				backend.relay.expectedPrevRandaoLock.RLock()
+				defer backend.relay.expectedPrevRandaoLock.RUnlock()
+				return backend.relay.expectedPrevRandao.slot == slot+1
+			}, time.Second, 10*time.Millisecond)
+			require.Eventually(t, func() bool {
+				backend.relay.proposerDutiesLock.RLock()
+				defer backend.relay.proposerDutiesLock.RUnlock()
+				return backend.relay.proposerDutiesSlot == slot
+			}, time.Second, 10*time.Millisecond)
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			req.ExecutionPayload.Timestamp = genesisTime + slot*12
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockVerifySigEarly(t *testing.T) {
+	testCases := []struct {
+		description     string
+		verifySigEarly  bool
+		expectedMessage string
+	}{
+		{
+			description:     "default",
+			verifySigEarly:  false,
+			expectedMessage: "could not find slot duty",
+		},
+		{
+			description:     "early",
+			verifySigEarly:  true,
+			expectedMessage: "invalid signature",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.ffVerifySigEarly = tc.verifySigEarly
+			backend.relay.proposerDutiesMap = map[uint64]*types.RegisterValidatorRequestMessage{}
+
+			// The header is signed, the message decoded from the full payload isn't
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			forgedMessage := *req.Message
+			forgedMessage.Value = types.IntToU256(collateral * 2)
+			reqJSON, err := json.Marshal(req)
+			require.NoError(t, err)
+			forgedMessageJSON, err := json.Marshal(forgedMessage)
+			require.NoError(t, err)
+			body := append(reqJSON[:len(reqJSON)-1], []byte(`,"message":`+string(forgedMessageJSON)+"}")...)
+
+			rr := httptest.NewRecorder()
+			httpReq, err := http.NewRequest(http.MethodPost, pathSubmitNewBlock, bytes.NewReader(body))
+			require.NoError(t, err)
+			backend.relay.getRouter().ServeHTTP(rr, httpReq)
+			require.Equal(t, http.StatusBadRequest, rr.Code)
+			require.Contains(t, rr.Body.String(), tc.expectedMessage)
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockIdempotencyKey(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	payload := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	payloadBytes, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	submit := func(idempotencyKey string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, pathSubmitNewBlock, bytes.NewReader(payloadBytes))
+		require.NoError(t, err)
+		req.Header.Set(HeaderIdempotencyKey, idempotencyKey)
+		rr := httptest.NewRecorder()
+		backend.relay.getRouter().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := submit("key-1")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	cachedResp, err := backend.relay.redis.GetSubmitBlockResponse(pubkey.String(), "key-1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, cachedResp.Code)
+
+	// A retry is answered from the cache, even if processing it again would now fail
+	minCollateral := types.IntToU256(uint64(collateral) + 1)
+	backend.relay.minCollateral = &minCollateral
+	rr = submit("key-1")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = submit("key-2")
+	require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+	cachedResp, err = backend.relay.redis.GetSubmitBlockResponse(pubkey.String(), "key-2")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, cachedResp.Code)
+	require.Equal(t, rr.Body.String(), cachedResp.Body)
+}
+
+func TestBuilderApiSubmitNewBlockContentEncoding(t *testing.T) {
+	testCases := []struct {
+		description          string
+		contentEncoding      string
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "none",
+			contentEncoding:      "",
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "gzip",
+			contentEncoding:      "gzip",
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "zstd",
+			contentEncoding:      "zstd",
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "unsupported",
+			contentEncoding:      "compress",
+			expectedHTTPResponse: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			payload := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			payloadBytes, err := json.Marshal(payload)
+			require.NoError(t, err)
+
+			var body bytes.Buffer
+			switch tc.contentEncoding {
+			case "gzip":
+				zw := gzip.NewWriter(&body)
+				_, err = zw.Write(payloadBytes)
+				require.NoError(t, err)
+				require.NoError(t, zw.Close())
+			case "zstd":
+				zw, err := zstd.NewWriter(&body)
+				require.NoError(t, err)
+				_, err = zw.Write(payloadBytes)
+				require.NoError(t, err)
+				require.NoError(t, zw.Close())
+			default:
+				body.Write(payloadBytes)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, pathSubmitNewBlock, &body)
+			require.NoError(t, err)
+			req.Header.Set("Content-Encoding", tc.contentEncoding)
+			rr := httptest.NewRecorder()
+			backend.relay.getRouter().ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockMinCollateral(t *testing.T) {
+	minCollateralAboveTest := types.IntToU256(uint64(collateral) + 1)
+	testCases := []struct {
+		description          string
+		minCollateral        *types.U256Str
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "not_required",
+			minCollateral:        nil,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "sufficient_collateral",
+			minCollateral:        &types.U256Str{},
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "insufficient_collateral",
+			minCollateral:        &minCollateralAboveTest,
+			expectedHTTPResponse: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.minCollateral = tc.minCollateral
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockMaxBidValue(t *testing.T) {
+	testCases := []struct {
+		description          string
+		maxBidValue          types.U256Str
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "value_at_maximum",
+			maxBidValue:          types.IntToU256(uint64(collateral)),
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "value_above_maximum",
+			maxBidValue:          types.IntToU256(uint64(collateral) - 1),
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			maxBidValue := tc.maxBidValue
+			backend.relay.maxBidValue = &maxBidValue
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockStrictFeeRecipient(t *testing.T) {
+	testCases := []struct {
+		description          string
+		strict               bool
+		payloadFeeRecipient  types.Address
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "lenient_mismatch",
+			strict:               false,
+			payloadFeeRecipient:  types.Address{0x03},
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "strict_match",
+			strict:               true,
+			payloadFeeRecipient:  feeRecipient,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "strict_mismatch",
+			strict:               true,
+			payloadFeeRecipient:  types.Address{0x03},
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.ffStrictFeeRecipient = tc.strict
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			req.ExecutionPayload.FeeRecipient = tc.payloadFeeRecipient
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockStrictJSON(t *testing.T) {
+	testCases := []struct {
+		description          string
+		strict               bool
+		extraField           bool
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "lenient_extra_field",
+			strict:               false,
+			extraField:           true,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "strict_no_extra_field",
+			strict:               true,
+			extraField:           false,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "strict_extra_field",
+			strict:               true,
+			extraField:           true,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.ffStrictJSONDecoding = tc.strict
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			payload, err := json.Marshal(req)
+			require.NoError(t, err)
+			if tc.extraField {
+				payload = append(payload[:len(payload)-1], []byte(`,"unexpected":"data"}`)...)
+			}
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, json.RawMessage(payload))
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockBlockNumber(t *testing.T) {
+	testCases := []struct {
+		description          string
+		parentHash           string
+		expectedBlockNumber  uint64
+		payloadBlockNumber   uint64
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "unknown_block_number",
+			parentHash:           types.Hash{}.String(),
+			expectedBlockNumber:  0,
+			payloadBlockNumber:   123,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "correct_block_number",
+			parentHash:           types.Hash{}.String(),
+			expectedBlockNumber:  100,
+			payloadBlockNumber:   100,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "incorrect_block_number",
+			parentHash:           types.Hash{}.String(),
+			expectedBlockNumber:  100,
+			payloadBlockNumber:   123,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "different_parent",
+			parentHash:           types.Hash{0x01}.String(),
+			expectedBlockNumber:  100,
+			payloadBlockNumber:   123,
+			expectedHTTPResponse: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.expectedPrevRandao.parentHash = tc.parentHash
+			backend.relay.expectedPrevRandao.blockNumber = tc.expectedBlockNumber
+
+			req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			req.ExecutionPayload.BlockNumber = tc.payloadBlockNumber
+			rr := backend.request(http.MethodPost, pathSubmitNewBlock, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestProposerApiGetPayloadVerifyBodyRoot(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.ffVerifyPayloadBodyRoot = true
+
+	executionPayload := &types.ExecutionPayload{
+		BlockHash:    getTestBlockHash(t),
+		BlockNumber:  1234,
+		Transactions: []hexutil.Bytes{},
+	}
+	err := backend.relay.redis.SaveExecutionPayload(slot, pubkey.String(), getTestBlockHash(t).String(), &types.GetPayloadResponse{Data: executionPayload})
+	require.NoError(t, err)
+	header, err := types.PayloadToPayloadHeader(executionPayload)
+	require.NoError(t, err)
+
+	getPayloadRequest := func(header *types.ExecutionPayloadHeader) *types.SignedBlindedBeaconBlock {
+		block := &types.BlindedBeaconBlock{
+			Slot:          slot,
+			ProposerIndex: proposerInd,
+			Body: &types.BlindedBeaconBlockBody{
+				ExecutionPayloadHeader: header,
+				Eth1Data:               &types.Eth1Data{},
+				SyncAggregate:          &types.SyncAggregate{},
+			},
+		}
+		signature, err := types.SignMessage(block, backend.relay.opts.EthNetDetails.DomainBeaconProposer, secretkey)
+		require.NoError(t, err)
+		return &types.SignedBlindedBeaconBlock{Message: block, Signature: signature}
+	}
+
+	// A header with the same block hash, but a different body root
+	mismatchedHeader := *header
+	mismatchedHeader.GasUsed = 1
+	rr := backend.request(http.MethodPost, pathGetPayload, getPayloadRequest(&mismatchedHeader))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), ErrPayloadHeaderMismatch.Error())
+
+	rr = backend.request(http.MethodPost, pathGetPayload, getPayloadRequest(header))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestProposerApiGetPayloadSSZ(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+
+	executionPayload := &types.ExecutionPayload{
+		BlockHash:    getTestBlockHash(t),
+		BlockNumber:  1234,
+		ExtraData:    types.ExtraData{0x01, 0x02},
+		Transactions: []hexutil.Bytes{{0x03}, {0x04, 0x05}},
+	}
+	err := backend.relay.redis.SaveExecutionPayload(slot, pubkey.String(), getTestBlockHash(t).String(), &types.GetPayloadResponse{Version: "bellatrix", Data: executionPayload})
+	require.NoError(t, err)
+
+	block := &types.BlindedBeaconBlock{
+		Slot:          slot,
+		ProposerIndex: proposerInd,
+		Body: &types.BlindedBeaconBlockBody{
+			ExecutionPayloadHeader: &types.ExecutionPayloadHeader{BlockHash: getTestBlockHash(t)},
+			Eth1Data:               &types.Eth1Data{},
+			SyncAggregate:          &types.SyncAggregate{},
+		},
+	}
+	signature, err := types.SignMessage(block, backend.relay.opts.EthNetDetails.DomainBeaconProposer, secretkey)
+	require.NoError(t, err)
+	payloadBytes, err := json.Marshal(&types.SignedBlindedBeaconBlock{Message: block, Signature: signature})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, pathGetPayload, bytes.NewReader(payloadBytes))
+	require.NoError(t, err)
+	req.Header.Set("Accept", MediaTypeOctetStream)
+	rr := httptest.NewRecorder()
+	backend.relay.getRouter().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Equal(t, MediaTypeOctetStream, rr.Header().Get("Content-Type"))
+	require.Equal(t, "bellatrix", rr.Header().Get(HeaderEthConsensusVersion))
+
+	expected, err := MarshalExecutionPayloadSSZ(executionPayload)
+	require.NoError(t, err)
+	require.Equal(t, expected, rr.Body.Bytes())
+}
+
+func TestProposerApiGetPayloadAllowlist(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.proposerAllowlist = map[types.PubkeyHex]bool{}
+
+	block := &types.BlindedBeaconBlock{
+		Slot:          slot,
+		ProposerIndex: proposerInd,
+		Body: &types.BlindedBeaconBlockBody{
+			ExecutionPayloadHeader: &types.ExecutionPayloadHeader{BlockHash: getTestBlockHash(t)},
+			Eth1Data:               &types.Eth1Data{},
+			SyncAggregate:          &types.SyncAggregate{},
+		},
+	}
+	signature, err := types.SignMessage(block, backend.relay.opts.EthNetDetails.DomainBeaconProposer, secretkey)
+	require.NoError(t, err)
+	req := &types.SignedBlindedBeaconBlock{Message: block, Signature: signature}
+
+	rr := backend.request(http.MethodPost, pathGetPayload, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	backend.relay.proposerAllowlist = map[types.PubkeyHex]bool{pubkey.PubkeyHex(): true}
+	rr = backend.request(http.MethodPost, pathGetPayload, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestProposerApiGetPayloadSlotWindow(t *testing.T) {
+	testCases := []struct {
+		description          string
+		headSlot             uint64
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "head_slot_unknown",
+			headSlot:             0,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "next_slot",
+			headSlot:             slot - 1,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "head_behind",
+			headSlot:             slot - 2,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "future_slot",
+			headSlot:             slot - 3,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "past_slot",
+			headSlot:             slot + 1,
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			_, secretkey, backend := startTestBackend(t)
+			backend.relay.headSlot.Store(tc.headSlot)
+
+			block := &types.BlindedBeaconBlock{
+				Slot:          slot,
+				ProposerIndex: proposerInd,
+				Body: &types.BlindedBeaconBlockBody{
+					ExecutionPayloadHeader: &types.ExecutionPayloadHeader{BlockHash: getTestBlockHash(t)},
+					Eth1Data:               &types.Eth1Data{},
+					SyncAggregate:          &types.SyncAggregate{},
+				},
+			}
+			signature, err := types.SignMessage(block, backend.relay.opts.EthNetDetails.DomainBeaconProposer, secretkey)
+			require.NoError(t, err)
+			req := &types.SignedBlindedBeaconBlock{Message: block, Signature: signature}
+
+			rr := backend.request(http.MethodPost, pathGetPayload, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockForkVersion(t *testing.T) {
+	testCases := []struct {
+		description          string
+		capellaForkEpoch     uint64
+		consensusVersion     string
+		expectedHTTPResponse int
+	}{
+		{
+			description:          "bellatrix",
+			capellaForkEpoch:     0,
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "bellatrix_before_fork",
+			capellaForkEpoch:     slot/uint64(common.SlotsPerEpoch) + 1,
+			consensusVersion:     "Bellatrix",
+			expectedHTTPResponse: http.StatusOK,
+		},
+		{
+			description:          "bellatrix_after_fork",
+			capellaForkEpoch:     slot / uint64(common.SlotsPerEpoch),
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+		{
+			description:          "capella_before_fork",
+			capellaForkEpoch:     0,
+			consensusVersion:     "capella",
+			expectedHTTPResponse: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pubkey, secretkey, backend := startTestBackend(t)
+			backend.relay.opts.EthNetDetails.CapellaForkEpoch = tc.capellaForkEpoch
+
+			payload := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+			payloadBytes, err := json.Marshal(payload)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, pathSubmitNewBlock, bytes.NewReader(payloadBytes))
+			require.NoError(t, err)
+			if tc.consensusVersion != "" {
+				req.Header.Set(HeaderEthConsensusVersion, tc.consensusVersion)
+			}
+
+			rr := httptest.NewRecorder()
+			backend.relay.getRouter().ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedHTTPResponse, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestBuilderApiSubmitNewBlockStoreRaw(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	backend.relay.ffStoreRawSubmissions = true
+	backend.relay.db.(*database.MockDB).RawSubmissions = map[string]*database.RawBlockSubmissionEntry{}
+
+	// Bodies without a valid builder signature are not stored
+	payload := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	payload.Signature = types.Signature{}
+	rr := backend.request(http.MethodPost, pathSubmitNewBlock, payload)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	time.Sleep(100 * time.Millisecond)
+	require.Empty(t, backend.relay.db.(*database.MockDB).RawSubmissions)
+
+	payload = common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+	payloadBytes, err := json.Marshal(payload)
+	require.NoError(t, err)
+	payloadBytes = append(payloadBytes, '\n')
+	req, err := http.NewRequest(http.MethodPost, pathSubmitNewBlock, bytes.NewReader(payloadBytes))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	backend.relay.getRouter().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// Let the raw submission be saved async.
+	time.Sleep(100 * time.Millisecond)
+
+	path := fmt.Sprintf("/internal/v1/submission/raw/%d/%s/%s", slot, pubkey.String(), payload.Message.BlockHash.String())
+	rr = backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, payloadBytes, rr.Body.Bytes())
+
+	path = fmt.Sprintf("/internal/v1/submission/raw/%d/%s/%s", slot+1, pubkey.String(), payload.Message.BlockHash.String())
+	rr = backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
 }
@@ -22,6 +22,7 @@ import (
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/buger/jsonparser"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/flashbots/go-boost-utils/bls"
 	"github.com/flashbots/go-boost-utils/types"
 	"github.com/flashbots/go-utils/cli"
@@ -32,6 +33,7 @@ import (
 	"github.com/flashbots/mev-boost-relay/datastore"
 	"github.com/go-redis/redis/v9"
 	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
 	"github.com/sirupsen/logrus"
 	uberatomic "go.uber.org/atomic"
 )
@@ -45,6 +47,9 @@ var (
 	ErrRelayPubkeyMismatch        = errors.New("relay pubkey does not match existing one")
 	ErrServerAlreadyStarted       = errors.New("server was already started")
 	ErrBuilderAPIWithoutSecretKey = errors.New("cannot start builder API without secret key")
+	ErrNoProposerAllowlist        = errors.New("no proposer allowlist configured")
+	ErrNoBuilderLists             = errors.New("no builder allowlist or denylist configured")
+	ErrInvalidValueAnomalyWindow  = errors.New("VALUE_ANOMALY_WINDOW must be positive")
 )
 
 var (
@@ -53,24 +58,155 @@ var (
 	pathRegisterValidator = "/eth/v1/builder/validators"
 	pathGetHeader         = "/eth/v1/builder/header/{slot:[0-9]+}/{parent_hash:0x[a-fA-F0-9]+}/{pubkey:0x[a-fA-F0-9]+}"
 	pathGetPayload        = "/eth/v1/builder/blinded_blocks"
+	pathRelayInfo         = "/eth/v1/builder/relay_info"
+	pathProposerDuties    = "/eth/v1/builder/duties/{pubkey:0x[a-fA-F0-9]+}"
 
 	// Block builder API
 	pathBuilderGetValidators = "/relay/v1/builder/validators"
 	pathSubmitNewBlock       = "/relay/v1/builder/blocks"
+	pathBuilderPrevRandao    = "/relay/v1/builder/prev_randao"
 
 	// Data API
 	pathDataProposerPayloadDelivered = "/relay/v1/data/bidtraces/proposer_payload_delivered"
 	pathDataBuilderBidsReceived      = "/relay/v1/data/bidtraces/builder_blocks_received"
 	pathDataValidatorRegistration    = "/relay/v1/data/validator_registration"
+	pathDataRegistrationHistory      = "/relay/v1/data/validator_registration_history"
+	pathDataActiveValidators         = "/relay/v1/data/active_validators"
+	pathDataFeeRecipientChanges      = "/relay/v1/data/fee_recipient_changes"
+	pathDataSlotWinner               = "/relay/v1/data/slot_winner"
+	pathDataBuilderStats             = "/relay/v1/data/builder_stats"
 
 	// Internal API
-	pathInternalBuilderStatus     = "/internal/v1/builder/{pubkey:0x[a-fA-F0-9]+}"
-	pathInternalBuilderCollateral = "/internal/v1/builder/collateral/{pubkey:0x[a-fA-F0-9]+}"
+	pathInternalBuilders            = "/internal/v1/builders"
+	pathInternalBuilderStatus       = "/internal/v1/builder/{pubkey:0x[a-fA-F0-9]+}"
+	pathInternalBuilderCollateral   = "/internal/v1/builder/collateral/{pubkey:0x[a-fA-F0-9]+}"
+	pathInternalBuilderRefresh      = "/internal/v1/builder/{pubkey:0x[a-fA-F0-9]+}/refresh"
+	pathInternalRejectedSubmissions = "/internal/v1/submissions/rejected"
+	pathInternalSubmissionStats     = "/internal/v1/stats/submissions"
+	pathInternalSimulationStats     = "/internal/v1/stats/simulation"
+	pathInternalOptimisticEnabled   = "/internal/v1/optimistic/enabled"
+	pathInternalProposerAllowlist   = "/internal/v1/proposer_allowlist/reload"
+	pathInternalBuilderLists        = "/internal/v1/builder_lists/reload"
+	pathInternalMetrics             = "/internal/v1/metrics"
+	pathInternalPrometheusMetrics   = "/internal/v1/metrics/prometheus"
+	pathInternalConfig              = "/internal/v1/config"
+	pathInternalBuildInfo           = "/internal/v1/build_info"
+	pathInternalPayload             = "/internal/v1/payload/{slot:[0-9]+}/{proposer_pubkey:0x[a-fA-F0-9]+}/{block_hash:0x[a-fA-F0-9]+}"
+	pathInternalMissedSlots         = "/internal/v1/missed_slots"
+	pathInternalFeatureFlags        = "/internal/v1/flags"
+	pathInternalRawSubmission       = "/internal/v1/submission/raw/{slot:[0-9]+}/{builder_pubkey:0x[a-fA-F0-9]+}/{block_hash:0x[a-fA-F0-9]+}"
+	pathInternalDemotion            = "/internal/v1/demotion/{slot:[0-9]+}/{builder_pubkey:0x[a-fA-F0-9]+}/{block_hash:0x[a-fA-F0-9]+}"
+	pathInternalDemotions           = "/internal/v1/demotions"
+	pathInternalSlotBlocklist       = "/internal/v1/slot_blocklist"
+	pathInternalFeeRecipients       = "/internal/v1/proposer/{pubkey:0x[a-fA-F0-9]+}/fee_recipients"
 
 	// number of goroutines to save active validator
 	numActiveValidatorProcessors = cli.GetEnvInt("NUM_ACTIVE_VALIDATOR_PROCESSORS", 10)
 	numValidatorRegProcessors    = cli.GetEnvInt("NUM_VALIDATOR_REG_PROCESSORS", 10)
-	timeoutGetPayloadRetryMs     = cli.GetEnvInt("GETPAYLOAD_RETRY_TIMEOUT_MS", 100)
+
+	// number of goroutines to process the registration calls answered before processing, and the size of their queue
+	numAsyncValidatorRegProcessors = cli.GetEnvInt("NUM_ASYNC_VALIDATOR_REG_PROCESSORS", 4)
+	asyncValidatorRegQueueSize     = cli.GetEnvInt("ASYNC_VALIDATOR_REG_QUEUE_SIZE", 100)
+	timeoutGetPayloadRetryMs       = cli.GetEnvInt("GETPAYLOAD_RETRY_TIMEOUT_MS", 100)
+
+	// maximum number of getPayload calls processed at the same time, further calls get a 503, 0 for no maximum
+	maxConcurrentGetPayloads = cli.GetEnvInt("GETPAYLOAD_MAX_CONCURRENT", 0)
+
+	// maximum number of slots a getPayload request may be ahead of the head slot, older slots than the head slot are always rejected
+	getPayloadMaxSlotLead = uint64(cli.GetEnvInt("GETPAYLOAD_MAX_SLOT_LEAD", 2))
+
+	// deadline for reading the getPayload request body, shorter than the server read timeout, 0 to disable
+	getPayloadBodyReadTimeoutMs = cli.GetEnvInt("GETPAYLOAD_BODY_READ_TIMEOUT_MS", 0)
+
+	// channel sizes, and how long to wait for space in the registration channel before dropping a registration
+	activeValidatorChannelSize   = cli.GetEnvInt("ACTIVE_VALIDATOR_CHANNEL_SIZE", 450_000)
+	validatorRegChannelSize      = cli.GetEnvInt("VALIDATOR_REG_CHANNEL_SIZE", 450_000)
+	validatorRegChannelTimeoutMs = cli.GetEnvInt("VALIDATOR_REG_CHANNEL_TIMEOUT_MS", 0)
+
+	// retries of the redis writes making a submitted bid eligible, with exponential backoff
+	redisSubmissionRetries        = cli.GetEnvInt("REDIS_SUBMISSION_RETRIES", 2)
+	redisSubmissionRetryBackoffMs = cli.GetEnvInt("REDIS_SUBMISSION_RETRY_BACKOFF_MS", 10)
+
+	// warn about submissions with a value above this factor times the median of the recent accepted values, 0 to disable
+	valueAnomalyFactor = cli.GetEnvInt("VALUE_ANOMALY_FACTOR", 0)
+	valueAnomalyWindow = cli.GetEnvInt("VALUE_ANOMALY_WINDOW", 1000)
+
+	// write the informational logs of 1 in this many block submissions, rejections and errors are always logged
+	submissionLogSampleRate = cli.GetEnvInt("SUBMISSION_LOG_SAMPLE_RATE", 1)
+
+	// upper bound of plausible submission values (1000 ETH), overridden with MAX_BID_WEI
+	defaultMaxBidWei = "1000000000000000000000"
+
+	// set high-prio builders to low-prio when their average simulation duration exceeds this, 0 to disable
+	slowSimThresholdMs = cli.GetEnvInt("SLOW_SIM_THRESHOLD_MS", 0)
+	slowSimWindow      = cli.GetEnvInt("SLOW_SIM_WINDOW", 100)
+
+	// delay before publishing the block of a getPayload call, for timing research; the proposer's response isn't delayed
+	publishDelayMs    = cli.GetEnvInt("PUBLISH_DELAY_MS", 0)
+	maxPublishDelayMs = 4000 // attestations for the slot are due 4s after its start
+
+	// seconds a submission's payload timestamp may differ from the slot timestamp to be logged as tolerated (e.g. builder
+	// clock drift), such submissions are still rejected as the beacon node requires the exact slot timestamp
+	timestampToleranceSec = uint64(cli.GetEnvInt("TIMESTAMP_TOLERANCE_SEC", 0))
+
+	// retries of the beacon node requests needed to start the server, with exponential backoff
+	beaconStartupRetries        = cli.GetEnvInt("BEACON_STARTUP_RETRIES", 5)
+	beaconStartupRetryBackoffMs = cli.GetEnvInt("BEACON_STARTUP_RETRY_BACKOFF_MS", 1000)
+
+	// number of missed slots kept for the internal API
+	missedSlotsWindow = cli.GetEnvInt("MISSED_SLOTS_WINDOW", 1000)
+
+	// time to save queued active validators and registrations on shutdown, 0 to drop them
+	shutdownDrainTimeoutMs = cli.GetEnvInt("SHUTDOWN_DRAIN_TIMEOUT_MS", 5000)
+
+	// header set by a trusted reverse proxy with the client address, e.g. X-Forwarded-For
+	trustedProxyHeader = os.Getenv("TRUSTED_PROXY_HEADER")
+
+	// number of slots the head may lag behind a submission (beyond slot-1) before responding 503, -1 to disable
+	submissionMaxHeadSlotLag = cli.GetEnvInt("SUBMISSION_MAX_HEAD_SLOT_LAG", -1)
+
+	// number of slot durations without a head event before resubscribing and polling the beacon node, 0 to disable
+	headEventWatchdogSlots = cli.GetEnvInt("HEAD_EVENT_WATCHDOG_SLOTS", 2)
+
+	// time after the start of the head slot during which submissions for it are still accepted, 0 to disable
+	headSlotGraceMs = cli.GetEnvInt("HEAD_SLOT_GRACE_MS", 0)
+
+	// offset into the slot before which submissions for the next slot are rejected, 0 to disable
+	submissionAcceptAfterMs = cli.GetEnvInt("SUBMISSION_ACCEPT_AFTER_MS", 0)
+
+	// maximum number of blocks a builder can submit for a single slot, further ones get a 429, 0 for no maximum
+	maxBuilderSubmissionsPerSlot = cli.GetEnvInt("BUILDER_SUBMISSIONS_PER_SLOT_MAX", 0)
+
+	// number of slots after which a demoted builder is re-enabled for optimistic processing, 0 to only promote manually
+	demotionExpirySlots = cli.GetEnvInt("DEMOTION_EXPIRY_SLOTS", 0)
+
+	// minimum gas used and number of transactions of a submitted block, blocks without transactions are always rejected
+	minBlockGasUsed = uint64(cli.GetEnvInt("MIN_BLOCK_GAS_USED", 0))
+	minBlockTxCount = cli.GetEnvInt("MIN_BLOCK_TX_COUNT", 1)
+
+	// maximum factor between the bid value and gas_used * base_fee_per_gas of a submission, 0 to disable
+	valueFeeBoundFactor = cli.GetEnvInt("VALUE_FEE_BOUND_FACTOR", 0)
+
+	// maximum page size of the active validators data API
+	maxActiveValidatorsPageSize = cli.GetEnvInt("ACTIVE_VALIDATORS_API_MAX_LIMIT", 1000)
+
+	// maximum number of slots between slot_from and slot_to in data API queries
+	maxDataAPISlotRange = uint64(cli.GetEnvInt("DATA_API_MAX_SLOT_RANGE", 7200))
+
+	// maximum time to wait for the database when re-checking the demotion status before optimistic processing
+	optimisticDemotionCheckTimeoutMs = cli.GetEnvInt("OPTIMISTIC_DEMOTION_CHECK_TIMEOUT_MS", 50)
+
+	// deadline for a single block simulation, including the rate limiter queue, 0 to disable
+	blockSimTimeoutMs = cli.GetEnvInt("BLOCK_SIM_TIMEOUT_MS", 0)
+
+	// maximum size of a simulation error response stored with a demotion
+	maxSimErrorResponseBytes = cli.GetEnvInt("SIM_ERROR_RESPONSE_MAX_BYTES", 4096)
+
+	// interval for saving metrics snapshots to the database, 0 to disable
+	metricsSnapshotIntervalSec = cli.GetEnvInt("METRICS_SNAPSHOT_INTERVAL_SEC", 0)
+
+	// interval for reloading the slot blocklist from redis between head events, 0 to only reload it on head events
+	slotBlocklistRefreshMs = cli.GetEnvInt("SLOT_BLOCKLIST_REFRESH_MS", 1000)
 
 	apiReadTimeoutMs       = cli.GetEnvInt("API_TIMEOUT_READ_MS", 1500)
 	apiReadHeaderTimeoutMs = cli.GetEnvInt("API_TIMEOUT_READHEADER_MS", 600)
@@ -90,7 +226,8 @@ type RelayAPIOpts struct {
 	Redis        *datastore.RedisCache
 	DB           database.IDatabaseService
 
-	SecretKey *bls.SecretKey // used to sign bids (getHeader responses)
+	SecretKey *bls.SecretKey // used to sign bids (getHeader responses) if no Signer is set
+	Signer    ISigner        // signs bids instead of SecretKey, e.g. with a key held in a KMS or HSM
 
 	// Network specific variables
 	EthNetDetails common.EthNetworkDetails
@@ -101,11 +238,19 @@ type RelayAPIOpts struct {
 	DataAPI         bool
 	PprofAPI        bool
 	InternalAPI     bool
+
+	BuildInfo BuildInfo
 }
 
 type randaoHelper struct {
 	slot       uint64
 	prevRandao string
+
+	// Execution block expected to be the parent at this slot, blockNumber is 0 if unknown
+	parentHash      string
+	blockNumber     uint64
+	parentTimestamp uint64
+	parentGasLimit  uint64
 }
 
 // Data needed to issue a block validation request.
@@ -114,6 +259,7 @@ type blockSimOptions struct {
 	isHighPrio bool
 	log        *logrus.Entry
 	req        *BuilderBlockValidationRequest
+	sampledOut bool // skip informational logs, see SUBMISSION_LOG_SAMPLE_RATE
 }
 
 type blockBuilderCacheEntry struct {
@@ -126,11 +272,12 @@ type RelayAPI struct {
 	opts RelayAPIOpts
 	log  *logrus.Entry
 
-	blsSk     *bls.SecretKey
+	signer    ISigner
 	publicKey *types.PublicKey
 
-	srv        *http.Server
-	srvStarted uberatomic.Bool
+	srv         *http.Server
+	srvStarted  uberatomic.Bool
+	srvStopping uberatomic.Bool
 
 	beaconClient beaconclient.IMultiBeaconClient
 	datastore    *datastore.Datastore
@@ -140,6 +287,14 @@ type RelayAPI struct {
 	headSlot    uberatomic.Uint64
 	genesisInfo *beaconclient.GetGenesisResponse
 
+	// time of the latest head event (unix ms), watched to resubscribe when head events stop arriving
+	lastHeadEventAt        uberatomic.Int64
+	lastHeadResubscribedAt time.Time
+
+	// cancels the current head event subscription, before resubscribing
+	cancelHeadEvents     context.CancelFunc
+	cancelHeadEventsLock sync.Mutex
+
 	proposerDutiesLock       sync.RWMutex
 	proposerDutiesResponse   []types.BuilderGetValidatorsResponseEntry
 	proposerDutiesMap        map[uint64]*types.RegisterValidatorRequestMessage
@@ -151,18 +306,70 @@ type RelayAPI struct {
 	activeValidatorC chan types.PubkeyHex
 	validatorRegC    chan types.SignedValidatorRegistration
 
+	// registration calls which were answered before processing them, waited for on shutdown
+	asyncValidatorRegC       chan func()
+	asyncValidatorRegBatches sync.WaitGroup
+
+	metrics relayMetrics
+
+	// values of the recent accepted submissions, nil if value anomalies aren't checked
+	recentValues *valueWindow
+
+	// number of block submissions, to sample their informational logs
+	submissionLogCounter uberatomic.Uint64
+
+	// recent simulation durations of each builder, served by the internal API
+	simDurations *simDurationWindows
+
+	// builders currently being set to low-prio for slow simulations, to downgrade each of them only once
+	slowBuilderDowngrades     map[string]bool
+	slowBuilderDowngradesLock sync.Mutex
+
+	// request durations by route, served by the internal API in the Prometheus format
+	requestMetrics *requestMetrics
+
+	// slots missed between two head events, served by the internal API
+	missedSlots *slotWindow
+
+	// delay before publishing a block, bounded by maxPublishDelayMs
+	publishDelay time.Duration
+
 	// used to wait on any active getPayload calls on shutdown
 	getPayloadCallsInFlight sync.WaitGroup
 
-	// Feature flags
-	ffForceGetHeader204      bool
-	ffDisableBlockPublishing bool
-	ffDisableLowPrioBuilders bool
+	// used to wait on the publishing of delivered blocks on shutdown, which may be delayed by publishDelay
+	blockPublishesInFlight sync.WaitGroup
+
+	// limits the number of concurrent getPayload calls, nil if there is no limit
+	getPayloadSem chan struct{}
+
+	// Feature flags, the first ones can be changed at runtime through the internal API
+	ffForceGetHeader204         uberatomic.Bool
+	ffDisableBlockPublishing    uberatomic.Bool
+	ffDisableLowPrioBuilders    uberatomic.Bool
+	ffStrictFeeRecipient        bool
+	ffStrictJSONDecoding        bool
+	ffRecheckDemotion           bool
+	ffEnableActiveValidatorsAPI bool
+	ffVerifyBlockHash           bool
+	ffVerifyBlockHashOptimistic bool
+	ffExplicitCancellations     bool
+	ffVerifyPayloadBodyRoot     bool
+	ffBuildInfoHeader           bool
+	ffStoreSimErrorResponse     bool
+	ffRecordParentBlock         bool
+	ffVerifySigEarly            bool
+	ffAsyncValidatorRegs        bool
+	ffGetHeaderDBFallback       bool
+	ffStoreRawSubmissions       bool
 
 	expectedPrevRandao         randaoHelper
 	expectedPrevRandaoLock     sync.RWMutex
 	expectedPrevRandaoUpdating uint64
 
+	// The expected prev_randao of the previous slot, for head slot submissions within HEAD_SLOT_GRACE_MS
+	previousPrevRandao randaoHelper
+
 	// The slot we are currently optimistically simulating.
 	optimisticSlot uint64
 	// The number of optimistic blocks being processed (only used for logging).
@@ -170,7 +377,43 @@ type RelayAPI struct {
 	// Wait group used to monitor status of per-slot optimistic processing.
 	optimisticBlocks sync.WaitGroup
 	// Cache for builder statuses and collaterals.
-	blockBuildersCache map[string]*blockBuilderCacheEntry
+	blockBuildersCache     map[string]*blockBuilderCacheEntry
+	blockBuildersCacheLock sync.RWMutex
+	// Global switch for optimistic processing, shared across instances via redis.
+	optimisticEnabled uberatomic.Bool
+	// Minimum collateral required for any submission, nil if not required.
+	minCollateral *types.U256Str
+	// Maximum collateral credited to any builder, nil if not capped.
+	maxCollateral *types.U256Str
+	// Submissions with a higher value are rejected as implausible.
+	maxBidValue *types.U256Str
+	// Decides between builders with equal top bid values.
+	topBidTieBreak datastore.TieBreakPolicy
+
+	// Proposers served by getHeader and getPayload, nil if all proposers are served
+	proposerAllowlist     map[types.PubkeyHex]bool
+	proposerAllowlistFile string
+	proposerAllowlistLock sync.RWMutex
+
+	// Builders whose submissions are accepted (nil if all) and rejected (nil if none), independent of the database
+	builderAllowlist     map[types.PubkeyHex]bool
+	builderAllowlistFile string
+	builderDenylist      map[types.PubkeyHex]bool
+	builderDenylistFile  string
+	builderListsLock     sync.RWMutex
+
+	// Submissions (builder pubkey and block hash) seen since the last head slot update, to skip exact duplicates. true once
+	// the submission is eligible, false while it is still being processed
+	seenSubmissions     map[string]bool
+	seenSubmissionsLock sync.Mutex
+
+	// slots for which no bids are served, kept in sync with redis on every slot
+	slotBlocklist     map[uint64]bool
+	slotBlocklistLock sync.RWMutex
+
+	// allowed fee recipients per proposer pubkey, proposers without an entry are not restricted. kept in sync with redis on every slot
+	feeRecipientAllowlists    map[string]map[string]bool
+	feeRecipientAllowlistLock sync.RWMutex
 }
 
 // NewRelayAPI creates a new service. if builders is nil, allow any builder
@@ -187,18 +430,22 @@ func NewRelayAPI(opts RelayAPIOpts) (api *RelayAPI, err error) {
 		return nil, ErrMissingDatastoreOpt
 	}
 
-	// If block-builder API is enabled, then ensure secret key is all set
+	// Sign with the in-memory secret key unless an external signer is provided
 	var publicKey types.PublicKey
+	signer := opts.Signer
+	if signer == nil && opts.SecretKey != nil {
+		signer, err = NewLocalSigner(opts.SecretKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// If block-builder API is enabled, then ensure a signer is set
 	if opts.BlockBuilderAPI {
-		if opts.SecretKey == nil {
+		if signer == nil {
 			return nil, ErrBuilderAPIWithoutSecretKey
 		}
 
-		// If using a secret key, ensure it's the correct one
-		publicKey, err = types.BlsPublicKeyToPublicKey(bls.PublicKeyFromSecretKey(opts.SecretKey))
-		if err != nil {
-			return nil, err
-		}
+		publicKey = signer.PublicKey()
 		opts.Log.Infof("Using BLS key: %s", publicKey.String())
 
 		// ensure pubkey is same across all relay instances
@@ -218,7 +465,7 @@ func NewRelayAPI(opts RelayAPIOpts) (api *RelayAPI, err error) {
 	api = &RelayAPI{
 		opts:                   opts,
 		log:                    opts.Log,
-		blsSk:                  opts.SecretKey,
+		signer:                 signer,
 		publicKey:              &publicKey,
 		datastore:              opts.Datastore,
 		beaconClient:           opts.BeaconClient,
@@ -227,23 +474,213 @@ func NewRelayAPI(opts RelayAPIOpts) (api *RelayAPI, err error) {
 		proposerDutiesResponse: []types.BuilderGetValidatorsResponseEntry{},
 		blockSimRateLimiter:    NewBlockSimulationRateLimiter(opts.BlockSimURL),
 
-		activeValidatorC: make(chan types.PubkeyHex, 450_000),
-		validatorRegC:    make(chan types.SignedValidatorRegistration, 450_000),
+		activeValidatorC: make(chan types.PubkeyHex, activeValidatorChannelSize),
+		validatorRegC:    make(chan types.SignedValidatorRegistration, validatorRegChannelSize),
+
+		asyncValidatorRegC: make(chan func(), asyncValidatorRegQueueSize),
+
+		seenSubmissions: make(map[string]bool),
+		slotBlocklist:   make(map[uint64]bool),
+
+		feeRecipientAllowlists: make(map[string]map[string]bool),
+		simDurations:           newSimDurationWindows(slowSimWindow),
+		slowBuilderDowngrades:  make(map[string]bool),
+		missedSlots:            newSlotWindow(missedSlotsWindow),
+		requestMetrics:         newRequestMetrics(),
+	}
+	api.optimisticEnabled.Store(true)
+
+	if maxConcurrentGetPayloads > 0 {
+		api.log.Infof("env: GETPAYLOAD_MAX_CONCURRENT - processing at most %d getPayload calls at a time", maxConcurrentGetPayloads)
+		api.getPayloadSem = make(chan struct{}, maxConcurrentGetPayloads)
+	}
+
+	if valueAnomalyFactor > 0 {
+		if valueAnomalyWindow <= 0 {
+			return nil, ErrInvalidValueAnomalyWindow
+		}
+		api.log.Warnf("env: VALUE_ANOMALY_FACTOR - warning about submission values above %dx the median of the last %d values", valueAnomalyFactor, valueAnomalyWindow)
+		api.recentValues = newValueWindow(valueAnomalyWindow)
+	}
+
+	if publishDelayMs > 0 {
+		if publishDelayMs > maxPublishDelayMs {
+			api.log.Warnf("env: PUBLISH_DELAY_MS - %dms is above the maximum, using %dms", publishDelayMs, maxPublishDelayMs)
+			publishDelayMs = maxPublishDelayMs
+		}
+		api.log.Warnf("env: PUBLISH_DELAY_MS - delaying the publishing of blocks by %dms", publishDelayMs)
+		api.publishDelay = time.Duration(publishDelayMs) * time.Millisecond
+	}
+
+	if timestampToleranceSec > 0 {
+		// a timestamp a whole slot off would match the neighbouring slot
+		if maxToleranceSec := uint64(opts.EthNetDetails.SlotDuration()/time.Second) - 1; timestampToleranceSec > maxToleranceSec {
+			api.log.Warnf("env: TIMESTAMP_TOLERANCE_SEC - %ds is not below the slot duration, using %ds", timestampToleranceSec, maxToleranceSec)
+			timestampToleranceSec = maxToleranceSec
+		}
+		api.log.Warnf("env: TIMESTAMP_TOLERANCE_SEC - logging rejected submissions with timestamps up to %ds off the slot timestamp as tolerated", timestampToleranceSec)
+	}
+
+	if slowSimThresholdMs > 0 {
+		api.log.Warnf("env: SLOW_SIM_THRESHOLD_MS - setting builders to low-prio when their last %d simulations took more than %dms on average", slowSimWindow, slowSimThresholdMs)
+	}
+
+	if submissionLogSampleRate > 1 {
+		api.log.Infof("env: SUBMISSION_LOG_SAMPLE_RATE - logging details of 1 in %d block submissions", submissionLogSampleRate)
 	}
 
 	if os.Getenv("FORCE_GET_HEADER_204") == "1" {
 		api.log.Warn("env: FORCE_GET_HEADER_204 - forcing getHeader to always return 204")
-		api.ffForceGetHeader204 = true
+		api.ffForceGetHeader204.Store(true)
 	}
 
 	if os.Getenv("DISABLE_BLOCK_PUBLISHING") == "1" {
 		api.log.Warn("env: DISABLE_BLOCK_PUBLISHING - disabling publishing blocks on getPayload")
-		api.ffDisableBlockPublishing = true
+		api.ffDisableBlockPublishing.Store(true)
 	}
 
 	if os.Getenv("DISABLE_LOWPRIO_BUILDERS") == "1" {
 		api.log.Warn("env: DISABLE_LOWPRIO_BUILDERS - allowing only high-level builders")
-		api.ffDisableLowPrioBuilders = true
+		api.ffDisableLowPrioBuilders.Store(true)
+	}
+
+	if allowlistFile := os.Getenv("PROPOSER_ALLOWLIST_FILE"); allowlistFile != "" {
+		api.proposerAllowlistFile = allowlistFile
+		numProposers, err := api.reloadProposerAllowlist()
+		if err != nil {
+			return nil, err
+		}
+		api.log.Warnf("env: PROPOSER_ALLOWLIST_FILE - only serving %d allowlisted proposers", numProposers)
+	}
+
+	api.builderAllowlistFile = os.Getenv("BUILDER_ALLOWLIST_FILE")
+	api.builderDenylistFile = os.Getenv("BUILDER_DENYLIST_FILE")
+	if api.builderAllowlistFile != "" || api.builderDenylistFile != "" {
+		numAllowed, numDenied, err := api.reloadBuilderLists()
+		if err != nil {
+			return nil, err
+		}
+		if api.builderAllowlistFile != "" {
+			api.log.Warnf("env: BUILDER_ALLOWLIST_FILE - only accepting submissions of %d allowlisted builders", numAllowed)
+		}
+		if api.builderDenylistFile != "" {
+			api.log.Warnf("env: BUILDER_DENYLIST_FILE - rejecting submissions of %d denylisted builders", numDenied)
+		}
+	}
+
+	if os.Getenv("STRICT_FEE_RECIPIENT") == "1" {
+		api.log.Warn("env: STRICT_FEE_RECIPIENT - rejecting submissions where the payload fee recipient differs from the proposer fee recipient")
+		api.ffStrictFeeRecipient = true
+	}
+
+	if os.Getenv("STRICT_SUBMISSION_JSON") == "1" {
+		api.log.Warn("env: STRICT_SUBMISSION_JSON - rejecting block submissions with unknown JSON fields")
+		api.ffStrictJSONDecoding = true
+	}
+
+	if os.Getenv("OPTIMISTIC_RECHECK_DEMOTION") == "1" {
+		api.log.Warn("env: OPTIMISTIC_RECHECK_DEMOTION - re-checking builder demotion status in the database before optimistic processing")
+		api.ffRecheckDemotion = true
+	}
+
+	if os.Getenv("ENABLE_ACTIVE_VALIDATORS_API") == "1" {
+		api.log.Warn("env: ENABLE_ACTIVE_VALIDATORS_API - serving the list of active validators in the data API")
+		api.ffEnableActiveValidatorsAPI = true
+	}
+
+	if minCollateralStr := os.Getenv("MIN_COLLATERAL_WEI"); minCollateralStr != "" {
+		minCollateral := new(types.U256Str)
+		if err := minCollateral.UnmarshalText([]byte(minCollateralStr)); err != nil {
+			return nil, fmt.Errorf("invalid MIN_COLLATERAL_WEI: %w", err)
+		}
+		api.log.Warnf("env: MIN_COLLATERAL_WEI - rejecting submissions from builders with collateral below %s wei", minCollateral.String())
+		api.minCollateral = minCollateral
+	}
+
+	if maxCollateralStr := os.Getenv("MAX_COLLATERAL_WEI"); maxCollateralStr != "" {
+		maxCollateral := new(types.U256Str)
+		if err := maxCollateral.UnmarshalText([]byte(maxCollateralStr)); err != nil {
+			return nil, fmt.Errorf("invalid MAX_COLLATERAL_WEI: %w", err)
+		}
+		api.log.Warnf("env: MAX_COLLATERAL_WEI - crediting builders with at most %s wei collateral", maxCollateral.String())
+		api.maxCollateral = maxCollateral
+	}
+
+	api.maxBidValue = new(types.U256Str)
+	if err := api.maxBidValue.UnmarshalText([]byte(defaultMaxBidWei)); err != nil {
+		return nil, err
+	}
+	if maxBidStr := os.Getenv("MAX_BID_WEI"); maxBidStr != "" {
+		if err := api.maxBidValue.UnmarshalText([]byte(maxBidStr)); err != nil {
+			return nil, fmt.Errorf("invalid MAX_BID_WEI: %w", err)
+		}
+		api.log.Warnf("env: MAX_BID_WEI - rejecting submissions with a value above %s wei", api.maxBidValue.String())
+	}
+
+	api.topBidTieBreak = datastore.TieBreakFirstSeen
+	if tieBreak := datastore.TieBreakPolicy(os.Getenv("TOP_BID_TIE_BREAK")); tieBreak != "" {
+		switch tieBreak {
+		case datastore.TieBreakFirstSeen, datastore.TieBreakHighPrioFirst, datastore.TieBreakIncumbent:
+		default:
+			return nil, fmt.Errorf("invalid TOP_BID_TIE_BREAK: %s", tieBreak)
+		}
+		api.log.Warnf("env: TOP_BID_TIE_BREAK - breaking ties between top bids with the %s policy", tieBreak)
+		api.topBidTieBreak = tieBreak
+	}
+
+	if os.Getenv("BUILD_INFO_HEADER") == "1" {
+		api.log.Warn("env: BUILD_INFO_HEADER - setting the build info in all response headers")
+		api.ffBuildInfoHeader = true
+	}
+
+	if os.Getenv("STORE_SIM_ERROR_RESPONSE") == "1" {
+		api.log.Warn("env: STORE_SIM_ERROR_RESPONSE - storing the simulation node response with builder demotions")
+		api.ffStoreSimErrorResponse = true
+	}
+
+	if os.Getenv("VERIFY_GETPAYLOAD_BODY_ROOT") == "1" {
+		api.log.Warn("env: VERIFY_GETPAYLOAD_BODY_ROOT - verifying the reconstructed block body root in getPayload")
+		api.ffVerifyPayloadBodyRoot = true
+	}
+
+	if os.Getenv("VERIFY_BLOCK_HASH_OPTIMISTIC") == "1" {
+		api.log.Warn("env: VERIFY_BLOCK_HASH_OPTIMISTIC - verifying the block hash of optimistic submissions before they become eligible")
+		api.ffVerifyBlockHashOptimistic = true
+	}
+
+	if os.Getenv("EXPLICIT_BID_CANCELLATIONS") == "1" {
+		api.log.Warn("env: EXPLICIT_BID_CANCELLATIONS - builders can only lower their own top bid with cancellations=1")
+		api.ffExplicitCancellations = true
+	}
+
+	if os.Getenv("RECORD_PARENT_BLOCK") == "1" {
+		api.log.Warn("env: RECORD_PARENT_BLOCK - recording the parent block timestamp and gas limit of block submissions")
+		api.ffRecordParentBlock = true
+	}
+
+	if os.Getenv("VERIFY_BLOCK_HASH") == "1" {
+		api.log.Warn("env: VERIFY_BLOCK_HASH - recomputing the block hash of submissions before simulation")
+		api.ffVerifyBlockHash = true
+	}
+
+	if os.Getenv("VERIFY_SIG_EARLY") == "1" {
+		api.log.Warn("env: VERIFY_SIG_EARLY - verifying the builder signature of submissions right after decoding")
+		api.ffVerifySigEarly = true
+	}
+
+	if os.Getenv("ASYNC_VALIDATOR_REGISTRATION") == "1" {
+		api.log.Warn("env: ASYNC_VALIDATOR_REGISTRATION - responding to validator registrations before verifying and saving them")
+		api.ffAsyncValidatorRegs = true
+	}
+
+	if os.Getenv("GETHEADER_DB_FALLBACK") == "1" {
+		api.log.Warn("env: GETHEADER_DB_FALLBACK - serving the best bid from the database if there is none in redis")
+		api.ffGetHeaderDBFallback = true
+	}
+
+	if os.Getenv("STORE_RAW_SUBMISSIONS") == "1" {
+		api.log.Warn("env: STORE_RAW_SUBMISSIONS - saving the request body of every block submission to the database")
+		api.ffStoreRawSubmissions = true
 	}
 
 	return api, nil
@@ -253,6 +690,7 @@ func (api *RelayAPI) getRouter() http.Handler {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/", api.handleRoot).Methods(http.MethodGet)
+	r.HandleFunc(pathRelayInfo, api.handleRelayInfo).Methods(http.MethodGet)
 
 	// Proposer API
 	if api.opts.ProposerAPI {
@@ -261,6 +699,7 @@ func (api *RelayAPI) getRouter() http.Handler {
 		r.HandleFunc(pathRegisterValidator, api.handleRegisterValidator).Methods(http.MethodPost)
 		r.HandleFunc(pathGetHeader, api.handleGetHeader).Methods(http.MethodGet)
 		r.HandleFunc(pathGetPayload, api.handleGetPayload).Methods(http.MethodPost)
+		r.HandleFunc(pathProposerDuties, api.handleProposerDuties).Methods(http.MethodGet)
 	}
 
 	// Builder API
@@ -268,6 +707,7 @@ func (api *RelayAPI) getRouter() http.Handler {
 		api.log.Info("block builder API enabled")
 		r.HandleFunc(pathBuilderGetValidators, api.handleBuilderGetValidators).Methods(http.MethodGet)
 		r.HandleFunc(pathSubmitNewBlock, api.handleSubmitNewBlock).Methods(http.MethodPost)
+		r.HandleFunc(pathBuilderPrevRandao, api.handleBuilderPrevRandao).Methods(http.MethodGet)
 	}
 
 	// Data API
@@ -276,6 +716,13 @@ func (api *RelayAPI) getRouter() http.Handler {
 		r.HandleFunc(pathDataProposerPayloadDelivered, api.handleDataProposerPayloadDelivered).Methods(http.MethodGet)
 		r.HandleFunc(pathDataBuilderBidsReceived, api.handleDataBuilderBidsReceived).Methods(http.MethodGet)
 		r.HandleFunc(pathDataValidatorRegistration, api.handleDataValidatorRegistration).Methods(http.MethodGet)
+		r.HandleFunc(pathDataRegistrationHistory, api.handleDataValidatorRegistrationHistory).Methods(http.MethodGet)
+		r.HandleFunc(pathDataFeeRecipientChanges, api.handleDataFeeRecipientChanges).Methods(http.MethodGet)
+		r.HandleFunc(pathDataSlotWinner, api.handleDataSlotWinner).Methods(http.MethodGet)
+		r.HandleFunc(pathDataBuilderStats, api.handleDataBuilderStats).Methods(http.MethodGet)
+		if api.ffEnableActiveValidatorsAPI {
+			r.HandleFunc(pathDataActiveValidators, api.handleDataActiveValidators).Methods(http.MethodGet)
+		}
 	}
 
 	// Pprof
@@ -287,12 +734,37 @@ func (api *RelayAPI) getRouter() http.Handler {
 	// /internal/...
 	if api.opts.InternalAPI {
 		api.log.Info("internal API enabled")
+		r.HandleFunc(pathInternalBuilders, api.handleInternalBuilders).Methods(http.MethodGet)
 		r.HandleFunc(pathInternalBuilderStatus, api.handleInternalBuilderStatus).Methods(http.MethodGet, http.MethodPost, http.MethodPut)
 		r.HandleFunc(pathInternalBuilderCollateral, api.handleInternalBuilderCollateral).Methods(http.MethodPost, http.MethodPut)
+		r.HandleFunc(pathInternalBuilderRefresh, api.handleInternalBuilderRefresh).Methods(http.MethodPost)
+		r.HandleFunc(pathInternalRejectedSubmissions, api.handleInternalRejectedSubmissions).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalSubmissionStats, api.handleInternalSubmissionStats).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalSimulationStats, api.handleInternalSimulationStats).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalOptimisticEnabled, api.handleInternalOptimisticEnabled).Methods(http.MethodPost, http.MethodPut)
+		r.HandleFunc(pathInternalProposerAllowlist, api.handleInternalProposerAllowlistReload).Methods(http.MethodPost, http.MethodPut)
+		r.HandleFunc(pathInternalBuilderLists, api.handleInternalBuilderListsReload).Methods(http.MethodPost, http.MethodPut)
+		r.HandleFunc(pathInternalMetrics, api.handleInternalMetrics).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalPrometheusMetrics, api.handleInternalPrometheusMetrics).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalConfig, api.handleInternalConfig).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalBuildInfo, api.handleInternalBuildInfo).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalPayload, api.handleInternalPayload).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalDemotion, api.handleInternalDemotion).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalDemotions, api.handleInternalDemotions).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalMissedSlots, api.handleInternalMissedSlots).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalFeatureFlags, api.handleInternalFeatureFlags).Methods(http.MethodGet, http.MethodPost, http.MethodPut)
+		r.HandleFunc(pathInternalRawSubmission, api.handleInternalRawSubmission).Methods(http.MethodGet)
+		r.HandleFunc(pathInternalSlotBlocklist, api.handleInternalSlotBlocklist).Methods(http.MethodGet, http.MethodPost, http.MethodDelete)
+		r.HandleFunc(pathInternalFeeRecipients, api.handleInternalFeeRecipients).Methods(http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
 	}
 
 	// r.Use(mux.CORSMethodMiddleware(r))
-	loggedRouter := httplogger.LoggingMiddlewareLogrus(api.log, r)
+	r.Use(api.requestMetricsMiddleware)
+	var handler http.Handler = r
+	if api.ffBuildInfoHeader {
+		handler = api.buildInfoHeaderMiddleware(handler)
+	}
+	loggedRouter := httplogger.LoggingMiddlewareLogrus(api.log, api.requestIDMiddleware(handler))
 	withGz := gziphandler.GzipHandler(loggedRouter)
 	return withGz
 }
@@ -304,7 +776,11 @@ func (api *RelayAPI) StartServer() (err error) {
 	}
 
 	// Get best beacon-node status by head slot, process current slot and start slot updates
-	bestSyncStatus, err := api.beaconClient.BestSyncStatus()
+	var bestSyncStatus *beaconclient.SyncStatusPayloadData
+	err = api.retryBeaconStartup("sync status", func() (err error) {
+		bestSyncStatus, err = api.beaconClient.BestSyncStatus()
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -312,15 +788,18 @@ func (api *RelayAPI) StartServer() (err error) {
 	// Initialize block builder cache.
 	api.blockBuildersCache = make(map[string]*blockBuilderCacheEntry)
 
-	api.genesisInfo, err = api.beaconClient.GetGenesis()
+	err = api.retryBeaconStartup("genesis", func() (err error) {
+		api.genesisInfo, err = api.beaconClient.GetGenesis()
+		return err
+	})
 	if err != nil {
 		return err
 	}
 	api.log.Infof("genesis info: %d", api.genesisInfo.Data.GenesisTime)
 
-	// start things for the block-builder API
-	if api.opts.BlockBuilderAPI {
-		// Get current proposer duties blocking before starting, to have them ready
+	// Get current proposer duties blocking before starting, to have them ready. The proposer API needs them to know
+	// whether it is ready to serve bids.
+	if api.opts.BlockBuilderAPI || api.opts.ProposerAPI {
 		api.updateProposerDuties(bestSyncStatus.HeadSlot)
 	}
 
@@ -340,21 +819,46 @@ func (api *RelayAPI) StartServer() (err error) {
 		for i := 0; i < numValidatorRegProcessors; i++ {
 			go api.startValidatorRegistrationDBProcessor()
 		}
+
+		// Start the processors of the registration calls answered before processing
+		if api.ffAsyncValidatorRegs {
+			api.log.Infof("starting %d async validator registration processors", numAsyncValidatorRegProcessors)
+			for i := 0; i < numAsyncValidatorRegProcessors; i++ {
+				go api.startAsyncValidatorRegProcessor()
+			}
+		}
+	}
+
+	// Periodically save metrics snapshots to the database
+	if metricsSnapshotIntervalSec > 0 {
+		go api.startMetricsSnapshotWriter(time.Duration(metricsSnapshotIntervalSec) * time.Second)
 	}
 
 	// Process current slot
 	api.processNewSlot(bestSyncStatus.HeadSlot)
 
 	// Start regular slot updates
+	headEventC := make(chan beaconclient.HeadEventData)
+	api.lastHeadEventAt.Store(time.Now().UnixMilli())
 	go func() {
-		c := make(chan beaconclient.HeadEventData)
-		api.beaconClient.SubscribeToHeadEvents(c)
+		api.subscribeToHeadEvents(headEventC)
 		for {
-			headEvent := <-c
+			headEvent := <-headEventC
+			api.lastHeadEventAt.Store(time.Now().UnixMilli())
 			api.processNewSlot(headEvent.Slot)
 		}
 	}()
 
+	// Resubscribe if head events stop arriving, e.g. after a beacon node restart
+	if headEventWatchdogSlots > 0 {
+		go api.startHeadEventWatchdog(headEventC)
+	}
+
+	// Pick up slots blocklisted through other instances within the slot
+	if slotBlocklistRefreshMs > 0 {
+		go api.startSlotBlocklistUpdates(time.Duration(slotBlocklistRefreshMs) * time.Millisecond)
+	}
+
 	api.srv = &http.Server{
 		Addr:    api.opts.ListenAddr,
 		Handler: api.getRouter(),
@@ -372,13 +876,119 @@ func (api *RelayAPI) StartServer() (err error) {
 	return err
 }
 
+// startHeadEventWatchdog checks the age of the latest head event once per slot
+func (api *RelayAPI) startHeadEventWatchdog(headEventC chan beaconclient.HeadEventData) {
+	ticker := time.NewTicker(api.opts.EthNetDetails.SlotDuration())
+	defer ticker.Stop()
+	for range ticker.C {
+		api.checkHeadEvents(headEventC, time.Now())
+	}
+}
+
+// subscribeToHeadEvents subscribes to the head events of all beacon nodes, and ends the previous subscription
+func (api *RelayAPI) subscribeToHeadEvents(headEventC chan beaconclient.HeadEventData) {
+	ctx, cancel := context.WithCancel(context.Background())
+	api.cancelHeadEventsLock.Lock()
+	if api.cancelHeadEvents != nil {
+		api.cancelHeadEvents()
+	}
+	api.cancelHeadEvents = cancel
+	api.cancelHeadEventsLock.Unlock()
+	api.beaconClient.SubscribeToHeadEvents(ctx, headEventC)
+}
+
+// checkHeadEvents resubscribes to head events if none was received for headEventWatchdogSlots slot durations,
+// and meanwhile polls the beacon node for the head slot so the relay doesn't go stale
+func (api *RelayAPI) checkHeadEvents(headEventC chan beaconclient.HeadEventData, now time.Time) {
+	maxAge := time.Duration(headEventWatchdogSlots) * api.opts.EthNetDetails.SlotDuration()
+	age := now.Sub(time.UnixMilli(api.lastHeadEventAt.Load()))
+	if age < maxAge {
+		return
+	}
+
+	log := api.log.WithFields(logrus.Fields{
+		"lastHeadEventAgeMs": age.Milliseconds(),
+		"headSlot":           api.headSlot.Load(),
+	})
+
+	// Don't stack up subscriptions while the beacon node is still unavailable
+	if now.Sub(api.lastHeadResubscribedAt) >= maxAge {
+		log.Warn("no recent head event, resubscribing to head events")
+		api.lastHeadResubscribedAt = now
+		api.subscribeToHeadEvents(headEventC)
+	}
+
+	syncStatus, err := api.beaconClient.BestSyncStatus()
+	if err != nil {
+		log.WithError(err).Error("no recent head event, failed to poll the beacon node sync status")
+		return
+	}
+	log.WithField("polledHeadSlot", syncStatus.HeadSlot).Info("no recent head event, polled head slot from the beacon node")
+	api.processNewSlot(syncStatus.HeadSlot)
+}
+
+// getBestBidFromDB signs the best bid of the saved block submissions for a slot, and saves its bid trace to redis again
+// so the payload can be delivered. The execution payload is read from the database on getPayload.
+func (api *RelayAPI) getBestBidFromDB(slot uint64, parentHash, proposerPubkey string) (*types.GetHeaderResponse, error) {
+	entry, err := api.db.GetBestBlockSubmissionEntry(slot, parentHash, proposerPubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadEntry, err := api.db.GetExecutionPayloadEntryByID(entry.ExecutionPayloadID.Int64)
+	if err != nil {
+		return nil, err
+	}
+
+	executionPayload := new(types.ExecutionPayload)
+	err = json.Unmarshal([]byte(payloadEntry.Payload), executionPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	bidTrace, err := database.BuilderSubmissionEntryToBidTraceV2(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	signedBuilderBid, err := ExecutionPayloadToSignedBuilderBid(executionPayload, bidTrace.Value, api.signer, api.opts.EthNetDetails.DomainBuilder)
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.redis.SaveBidTrace(bidTrace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.GetHeaderResponse{
+		Version: types.VersionString(payloadEntry.Version),
+		Data:    signedBuilderBid,
+	}, nil
+}
+
+// retryBeaconStartup retries a beacon node request needed to start the server, in case the beacon node is restarting
+func (api *RelayAPI) retryBeaconStartup(name string, fn func() error) error {
+	attempt := 0
+	backoff := time.Duration(beaconStartupRetryBackoffMs) * time.Millisecond
+	return retryWithBackoff(beaconStartupRetries, backoff, func() error {
+		attempt++
+		err := fn()
+		if err != nil {
+			api.log.WithError(err).WithField("attempt", attempt).Warnf("failed to get %s from the beacon node", name)
+		}
+		return err
+	})
+}
+
 // StopServer disables sending any bids on getHeader calls, waits a few seconds to catch any remaining getPayload call, and then shuts down the webserver
 func (api *RelayAPI) StopServer() (err error) {
 	api.log.Info("Stopping server...")
 
 	if api.opts.ProposerAPI {
-		// stop sending bids
-		api.ffForceGetHeader204 = true
+		// stop sending bids, and don't let the runtime flags from redis enable them again
+		api.srvStopping.Store(true)
+		api.ffForceGetHeader204.Store(true)
 		api.log.Info("Disabled sending bids, waiting a few seconds...")
 
 		// wait a few seconds, for any pending getPayload call to complete
@@ -388,77 +998,361 @@ func (api *RelayAPI) StopServer() (err error) {
 		api.getPayloadCallsInFlight.Wait()
 	}
 
-	// shutdown
-	return api.srv.Shutdown(context.Background())
+	// wait for the blocks of delivered payloads to be published
+	api.blockPublishesInFlight.Wait()
+
+	// shutdown, no new items are queued after this
+	err = api.srv.Shutdown(context.Background())
+
+	// save what is still queued, bounded by the drain timeout
+	if shutdownDrainTimeoutMs > 0 {
+		remaining := api.drainPendingWrites(time.Duration(shutdownDrainTimeoutMs) * time.Millisecond)
+		if remaining > 0 {
+			api.log.Warnf("shutdown drain timeout reached, %d queued items not saved", remaining)
+		}
+	}
+	return err
+}
+
+// drainPendingWrites waits for the queued async registration calls, then saves the queued active validators and
+// validator registrations until both channels are empty or the timeout is reached, and returns the number of items left
+// in the channels.
+func (api *RelayAPI) drainPendingWrites(timeout time.Duration) (remaining int) {
+	deadline := time.Now().Add(timeout)
+
+	// The async registration calls still queue registrations
+	asyncRegsDone := make(chan struct{})
+	go func() {
+		api.asyncValidatorRegBatches.Wait()
+		close(asyncRegsDone)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-asyncRegsDone:
+	case <-timer.C:
+		api.log.Warnf("shutdown drain timeout reached, %d queued async validator registration calls not processed", len(api.asyncValidatorRegC))
+	}
+
+	api.log.Infof("saving %d queued active validators and %d queued validator registrations...", len(api.activeValidatorC), len(api.validatorRegC))
+	for time.Now().Before(deadline) {
+		select {
+		case pubkey := <-api.activeValidatorC:
+			api.saveActiveValidator(pubkey)
+		case valReg := <-api.validatorRegC:
+			api.saveValidatorRegistration(valReg)
+		default:
+			return 0
+		}
+	}
+	return len(api.activeValidatorC) + len(api.validatorRegC)
 }
 
 // startActiveValidatorProcessor keeps listening on the channel and saving active validators to redis
 func (api *RelayAPI) startActiveValidatorProcessor() {
 	for pubkey := range api.activeValidatorC {
-		err := api.redis.SetActiveValidator(pubkey)
-		if err != nil {
-			api.log.WithError(err).Infof("error setting active validator")
+		api.saveActiveValidator(pubkey)
+	}
+}
+
+func (api *RelayAPI) saveActiveValidator(pubkey types.PubkeyHex) {
+	err := api.redis.SetActiveValidator(pubkey)
+	if err != nil {
+		api.log.WithError(err).Infof("error setting active validator")
+	}
+}
+
+// sendValidatorRegistration queues the registration for saving. If the channel is full, it waits up to
+// VALIDATOR_REG_CHANNEL_TIMEOUT_MS before dropping the registration, and returns false if it was dropped.
+func (api *RelayAPI) sendValidatorRegistration(valReg types.SignedValidatorRegistration) bool {
+	select {
+	case api.validatorRegC <- valReg:
+		return true
+	default:
+	}
+
+	if validatorRegChannelTimeoutMs > 0 {
+		timer := time.NewTimer(time.Duration(validatorRegChannelTimeoutMs) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case api.validatorRegC <- valReg:
+			return true
+		case <-timer.C:
 		}
 	}
+
+	api.metrics.validatorRegsDropped.Inc()
+	return false
 }
 
 // startActiveValidatorProcessor keeps listening on the channel and saving active validators to redis
 func (api *RelayAPI) startValidatorRegistrationDBProcessor() {
 	for valReg := range api.validatorRegC {
-		err := api.datastore.SaveValidatorRegistration(valReg)
-		if err != nil {
-			api.log.WithError(err).WithFields(logrus.Fields{
-				"reg_pubkey":       valReg.Message.Pubkey,
-				"reg_feeRecipient": valReg.Message.FeeRecipient,
-				"reg_gasLimit":     valReg.Message.GasLimit,
-				"reg_timestamp":    valReg.Message.Timestamp,
-			}).Error("error saving validator registration")
-		}
+		api.saveValidatorRegistration(valReg)
 	}
 }
 
-// simulateBlock sends a request for a block simulation to blockSimRateLimiter.
-func (api *RelayAPI) simulateBlock(opts blockSimOptions) error {
-	t := time.Now()
-	simErr := api.blockSimRateLimiter.send(opts.ctx, opts.req, opts.isHighPrio)
-	log := opts.log.WithFields(logrus.Fields{
-		"duration":   time.Since(t).Seconds(),
-		"numWaiting": api.blockSimRateLimiter.currentCounter(),
-	})
-	if simErr != nil && simErr.Error() != ErrBlockAlreadyKnown {
-		log.WithError(simErr).Error("block validation failed")
-		return simErr
+// startAsyncValidatorRegProcessor keeps listening on the channel and processing the registration calls answered before
+func (api *RelayAPI) startAsyncValidatorRegProcessor() {
+	for processRegistrations := range api.asyncValidatorRegC {
+		processRegistrations()
+		api.asyncValidatorRegBatches.Done()
 	}
-	log.Info("block validation successful")
-	return nil
 }
 
-func (api *RelayAPI) demoteBuilder(pubkey string, req *types.BuilderSubmitBlockRequest, simError error) {
-	builderEntry, ok := api.blockBuildersCache[pubkey]
-	if !ok {
-		api.log.Warnf("builder %v not in the builder cache", pubkey)
-		builderEntry = &blockBuilderCacheEntry{}
+func (api *RelayAPI) saveValidatorRegistration(valReg types.SignedValidatorRegistration) {
+	api.recordFeeRecipientChange(valReg)
+
+	err := api.datastore.SaveValidatorRegistration(valReg)
+	if err != nil {
+		api.log.WithError(err).WithFields(logrus.Fields{
+			"reg_pubkey":       valReg.Message.Pubkey,
+			"reg_feeRecipient": valReg.Message.FeeRecipient,
+			"reg_gasLimit":     valReg.Message.GasLimit,
+			"reg_timestamp":    valReg.Message.Timestamp,
+		}).Error("error saving validator registration")
 	}
-	newStatus := common.BuilderStatus{
-		IsHighPrio:    builderEntry.status.IsHighPrio,
-		IsBlacklisted: builderEntry.status.IsBlacklisted,
-		IsDemoted:     true,
+}
+
+// recordFeeRecipientChange stores a fee recipient change if the registration is newer than the previously
+// stored one and has a different fee recipient, so monitoring can flag suspicious mass changes
+func (api *RelayAPI) recordFeeRecipientChange(valReg types.SignedValidatorRegistration) {
+	pubkey := valReg.Message.Pubkey.String()
+	newFeeRecipient := valReg.Message.FeeRecipient.String()
+	log := api.log.WithFields(logrus.Fields{
+		"reg_pubkey":       pubkey,
+		"reg_feeRecipient": newFeeRecipient,
+		"reg_timestamp":    valReg.Message.Timestamp,
+	})
+
+	prevEntry, err := api.db.GetValidatorRegistration(pubkey)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && prevEntry == nil) {
+		return
+	} else if err != nil {
+		log.WithError(err).Error("error getting previous validator registration")
+		return
 	}
-	api.log.Infof("demoted builder new status: %v", newStatus)
-	if err := api.db.SetBlockBuilderStatus(pubkey, newStatus); err != nil {
-		api.log.Error(fmt.Errorf("error setting builder: %v status: %v", pubkey, err))
+
+	if prevEntry.Timestamp >= valReg.Message.Timestamp || strings.EqualFold(prevEntry.FeeRecipient, newFeeRecipient) {
+		return
 	}
-	// Write to demotions table.
-	api.log.WithFields(logrus.Fields{"builder_pubkey": pubkey}).Info("demoting builder")
-	if err := api.db.InsertBuilderDemotion(req, simError); err != nil {
-		api.log.WithError(err).WithFields(logrus.Fields{
-			"errorWritingDemotionToDB": true,
-			"bidTrace":                 req.Message,
-			"simError":                 simError,
-		}).Error("failed to save demotion to database")
+
+	log.WithField("prevFeeRecipient", prevEntry.FeeRecipient).Warn("validator fee recipient changed")
+	err = api.db.InsertFeeRecipientChange(&database.FeeRecipientChangeEntry{
+		Pubkey:          pubkey,
+		OldFeeRecipient: prevEntry.FeeRecipient,
+		NewFeeRecipient: newFeeRecipient,
+		Timestamp:       valReg.Message.Timestamp,
+	})
+	if err != nil {
+		log.WithError(err).Error("error saving fee recipient change")
 	}
 }
 
+// isLoweringOwnTopBid returns true if the builder of the submission owns the current top bid, and the submission has a lower value
+func (api *RelayAPI) isLoweringOwnTopBid(payload *types.BuilderSubmitBlockRequest) (bool, error) {
+	topBid, err := api.redis.GetBestBid(payload.Message.Slot, payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String())
+	if err != nil || topBid == nil {
+		return false, err
+	}
+
+	latestValue, err := api.redis.GetBuilderLatestValue(payload.Message.Slot, payload.Message.BuilderPubkey.String(), payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String())
+	if err != nil || latestValue == nil {
+		return false, err
+	}
+
+	topValue := topBid.Data.Message.Value.BigInt()
+	return latestValue.Cmp(topValue) == 0 && payload.Message.Value.BigInt().Cmp(topValue) < 0, nil
+}
+
+// simulateBlock sends a request for a block simulation to blockSimRateLimiter, and returns the time spent queued in the
+// rate limiter
+func (api *RelayAPI) simulateBlock(opts blockSimOptions) (time.Duration, error) {
+	ctx := opts.ctx
+	if blockSimTimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(opts.ctx, time.Duration(blockSimTimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	t := time.Now()
+	queueDuration, simErr := api.blockSimRateLimiter.send(ctx, opts.req, opts.isHighPrio)
+	log := opts.log.WithFields(logrus.Fields{
+		"duration":      time.Since(t).Seconds(),
+		"queueDuration": queueDuration.Seconds(),
+		"numWaiting":    api.blockSimRateLimiter.currentCounter(),
+	})
+	if simErr != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && opts.ctx.Err() == nil {
+		// Reported separately, so synchronous submissions can be retried instead of failing the builder
+		log.WithError(simErr).Warn("block validation timed out")
+		return queueDuration, ErrSimulationTimeout
+	}
+	if simErr != nil && simErr.Error() != ErrBlockAlreadyKnown {
+		log.WithError(simErr).Error("block validation failed")
+		return queueDuration, simErr
+	}
+	sampledLog(log, !opts.sampledOut).Info("block validation successful")
+	return queueDuration, nil
+}
+
+// isHighPrioBuilder returns the high-prio status of the builder from the cache, for breaking ties between top bids
+func (api *RelayAPI) isHighPrioBuilder(builderPubkey string) bool {
+	builderEntry, ok := api.getBlockBuilderCacheEntry(builderPubkey)
+	return ok && builderEntry.status.IsHighPrio
+}
+
+// isBuilderDemotedInDB reads the current demotion status of the builder from the database, because the builder cache
+// might not yet reflect a demotion applied during this slot. Errors and timeouts count as demoted.
+func (api *RelayAPI) isBuilderDemotedInDB(pubkey string) bool {
+	resultC := make(chan bool, 1)
+	go func() {
+		builder, err := api.db.GetBlockBuilderByPubkey(pubkey)
+		if err != nil {
+			api.log.WithError(err).WithField("builderPubkey", pubkey).Warn("failed to read builder demotion status")
+			resultC <- true
+			return
+		}
+		resultC <- builder.IsDemoted
+	}()
+
+	select {
+	case isDemoted := <-resultC:
+		return isDemoted
+	case <-time.After(time.Duration(optimisticDemotionCheckTimeoutMs) * time.Millisecond):
+		api.log.WithField("builderPubkey", pubkey).Warn("timeout reading builder demotion status")
+		return true
+	}
+}
+
+// sampleSubmissionLog returns whether the informational logs of a block submission are written, for 1 in every
+// SUBMISSION_LOG_SAMPLE_RATE submissions
+func (api *RelayAPI) sampleSubmissionLog() bool {
+	return submissionLogSampleRate <= 1 || api.submissionLogCounter.Inc()%uint64(submissionLogSampleRate) == 0
+}
+
+// markSubmissionInFlight records the submission as being processed, unless the same block from the same builder was
+// already seen. In that case seen is true, and eligible tells whether the first submission became eligible.
+func (api *RelayAPI) markSubmissionInFlight(key string) (seen, eligible bool) {
+	api.seenSubmissionsLock.Lock()
+	defer api.seenSubmissionsLock.Unlock()
+	if eligible, seen = api.seenSubmissions[key]; seen {
+		return seen, eligible
+	}
+	api.seenSubmissions[key] = false
+	return false, false
+}
+
+func (api *RelayAPI) markSubmissionEligible(key string) {
+	api.seenSubmissionsLock.Lock()
+	api.seenSubmissions[key] = true
+	api.seenSubmissionsLock.Unlock()
+}
+
+func (api *RelayAPI) forgetSubmissionSeen(key string) {
+	api.seenSubmissionsLock.Lock()
+	delete(api.seenSubmissions, key)
+	api.seenSubmissionsLock.Unlock()
+}
+
+func (api *RelayAPI) demoteBuilder(pubkey string, req *types.BuilderSubmitBlockRequest, simError error) {
+	builderEntry, ok := api.getBlockBuilderCacheEntry(pubkey)
+	if !ok {
+		api.log.Warnf("builder %v not in the builder cache", pubkey)
+		builderEntry = &blockBuilderCacheEntry{}
+	}
+	newStatus := common.BuilderStatus{
+		IsHighPrio:    builderEntry.status.IsHighPrio,
+		IsBlacklisted: builderEntry.status.IsBlacklisted,
+		IsDemoted:     true,
+	}
+	api.metrics.builderDemotions.Inc()
+	api.log.Infof("demoted builder new status: %v", newStatus)
+	if err := api.db.SetBlockBuilderStatus(pubkey, newStatus); err != nil {
+		api.log.Error(fmt.Errorf("error setting builder: %v status: %v", pubkey, err))
+	}
+	// Write to demotions table.
+	api.log.WithFields(logrus.Fields{"builder_pubkey": pubkey}).Info("demoting builder")
+	simErrorResponse := ""
+	var simErr *SimulationError
+	if api.ffStoreSimErrorResponse && errors.As(simError, &simErr) {
+		simErrorResponse = truncateUTF8(simErr.RawResponse, maxSimErrorResponseBytes)
+	}
+	if err := api.db.InsertBuilderDemotion(req, simError, simErrorResponse); err != nil {
+		api.log.WithError(err).WithFields(logrus.Fields{
+			"errorWritingDemotionToDB": true,
+			"bidTrace":                 req.Message,
+			"simError":                 simError,
+		}).Error("failed to save demotion to database")
+	}
+}
+
+// recordSimDuration adds the simulation duration (in microseconds) of a submission, and sets a high-prio builder
+// to low-prio if its recent simulations took longer than SLOW_SIM_THRESHOLD_MS on average
+func (api *RelayAPI) recordSimDuration(pubkey string, builderEntry *blockBuilderCacheEntry, duration uint64) {
+	average, full := api.simDurations.add(pubkey, duration)
+	if slowSimThresholdMs <= 0 || !full || !builderEntry.status.IsHighPrio {
+		return
+	}
+	if average <= uint64(slowSimThresholdMs)*1000 {
+		return
+	}
+
+	// Submissions keep arriving until the cache entry is updated, only the first one downgrades the builder
+	api.slowBuilderDowngradesLock.Lock()
+	inFlight := api.slowBuilderDowngrades[pubkey]
+	api.slowBuilderDowngrades[pubkey] = true
+	api.slowBuilderDowngradesLock.Unlock()
+	if !inFlight {
+		go api.downgradeSlowBuilder(pubkey, average)
+	}
+}
+
+// downgradeSlowBuilder sets a builder to low-prio, so its blocks are simulated after those of the high-prio builders.
+// Only the priority of this builder pubkey changes, its demotion status is kept as stored in the database.
+func (api *RelayAPI) downgradeSlowBuilder(pubkey string, average uint64) {
+	defer func() {
+		api.slowBuilderDowngradesLock.Lock()
+		delete(api.slowBuilderDowngrades, pubkey)
+		api.slowBuilderDowngradesLock.Unlock()
+	}()
+
+	log := api.log.WithFields(logrus.Fields{
+		"builderPubkey":    pubkey,
+		"avgSimDurationUs": average,
+	})
+	if err := api.db.SetBlockBuilderHighPrio(pubkey, false); err != nil {
+		log.WithError(err).Error("could not set slow builder to low-prio")
+		return
+	}
+
+	builder, err := api.db.GetBlockBuilderByPubkey(pubkey)
+	if err != nil {
+		log.WithError(err).Error("could not get block builder")
+		return
+	}
+	api.setBlockBuilderCacheEntry(builder)
+	log.Warn("set slow builder to low-prio")
+}
+
+// expireDemotion re-enables a builder for optimistic processing after its demotion expired
+func (api *RelayAPI) expireDemotion(builder *database.BlockBuilderEntry) {
+	log := api.log.WithFields(logrus.Fields{
+		"builderPubkey": builder.BuilderPubkey,
+		"demotedAt":     builder.DemotedAt.Time.UnixMilli(),
+	})
+	newStatus := common.BuilderStatus{
+		IsHighPrio:    builder.IsHighPrio,
+		IsBlacklisted: builder.IsBlacklisted,
+		IsDemoted:     false,
+	}
+	if err := api.db.SetBlockBuilderStatus(builder.BuilderPubkey, newStatus); err != nil {
+		log.WithError(err).Error("could not expire builder demotion")
+		return
+	}
+	builder.IsDemoted = false
+	log.Info("builder demotion expired")
+}
+
 // processOptimisticBlock is called on a new goroutine when a optimistic block
 // needs to be simulated.
 func (api *RelayAPI) processOptimisticBlock(opts blockSimOptions) {
@@ -476,7 +1370,12 @@ func (api *RelayAPI) processOptimisticBlock(opts blockSimOptions) {
 		"optBlocksInFlight": api.optimisticBlocksInFlight,
 	}).Infof("simulating optimistic block with hash: %v", opts.req.BuilderSubmitBlockRequest.Message.BlockHash)
 
-	if simErr := api.simulateBlock(opts); simErr != nil {
+	// The request context is closed as soon as the submission is answered
+	opts.ctx = context.Background()
+
+	// The optimistic bid is already eligible, so a timed out simulation is treated like a failed one: the block was
+	// never validated and the builder is demoted (refundable like any other demotion)
+	if _, simErr := api.simulateBlock(opts); simErr != nil {
 		api.log.WithError(simErr).Error("block simulation failed in processOptimisticBlock, demoting builder")
 
 		// Demote the builder.
@@ -485,38 +1384,55 @@ func (api *RelayAPI) processOptimisticBlock(opts blockSimOptions) {
 }
 
 func (api *RelayAPI) processNewSlot(headSlot uint64) {
-	_apiHeadSlot := api.headSlot.Load()
-	if headSlot <= _apiHeadSlot {
-		return
+	// store the head slot, only one caller (head events or the watchdog) may process a slot, and never an older one
+	var _apiHeadSlot uint64
+	for {
+		_apiHeadSlot = api.headSlot.Load()
+		if headSlot <= _apiHeadSlot {
+			return
+		}
+		if api.headSlot.CompareAndSwap(_apiHeadSlot, headSlot) {
+			break
+		}
 	}
 
 	if _apiHeadSlot > 0 {
 		for s := _apiHeadSlot + 1; s < headSlot; s++ {
 			api.log.WithField("missedSlot", s).Warnf("missed slot: %d", s)
+			api.missedSlots.add(s)
 		}
 	}
 
-	// store the head slot
-	api.headSlot.Store(headSlot)
+	// forget submissions seen for previous slots
+	api.seenSubmissionsLock.Lock()
+	api.seenSubmissions = make(map[string]bool)
+	api.seenSubmissionsLock.Unlock()
+
+	// pick up feature flags, blocklisted slots and fee recipient allowlists changed through any instance
+	go api.updateFeatureFlags()
+	go api.updateSlotBlocklist(headSlot)
+	go api.updateFeeRecipientAllowlists()
+
+	// update proposer duties in the background
+	if api.opts.BlockBuilderAPI || api.opts.ProposerAPI {
+		go api.updateProposerDuties(headSlot)
+	}
 
 	// only for builder-api
 	if api.opts.BlockBuilderAPI {
 		// query the expected prev_randao field
 		go api.updatedExpectedRandao(headSlot)
 
-		// update proposer duties in the background
-		go api.updateProposerDuties(headSlot)
-
 		// update the optimistic slot
 		go api.updateOptimisticSlot(headSlot)
 	}
 
 	// log
-	epoch := headSlot / uint64(common.SlotsPerEpoch)
+	epoch := headSlot / api.opts.EthNetDetails.SlotsInEpoch()
 	api.log.WithFields(logrus.Fields{
 		"epoch":              epoch,
 		"slotHead":           headSlot,
-		"slotStartNextEpoch": (epoch + 1) * uint64(common.SlotsPerEpoch),
+		"slotStartNextEpoch": (epoch + 1) * api.opts.EthNetDetails.SlotsInEpoch(),
 	}).Infof("updated headSlot to %d", headSlot)
 }
 
@@ -527,8 +1443,12 @@ func (api *RelayAPI) updateProposerDuties(headSlot uint64) {
 	}
 	defer api.isUpdatingProposerDuties.Store(false)
 
-	// Update once every 8 slots (or more, if a slot was missed)
-	if headSlot%8 != 0 && headSlot-api.proposerDutiesSlot < 8 {
+	// Update every half epoch (or more, if a slot was missed), at least every slot on networks with a single slot per epoch
+	halfEpoch := api.opts.EthNetDetails.SlotsInEpoch() / 2
+	if halfEpoch == 0 {
+		halfEpoch = 1
+	}
+	if headSlot%halfEpoch != 0 && headSlot-api.proposerDutiesSlot < halfEpoch {
 		return
 	}
 
@@ -541,6 +1461,10 @@ func (api *RelayAPI) updateProposerDuties(headSlot uint64) {
 
 	if err == nil {
 		api.proposerDutiesLock.Lock()
+		// keep the duty of the head slot, its submissions may still be accepted within HEAD_SLOT_GRACE_MS
+		if dutiesMap[headSlot] == nil && api.proposerDutiesMap[headSlot] != nil {
+			dutiesMap[headSlot] = api.proposerDutiesMap[headSlot]
+		}
 		api.proposerDutiesResponse = duties
 		api.proposerDutiesMap = dutiesMap
 		api.proposerDutiesSlot = headSlot
@@ -564,30 +1488,130 @@ func (api *RelayAPI) updateOptimisticSlot(headSlot uint64) {
 	api.optimisticBlocks.Wait()
 	api.optimisticSlot = headSlot + 1
 
+	// Pick up changes to the optimistic setting made through any instance.
+	enabledStr, err := api.redis.GetRelayConfig(datastore.RedisConfigFieldOptimisticEnabled)
+	if err != nil {
+		api.log.WithError(err).Error("unable to read optimistic setting from redis")
+	} else if enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			api.log.WithError(err).Errorf("could not parse optimistic setting: %s", enabledStr)
+		} else if api.optimisticEnabled.Swap(enabled) != enabled {
+			api.log.Infof("optimistic processing enabled: %t", enabled)
+		}
+	}
+
 	builders, err := api.db.GetBlockBuilders()
 	if err != nil {
 		api.log.WithError(err).Error("unable to read block builders from db, not updating builder cache")
 		return
 	}
 	for _, v := range builders {
-		collStr := v.CollateralValue
+		if demotionExpirySlots > 0 && v.IsDemoted && v.DemotedAt.Valid && time.Since(v.DemotedAt.Time) > time.Duration(demotionExpirySlots)*api.opts.EthNetDetails.SlotDuration() {
+			api.expireDemotion(v)
+		}
+
+		api.setBlockBuilderCacheEntry(v)
+	}
+}
+
+// getBlockBuilderCacheEntry returns the cached status and collateral of a builder
+func (api *RelayAPI) getBlockBuilderCacheEntry(pubkey string) (*blockBuilderCacheEntry, bool) {
+	api.blockBuildersCacheLock.RLock()
+	defer api.blockBuildersCacheLock.RUnlock()
+	entry, ok := api.blockBuildersCache[pubkey]
+	return entry, ok
+}
+
+// setBlockBuilderCacheEntry updates the cached status and collateral of a builder from its database entry
+func (api *RelayAPI) setBlockBuilderCacheEntry(v *database.BlockBuilderEntry) {
+	// Try to parse builder collateral string (U256Str) type.
+	var builderCollateral types.U256Str
+	err := builderCollateral.UnmarshalText([]byte(v.CollateralValue))
+	if err != nil {
+		api.log.WithError(err).Error("could not parse builder collateral string")
+		builderCollateral = ZeroU256
+	}
+	if api.maxCollateral != nil && builderCollateral.Cmp(api.maxCollateral) > 0 {
+		builderCollateral = *api.maxCollateral
+	}
+
+	api.blockBuildersCacheLock.Lock()
+	defer api.blockBuildersCacheLock.Unlock()
+	api.blockBuildersCache[v.BuilderPubkey] = &blockBuilderCacheEntry{
+		status: common.BuilderStatus{
+			IsHighPrio:    v.IsHighPrio,
+			IsBlacklisted: v.IsBlacklisted,
+			IsDemoted:     v.IsDemoted,
+		},
+		collateral: builderCollateral,
+	}
+}
+
+// reloadProposerAllowlist reads the proposer allowlist from the configured file and returns the number of proposers
+func (api *RelayAPI) reloadProposerAllowlist() (int, error) {
+	if api.proposerAllowlistFile == "" {
+		return 0, ErrNoProposerAllowlist
+	}
+
+	allowlist, err := readPubkeyList(api.proposerAllowlistFile)
+	if err != nil {
+		return 0, err
+	}
+
+	api.proposerAllowlistLock.Lock()
+	api.proposerAllowlist = allowlist
+	api.proposerAllowlistLock.Unlock()
+	return len(allowlist), nil
+}
+
+// isProposerAllowed returns whether the proposer is served, which is always true if no allowlist is configured
+func (api *RelayAPI) isProposerAllowed(pubkey types.PubkeyHex) bool {
+	api.proposerAllowlistLock.RLock()
+	defer api.proposerAllowlistLock.RUnlock()
+	return api.proposerAllowlist == nil || api.proposerAllowlist[types.NewPubkeyHex(pubkey.String())]
+}
+
+// reloadBuilderLists reads the builder allowlist and denylist from the configured files and returns the number of builders
+// in each. If either file is invalid, the previous lists are kept.
+func (api *RelayAPI) reloadBuilderLists() (numAllowed, numDenied int, err error) {
+	if api.builderAllowlistFile == "" && api.builderDenylistFile == "" {
+		return 0, 0, ErrNoBuilderLists
+	}
 
-		// Try to parse builder collateral string (U256Str) type.
-		var builderCollateral types.U256Str
-		err = builderCollateral.UnmarshalText([]byte(collStr))
+	var allowlist, denylist map[types.PubkeyHex]bool
+	if api.builderAllowlistFile != "" {
+		allowlist, err = readPubkeyList(api.builderAllowlistFile)
 		if err != nil {
-			api.log.WithError(err).Error("could not parse builder collateral string")
-			builderCollateral = ZeroU256
+			return 0, 0, err
 		}
-		api.blockBuildersCache[v.BuilderPubkey] = &blockBuilderCacheEntry{
-			status: common.BuilderStatus{
-				IsHighPrio:    v.IsHighPrio,
-				IsBlacklisted: v.IsBlacklisted,
-				IsDemoted:     v.IsDemoted,
-			},
-			collateral: builderCollateral,
+	}
+	if api.builderDenylistFile != "" {
+		denylist, err = readPubkeyList(api.builderDenylistFile)
+		if err != nil {
+			return 0, 0, err
 		}
 	}
+
+	api.builderListsLock.Lock()
+	api.builderAllowlist = allowlist
+	api.builderDenylist = denylist
+	api.builderListsLock.Unlock()
+	return len(allowlist), len(denylist), nil
+}
+
+// checkBuilderLists returns an error message if submissions of the builder are rejected by the allowlist or denylist
+func (api *RelayAPI) checkBuilderLists(pubkey types.PubkeyHex) string {
+	api.builderListsLock.RLock()
+	defer api.builderListsLock.RUnlock()
+	pubkey = types.NewPubkeyHex(pubkey.String())
+	if api.builderDenylist[pubkey] {
+		return "builder is denylisted"
+	}
+	if api.builderAllowlist != nil && !api.builderAllowlist[pubkey] {
+		return "builder not in allowlist"
+	}
+	return ""
 }
 
 func (api *RelayAPI) startKnownValidatorUpdates() {
@@ -601,14 +1625,62 @@ func (api *RelayAPI) startKnownValidatorUpdates() {
 		}
 
 		// Wait for one epoch (at the beginning, because initially the validators have already been queried)
-		time.Sleep(common.DurationPerEpoch / 2)
+		time.Sleep(api.opts.EthNetDetails.EpochDuration() / 2)
 	}
 }
 
+// requestIDMiddleware reads the X-Request-ID header or generates a new request ID, stores it in the
+// request context and echoes it in the response header
+func (api *RelayAPI) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestID := req.Header.Get(HeaderRequestID)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = generateRequestID()
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+		ctx := context.WithValue(req.Context(), requestIDContextKey{}, requestID)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// requestMetricsMiddleware records the duration and status code of the requests by route, routes without a name
+// are identified by their path template
+func (api *RelayAPI) requestMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sr := newStatusRecorder(w)
+		next.ServeHTTP(sr, req)
+
+		route := ""
+		if currentRoute := mux.CurrentRoute(req); currentRoute != nil {
+			route = currentRoute.GetName()
+			if route == "" {
+				route, _ = currentRoute.GetPathTemplate()
+			}
+		}
+		api.requestMetrics.observe(route, req.Method, sr.code, time.Since(start))
+	})
+}
+
+// buildInfoHeaderMiddleware sets the build of this instance in the response header
+func (api *RelayAPI) buildInfoHeaderMiddleware(next http.Handler) http.Handler {
+	buildInfo := api.opts.BuildInfo.String()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(HeaderRelayBuild, buildInfo)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// getRequestLog returns the logger with the request ID of this request
+func (api *RelayAPI) getRequestLog(req *http.Request) *logrus.Entry {
+	requestID, _ := req.Context().Value(requestIDContextKey{}).(string)
+	return api.log.WithField("requestID", requestID)
+}
+
 func (api *RelayAPI) RespondError(w http.ResponseWriter, code int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	resp := HTTPErrorResp{code, message}
+	resp := HTTPErrorResp{Code: code, Message: message, RequestID: w.Header().Get(HeaderRequestID)}
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		api.log.WithField("response", resp).WithError(err).Error("Couldn't write error response")
 		http.Error(w, "", http.StatusInternalServerError)
@@ -624,7 +1696,27 @@ func (api *RelayAPI) RespondOK(w http.ResponseWriter, response any) {
 	}
 }
 
+// respondGetPayloadSSZ writes the execution payload SSZ encoded, with the fork version in the Eth-Consensus-Version header
+func (api *RelayAPI) respondGetPayloadSSZ(w http.ResponseWriter, log *logrus.Entry, getPayloadResp *types.GetPayloadResponse) {
+	payloadSSZ, err := MarshalExecutionPayloadSSZ(getPayloadResp.Data)
+	if err != nil {
+		log.WithError(err).Error("could not encode execution payload as SSZ, responding with JSON")
+		api.RespondOK(w, getPayloadResp)
+		return
+	}
+
+	w.Header().Set("Content-Type", MediaTypeOctetStream)
+	w.Header().Set(HeaderEthConsensusVersion, string(getPayloadResp.Version))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(payloadSSZ); err != nil {
+		log.WithError(err).Error("could not write SSZ getPayload response")
+	}
+}
+
 func (api *RelayAPI) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if lastHeadEventAt := api.lastHeadEventAt.Load(); lastHeadEventAt > 0 {
+		w.Header().Set(HeaderLastHeadEventAgeMs, strconv.FormatInt(time.Now().UnixMilli()-lastHeadEventAt, 10))
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -637,9 +1729,24 @@ func (api *RelayAPI) handleRoot(w http.ResponseWriter, req *http.Request) {
 	fmt.Fprintf(w, "MEV-Boost Relay API")
 }
 
+func (api *RelayAPI) handleRelayInfo(w http.ResponseWriter, req *http.Request) {
+	resp := RelayInfoResponse{
+		Pubkey:                api.publicKey.String(),
+		Network:               api.opts.EthNetDetails.Name,
+		GenesisForkVersion:    api.opts.EthNetDetails.GenesisForkVersionHex,
+		GenesisValidatorsRoot: api.opts.EthNetDetails.GenesisValidatorsRootHex,
+		DomainBuilder:         hexutil.Encode(api.opts.EthNetDetails.DomainBuilder[:]),
+		DomainBeaconProposer:  hexutil.Encode(api.opts.EthNetDetails.DomainBeaconProposer[:]),
+	}
+	if api.genesisInfo != nil {
+		resp.GenesisTime = api.genesisInfo.Data.GenesisTime
+	}
+	api.RespondOK(w, resp)
+}
+
 func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Request) {
 	ua := req.UserAgent()
-	log := api.log.WithFields(logrus.Fields{
+	log := api.getRequestLog(req).WithFields(logrus.Fields{
 		"method":    "registerValidator",
 		"ua":        ua,
 		"mevBoostV": common.GetMevBoostVersionFromUserAgent(ua),
@@ -653,8 +1760,14 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 	numRegActive := 0
 	numRegNew := 0
 	processingStoppedByError := false
+	isAsync := false
 
 	respondError := func(code int, msg string) {
+		if isAsync {
+			// Already responded, keep processing the other registrations
+			log.Warnf("error: %s", msg)
+			return
+		}
 		processingStoppedByError = true
 		log.Warnf("error: %s", msg)
 		api.RespondError(w, code, msg)
@@ -692,23 +1805,15 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 		return types.PubkeyHex(pubkey), timestampInt, nil
 	}
 
-	// Iterate over the registrations
-	_, err = jsonparser.ArrayEach(body, func(value []byte, dataType jsonparser.ValueType, offset int, _err error) {
-		numRegTotal += 1
-		if processingStoppedByError {
-			return
-		}
-		numRegProcessed += 1
+	// Time spent decoding registrations, to compare the JSON and SSZ encodings
+	var parseDuration time.Duration
 
-		// Extract immediately necessary registration fields
-		pkHex, timestampInt, err := parseRegistration(value)
-		if err != nil {
-			respondError(http.StatusBadRequest, err.Error())
-			return
-		}
+	// processRegistration checks a registration, and only decodes it fully if it is new
+	processRegistration := func(pkHex types.PubkeyHex, timestampInt int64, decodeRegistration func() (*types.SignedValidatorRegistration, error)) {
+		numRegProcessed += 1
 
 		// Add validator pubkey to logs
-		regLog := api.log.WithField("pubkey", pkHex.String())
+		regLog := log.WithField("pubkey", pkHex.String())
 
 		// Ensure registration is not too far in the future
 		registrationTime := time.Unix(timestampInt, 0)
@@ -729,6 +1834,7 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 		select {
 		case api.activeValidatorC <- pkHex:
 		default:
+			api.metrics.activeValidatorsDropped.Inc()
 			regLog.Error("active validator channel full")
 		}
 
@@ -744,9 +1850,8 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 		// Now we have a new registration to process
 		numRegNew += 1
 
-		// JSON-decode the registration now (needed for signature verification)
-		signedValidatorRegistration := new(types.SignedValidatorRegistration)
-		err = json.Unmarshal(value, signedValidatorRegistration)
+		// Decode the registration now (needed for signature verification)
+		signedValidatorRegistration, err := decodeRegistration()
 		if err != nil {
 			regLog.WithError(err).Error("error unmarshalling signed validator registration")
 			respondError(http.StatusBadRequest, fmt.Sprintf("error unmarshalling signed validator registration: %s", err.Error()))
@@ -760,33 +1865,110 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 			respondError(http.StatusBadRequest, fmt.Sprintf("error verifying registerValidator signature: %s", err.Error()))
 			return
 		} else if !ok {
-			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("failed to verify validator signature for %s", signedValidatorRegistration.Message.Pubkey.String()))
+			respondError(http.StatusBadRequest, fmt.Sprintf("failed to verify validator signature for %s", signedValidatorRegistration.Message.Pubkey.String()))
 			return
 		}
 
 		// Save to database
-		select {
-		case api.validatorRegC <- *signedValidatorRegistration:
-		default:
+		if !api.sendValidatorRegistration(*signedValidatorRegistration) {
 			regLog.Error("validator registration channel full")
 		}
-	})
+	}
 
-	if err != nil {
-		respondError(http.StatusBadRequest, "error in traversing json")
+	isSSZ := strings.HasPrefix(req.Header.Get("Content-Type"), MediaTypeOctetStream)
+	var sszRegistrations []*types.SignedValidatorRegistration
+	if isSSZ {
+		// Large operators register many validators at once, SSZ is much cheaper to decode than JSON
+		parseStart := time.Now()
+		sszRegistrations, err = UnmarshalSignedValidatorRegistrationsSSZ(body)
+		parseDuration = time.Since(parseStart)
+		if err != nil {
+			respondError(http.StatusBadRequest, "invalid SSZ encoded registrations")
+			return
+		}
+	} else if api.ffAsyncValidatorRegs && !json.Valid(body) {
+		respondError(http.StatusBadRequest, "invalid JSON")
 		return
 	}
 
-	log = log.WithFields(logrus.Fields{
-		"timeNeededSec":             time.Since(start).Seconds(),
-		"numRegistrations":          numRegTotal,
-		"numRegistrationsActive":    numRegActive,
-		"numRegistrationsProcessed": numRegProcessed,
-		"numRegistrationsNew":       numRegNew,
-		"processingStoppedByError":  processingStoppedByError,
-	})
-	log.Info("validator registrations call processed")
-	w.WriteHeader(http.StatusOK)
+	// processRegistrations returns false if the request body couldn't be traversed
+	processRegistrations := func() bool {
+		if isSSZ {
+			numRegTotal = len(sszRegistrations)
+			for _, registration := range sszRegistrations {
+				if processingStoppedByError {
+					break
+				}
+				processRegistration(registration.Message.Pubkey.PubkeyHex(), int64(registration.Message.Timestamp), func() (*types.SignedValidatorRegistration, error) {
+					return registration, nil
+				})
+			}
+		} else {
+			// Iterate over the registrations
+			_, err := jsonparser.ArrayEach(body, func(value []byte, dataType jsonparser.ValueType, offset int, _err error) {
+				numRegTotal += 1
+				if processingStoppedByError {
+					return
+				}
+
+				// Extract immediately necessary registration fields
+				parseStart := time.Now()
+				pkHex, timestampInt, err := parseRegistration(value)
+				parseDuration += time.Since(parseStart)
+				if err != nil {
+					numRegProcessed += 1
+					respondError(http.StatusBadRequest, err.Error())
+					return
+				}
+
+				processRegistration(pkHex, timestampInt, func() (*types.SignedValidatorRegistration, error) {
+					parseStart := time.Now()
+					defer func() { parseDuration += time.Since(parseStart) }()
+					signedValidatorRegistration := new(types.SignedValidatorRegistration)
+					err := json.Unmarshal(value, signedValidatorRegistration)
+					return signedValidatorRegistration, err
+				})
+			})
+
+			if err != nil {
+				respondError(http.StatusBadRequest, "error in traversing json")
+				return false
+			}
+		}
+
+		log.WithFields(logrus.Fields{
+			"timeNeededSec":             time.Since(start).Seconds(),
+			"parseTimeSec":              parseDuration.Seconds(),
+			"isSSZ":                     isSSZ,
+			"isAsync":                   isAsync,
+			"numRegistrations":          numRegTotal,
+			"numRegistrationsActive":    numRegActive,
+			"numRegistrationsProcessed": numRegProcessed,
+			"numRegistrationsNew":       numRegNew,
+			"processingStoppedByError":  processingStoppedByError,
+		}).Info("validator registrations call processed")
+		return true
+	}
+
+	if api.ffAsyncValidatorRegs {
+		// Respond before the signature checks of large batches exceed the client timeout, errors are only logged. If the
+		// queue is full, the registrations are processed before responding.
+		isAsync = true
+		api.asyncValidatorRegBatches.Add(1)
+		select {
+		case api.asyncValidatorRegC <- func() { processRegistrations() }:
+			w.WriteHeader(http.StatusOK)
+			return
+		default:
+			api.asyncValidatorRegBatches.Done()
+			isAsync = false
+			log.Warn("async validator registration queue full, processing the registrations before responding")
+		}
+	}
+
+	if processRegistrations() {
+		w.WriteHeader(http.StatusOK)
+	}
 }
 
 func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
@@ -795,7 +1977,7 @@ func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
 	parentHashHex := vars["parent_hash"]
 	proposerPubkeyHex := vars["pubkey"]
 	ua := req.UserAgent()
-	log := api.log.WithFields(logrus.Fields{
+	log := api.getRequestLog(req).WithFields(logrus.Fields{
 		"method":     "getHeader",
 		"slot":       slotStr,
 		"parentHash": parentHashHex,
@@ -827,12 +2009,29 @@ func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
 
 	log.Debug("getHeader request received")
 
-	if api.ffForceGetHeader204 {
+	if !api.isProposerAllowed(types.PubkeyHex(proposerPubkeyHex)) {
+		log.Info("proposer not in allowlist")
+		api.RespondError(w, http.StatusForbidden, "proposer not allowed")
+		return
+	}
+
+	if api.ffForceGetHeader204.Load() {
 		log.Info("forced getHeader 204 response")
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	if api.isSlotBlocklisted(slot) {
+		log.Warn("slot is blocklisted, not serving a bid")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if req.URL.Query().Get("top") != "" {
+		api.handleGetHeaderTopBids(w, req, log, slot, parentHashHex, proposerPubkeyHex)
+		return
+	}
+
 	bid, err := api.redis.GetBestBid(slot, parentHashHex, proposerPubkeyHex)
 	if err != nil {
 		log.WithError(err).Error("could not get bid")
@@ -840,7 +2039,25 @@ func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// After losing the redis bids, e.g. on a flush or restart, the submissions are still in the database
+	if (bid == nil || bid.Data == nil) && api.ffGetHeaderDBFallback {
+		bid, err = api.getBestBidFromDB(slot, parentHashHex, proposerPubkeyHex)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.WithError(err).Error("could not get bid from the database")
+			}
+			bid = nil
+		} else {
+			log.Warn("no bid in redis, serving the best bid from the database")
+		}
+	}
+
 	if bid == nil || bid.Data == nil || bid.Data.Message == nil {
+		// Let mev-boost know if there may be a bid once the relay caught up, e.g. right after startup
+		if !api.isReadyForSlot(slot) {
+			log.WithField("headSlot", api.headSlot.Load()).Info("no bid, relay not ready for this slot yet")
+			w.Header().Set(HeaderRetryAfter, getHeaderRetryAfterSeconds)
+		}
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -851,6 +2068,15 @@ func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Polls for an unchanged top bid don't need the bid to be serialized again
+	etag := bidETag(bid)
+	w.Header().Set(HeaderETag, etag)
+	if ifNoneMatch := req.Header.Get(HeaderIfNoneMatch); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		log.Debug("bid not modified")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	log.WithFields(logrus.Fields{
 		"value":     bid.Data.Message.Value.String(),
 		"blockHash": bid.Data.Message.Header.BlockHash.String(),
@@ -858,31 +2084,109 @@ func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
 	api.RespondOK(w, bid)
 }
 
-func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request) {
-	api.getPayloadCallsInFlight.Add(1)
-	defer api.getPayloadCallsInFlight.Done()
+// isReadyForSlot returns whether the relay could have received bids for the slot, which requires the proposer duties to
+// be loaded and the head slot to be at most one slot behind
+func (api *RelayAPI) isReadyForSlot(slot uint64) bool {
+	headSlot := api.headSlot.Load()
+	if headSlot == 0 || slot > headSlot+1 {
+		return false
+	}
 
-	ua := req.UserAgent()
-	log := api.log.WithFields(logrus.Fields{
-		"method":        "getPayload",
-		"ua":            ua,
-		"mevBoostV":     common.GetMevBoostVersionFromUserAgent(ua),
-		"contentLength": req.ContentLength,
-	})
+	api.proposerDutiesLock.RLock()
+	defer api.proposerDutiesLock.RUnlock()
+	return api.proposerDutiesSlot > 0 || api.proposerDutiesMap[slot] != nil
+}
 
-	payload := new(types.SignedBlindedBeaconBlock)
-	if err := json.NewDecoder(req.Body).Decode(payload); err != nil {
-		if strings.Contains(err.Error(), "i/o timeout") {
-			log.WithError(err).Error("getPayload request failed to decode (i/o timeout)")
-		} else {
-			log.WithError(err).Warn("getPayload request failed to decode")
-		}
-		api.RespondError(w, http.StatusBadRequest, err.Error())
+// handleGetHeaderTopBids responds with the latest bids of up to `top` builders, ordered by value
+func (api *RelayAPI) handleGetHeaderTopBids(w http.ResponseWriter, req *http.Request, log *logrus.Entry, slot uint64, parentHashHex, proposerPubkeyHex string) {
+	maxTop := uint64(20)
+	top, err := strconv.ParseUint(req.URL.Query().Get("top"), 10, 64)
+	if err != nil || top == 0 {
+		api.RespondError(w, http.StatusBadRequest, "invalid top argument")
+		return
+	}
+	if top > maxTop {
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum top is %d", maxTop))
 		return
 	}
 
-	slot := payload.Message.Slot
-	blockHash := payload.Message.Body.ExecutionPayloadHeader.BlockHash
+	bids, err := api.redis.GetTopBids(slot, parentHashHex, proposerPubkeyHex, int(top))
+	if err != nil {
+		log.WithError(err).Error("could not get top bids")
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Bids without value are cancellations
+	response := make([]*types.GetHeaderResponse, 0, len(bids))
+	for _, bid := range bids {
+		if bid.Data != nil && bid.Data.Message != nil && bid.Data.Message.Value.Cmp(&ZeroU256) != 0 {
+			response = append(response, bid)
+		}
+	}
+	if len(response) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"numBids": len(response),
+		"value":   response[0].Data.Message.Value.String(),
+	}).Info("top bids delivered")
+	api.RespondOK(w, response)
+}
+
+func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request) {
+	ua := req.UserAgent()
+	log := api.getRequestLog(req).WithFields(logrus.Fields{
+		"method":        "getPayload",
+		"ua":            ua,
+		"mevBoostV":     common.GetMevBoostVersionFromUserAgent(ua),
+		"contentLength": req.ContentLength,
+	})
+
+	if api.getPayloadSem != nil {
+		select {
+		case api.getPayloadSem <- struct{}{}:
+			defer func() { <-api.getPayloadSem }()
+		default:
+			log.Warn("too many concurrent getPayload calls")
+			api.RespondError(w, http.StatusServiceUnavailable, "too many concurrent getPayload calls")
+			return
+		}
+	}
+
+	api.getPayloadCallsInFlight.Add(1)
+	defer api.getPayloadCallsInFlight.Done()
+
+	payload := new(types.SignedBlindedBeaconBlock)
+	var decodeErr error
+	if getPayloadBodyReadTimeoutMs > 0 {
+		// Don't hold a getPayload slot for the full server read timeout if the client stalls
+		body, err := readBodyWithTimeout(req.Body, time.Duration(getPayloadBodyReadTimeoutMs)*time.Millisecond)
+		if errors.Is(err, ErrBodyReadTimeout) {
+			log.Warn("getPayload request body read timed out")
+			api.RespondError(w, http.StatusRequestTimeout, err.Error())
+			return
+		} else if err == nil {
+			err = json.Unmarshal(body, payload)
+		}
+		decodeErr = err
+	} else {
+		decodeErr = json.NewDecoder(req.Body).Decode(payload)
+	}
+	if err := decodeErr; err != nil {
+		if strings.Contains(err.Error(), "i/o timeout") {
+			log.WithError(err).Error("getPayload request failed to decode (i/o timeout)")
+		} else {
+			log.WithError(err).Warn("getPayload request failed to decode")
+		}
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slot := payload.Message.Slot
+	blockHash := payload.Message.Body.ExecutionPayloadHeader.BlockHash
 	log = log.WithFields(logrus.Fields{
 		"slot":      slot,
 		"blockHash": blockHash.String(),
@@ -891,6 +2195,19 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 
 	log.Debug("getPayload request received")
 
+	// Reject requests for slots outside of the head slot window (e.g. replayed ones) before the signature check and lookups
+	if headSlot := api.headSlot.Load(); headSlot > 0 {
+		if slot < headSlot {
+			log.WithField("headSlot", headSlot).Warn("getPayload request for a past slot")
+			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("slot %d is before the head slot %d", slot, headSlot))
+			return
+		} else if slot > headSlot+getPayloadMaxSlotLead {
+			log.WithField("headSlot", headSlot).Warn("getPayload request for a future slot")
+			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("slot %d is too far ahead of the head slot %d", slot, headSlot))
+			return
+		}
+	}
+
 	proposerPubkey, found := api.datastore.GetKnownValidatorPubkeyByIndex(payload.Message.ProposerIndex)
 	if !found {
 		log.Errorf("could not find proposer pubkey for index %d", payload.Message.ProposerIndex)
@@ -900,6 +2217,12 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 
 	log = log.WithField("pubkeyFromIndex", proposerPubkey)
 
+	if !api.isProposerAllowed(proposerPubkey) {
+		log.Info("proposer not in allowlist")
+		api.RespondError(w, http.StatusForbidden, "proposer not allowed")
+		return
+	}
+
 	// Get the proposer pubkey based on the validator index from the payload
 	pk, err := types.HexToPubkey(proposerPubkey.String())
 	if err != nil {
@@ -938,12 +2261,26 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 		}
 	}
 
-	api.RespondOK(w, getPayloadResp)
+	// Ensure the block to publish has the body the proposer signed
+	if api.ffVerifyPayloadBodyRoot {
+		if err := VerifyBlindedBlockPayload(payload, getPayloadResp.Data); err != nil {
+			log.WithError(err).Error("reconstructed beacon block body doesn't match the signed blinded block")
+			api.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), MediaTypeOctetStream) {
+		api.respondGetPayloadSSZ(w, log, getPayloadResp)
+	} else {
+		api.RespondOK(w, getPayloadResp)
+	}
 	log = log.WithFields(logrus.Fields{
 		"numTx":       len(getPayloadResp.Data.Transactions),
 		"blockNumber": payload.Message.Body.ExecutionPayloadHeader.BlockNumber,
 	})
 	log.Info("execution payload delivered")
+	api.metrics.payloadsDelivered.Inc()
 
 	// Save information about delivered payload
 	go func() {
@@ -1025,16 +2362,53 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 	}()
 
 	// Publish the signed beacon block via beacon-node
+	api.blockPublishesInFlight.Add(1)
 	go func() {
-		if api.ffDisableBlockPublishing {
+		defer api.blockPublishesInFlight.Done()
+		if api.ffDisableBlockPublishing.Load() {
 			log.Info("publishing the block is disabled")
 			return
 		}
+		if api.publishDelay > 0 {
+			log.WithField("publishDelayMs", api.publishDelay.Milliseconds()).Info("delaying the publishing of the block")
+			time.Sleep(api.publishDelay)
+		}
 		signedBeaconBlock := SignedBlindedBeaconBlockToBeaconBlock(payload, getPayloadResp.Data)
 		_, _ = api.beaconClient.PublishBlock(signedBeaconBlock) // errors are logged inside
 	}()
 }
 
+func (api *RelayAPI) handleProposerDuties(w http.ResponseWriter, req *http.Request) {
+	var pubkey types.PublicKey
+	if err := pubkey.UnmarshalText([]byte(mux.Vars(req)["pubkey"])); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid pubkey")
+		return
+	}
+
+	registrationTimestamp, err := api.redis.GetValidatorRegistrationTimestamp(pubkey.PubkeyHex())
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting validator registration timestamp")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	slots := []uint64{}
+	api.proposerDutiesLock.RLock()
+	for _, duty := range api.proposerDutiesResponse {
+		if duty.Entry != nil && duty.Entry.Message.Pubkey == pubkey {
+			slots = append(slots, duty.Slot)
+		}
+	}
+	api.proposerDutiesLock.RUnlock()
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	api.RespondOK(w, ProposerDutiesResponse{
+		Pubkey:     pubkey.String(),
+		Slots:      slots,
+		Registered: registrationTimestamp > 0,
+	})
+}
+
 // --------------------
 //  BLOCK BUILDER APIS
 // --------------------
@@ -1063,6 +2437,20 @@ func (api *RelayAPI) updatedExpectedRandao(slot uint64) {
 		return
 	}
 
+	// get the execution block at this slot, which the next block is built upon. If there's no block (i.e. missed slot),
+	// the block number stays unknown and isn't checked.
+	var parentHash string
+	var blockNumber, parentTimestamp, parentGasLimit uint64
+	block, err := api.beaconClient.GetBlock(strconv.FormatUint(slot, 10))
+	if err != nil {
+		api.log.WithField("slot", slot).WithError(err).Warn("failed to get block from beacon node")
+	} else if block != nil {
+		parentHash = block.Data.Message.Body.ExecutionPayload.BlockHash.String()
+		blockNumber = block.Data.Message.Body.ExecutionPayload.BlockNumber + 1
+		parentTimestamp = block.Data.Message.Body.ExecutionPayload.Timestamp
+		parentGasLimit = block.Data.Message.Body.ExecutionPayload.GasLimit
+	}
+
 	// after request, check if still the latest, then update
 	api.expectedPrevRandaoLock.Lock()
 	defer api.expectedPrevRandaoLock.Unlock()
@@ -1071,34 +2459,115 @@ func (api *RelayAPI) updatedExpectedRandao(slot uint64) {
 
 	// update if still the latest
 	if targetSlot >= api.expectedPrevRandao.slot {
+		if targetSlot > api.expectedPrevRandao.slot {
+			api.previousPrevRandao = api.expectedPrevRandao
+		}
 		api.expectedPrevRandao = randaoHelper{
-			slot:       targetSlot, // the retrieved prev_randao is for the next slot
-			prevRandao: randao.Data.Randao,
+			slot:            targetSlot, // the retrieved prev_randao is for the next slot
+			prevRandao:      randao.Data.Randao,
+			parentHash:      parentHash,
+			blockNumber:     blockNumber,
+			parentTimestamp: parentTimestamp,
+			parentGasLimit:  parentGasLimit,
 		}
-		api.log.WithField("slot", slot).Infof("updated expected prev_randao to %s for slot %d", randao.Data.Randao, targetSlot)
+		api.log.WithField("slot", slot).Infof("updated expected prev_randao to %s and block number to %d for slot %d", randao.Data.Randao, blockNumber, targetSlot)
+	}
+}
+
+// handleBuilderPrevRandao returns the prev_randao the relay expects in block submissions, and the slot it is for
+func (api *RelayAPI) handleBuilderPrevRandao(w http.ResponseWriter, req *http.Request) {
+	api.expectedPrevRandaoLock.RLock()
+	expectedRandao := api.expectedPrevRandao
+	api.expectedPrevRandaoLock.RUnlock()
+
+	if expectedRandao.slot == 0 {
+		api.RespondError(w, http.StatusServiceUnavailable, "prev_randao is not known yet")
+		return
 	}
+
+	api.RespondOK(w, PrevRandaoResponse{
+		Slot:       expectedRandao.slot,
+		PrevRandao: expectedRandao.prevRandao,
+	})
 }
 
 func (api *RelayAPI) handleBuilderGetValidators(w http.ResponseWriter, req *http.Request) {
+	args := req.URL.Query()
+
+	// Optional paging, by default all duties are returned
+	var offset, limit uint64
+	var err error
+	if args.Get("offset") != "" {
+		offset, err = strconv.ParseUint(args.Get("offset"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid offset argument")
+			return
+		}
+	}
+	if args.Get("limit") != "" {
+		limit, err = strconv.ParseUint(args.Get("limit"), 10, 64)
+		if err != nil || limit == 0 {
+			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
+			return
+		}
+	}
+
 	api.proposerDutiesLock.RLock()
 	defer api.proposerDutiesLock.RUnlock()
-	api.RespondOK(w, api.proposerDutiesResponse)
+
+	// Let builders know how far ahead they can submit, independent of paging
+	var lookahead uint64
+	headSlot := api.headSlot.Load()
+	for _, duty := range api.proposerDutiesResponse {
+		if duty.Slot > headSlot && duty.Slot-headSlot > lookahead {
+			lookahead = duty.Slot - headSlot
+		}
+	}
+	w.Header().Set(HeaderDutiesLookaheadSlots, strconv.FormatUint(lookahead, 10))
+
+	duties := api.proposerDutiesResponse
+	if offset >= uint64(len(duties)) {
+		duties = duties[:0]
+	} else {
+		duties = duties[offset:]
+	}
+	if limit > 0 && limit < uint64(len(duties)) {
+		duties = duties[:limit]
+	}
+	api.RespondOK(w, duties)
 }
 
+// handleSubmitNewBlock processes block submissions of builders. Rejections are answered with:
+//
+//   - 400 for malformed or invalid submissions
+//   - 403 for builders which are blacklisted or otherwise not allowed to submit
+//   - 409 for stale submissions, for a past slot or a slot whose payload was already delivered, and for resubmissions
+//     of a block which is still being processed
+//   - 422 if the block failed simulation
+//   - 429 if the builder exceeded its submissions for the slot
+//   - 502 or 503 if the simulation node could not be reached or timed out
+//
+// 200 is only returned for accepted submissions, and for resubmissions of an already accepted block (with a reason).
 func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Request) {
 	var pf common.Profile
 	var prevTime, nextTime time.Time
 
 	receivedAt := time.Now().UTC()
 	prevTime = receivedAt
-	log := api.log.WithFields(logrus.Fields{
+	remoteAddr := getRemoteAddr(req, trustedProxyHeader)
+	log := api.getRequestLog(req).WithFields(logrus.Fields{
 		"method":        "submitNewBlock",
 		"contentLength": req.ContentLength,
+		"remoteAddr":    remoteAddr,
 	})
+	logSampled := api.sampleSubmissionLog()
 
 	var err error
 	var r io.Reader = req.Body
-	if req.Header.Get("Content-Encoding") == "gzip" {
+	pf.Encoding = req.Header.Get("Content-Encoding")
+	switch pf.Encoding {
+	case "", "identity":
+	case "gzip":
 		r, err = gzip.NewReader(req.Body)
 		if err != nil {
 			log.WithError(err).Warn("could not create gzip reader")
@@ -1106,6 +2575,23 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 			return
 		}
 		log = log.WithField("gzip-req", true)
+	case "zstd":
+		zr := zstdDecoderPool.Get().(*zstd.Decoder)
+		defer func() {
+			_ = zr.Reset(nil)
+			zstdDecoderPool.Put(zr)
+		}()
+		if err = zr.Reset(req.Body); err != nil {
+			log.WithError(err).Warn("could not reset zstd reader")
+			api.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		r = zr
+		log = log.WithField("zstd-req", true)
+	default:
+		log.WithField("contentEncoding", pf.Encoding).Warn("unsupported content encoding")
+		api.RespondError(w, http.StatusUnsupportedMediaType, "unsupported Content-Encoding: "+pf.Encoding)
+		return
 	}
 
 	nextTime = time.Now().UTC()
@@ -1159,6 +2645,13 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		}
 	}
 
+	// Reject builders by the allowlist and denylist before spending time on the signature
+	if msg := api.checkBuilderLists(bid.BuilderPubkey.PubkeyHex()); msg != "" {
+		log.WithField("builderPubkey", bid.BuilderPubkey.String()).Info("rejecting submission: " + msg)
+		api.RespondError(w, http.StatusForbidden, msg)
+		return
+	}
+
 	ok, err := types.VerifySignature(&bid, api.opts.EthNetDetails.DomainBuilder, bid.BuilderPubkey[:], sig[:])
 	if !ok || err != nil {
 		log.WithError(err).Warn("could not verify builder signature")
@@ -1166,20 +2659,65 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	// Answer retries with the response to the original submission
+	if idempotencyKey := req.Header.Get(HeaderIdempotencyKey); idempotencyKey != "" {
+		builderPubkey := bid.BuilderPubkey.String()
+		cachedResp, err := api.redis.GetSubmitBlockResponse(builderPubkey, idempotencyKey)
+		if err != nil {
+			log.WithError(err).Error("failed to get cached submission response from redis")
+		} else if cachedResp != nil {
+			log.WithField("idempotencyKey", idempotencyKey).Info("returning cached response to retried submission")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(cachedResp.Code)
+			_, _ = w.Write([]byte(cachedResp.Body))
+			return
+		}
+
+		recorder := newResponseRecorder(w)
+		w = recorder
+		defer func() {
+			// Transient failures are not cached, the retry should be processed again. This includes conflicts with a
+			// first submission of the block which is still being processed.
+			if recorder.code >= http.StatusInternalServerError || recorder.code == http.StatusTooEarly || recorder.code == http.StatusConflict {
+				return
+			}
+			resp := &common.SubmitBlockResponse{Code: recorder.code, Body: recorder.body.String()}
+			if err := api.redis.SaveSubmitBlockResponse(builderPubkey, idempotencyKey, resp); err != nil {
+				log.WithError(err).Error("failed to save submission response to redis")
+			}
+		}()
+	}
+
 	headerOnly := time.Now().UTC()
 	pf.ReadHeader = uint64(headerOnly.Sub(prevTime).Microseconds())
-	log.WithFields(logrus.Fields{
+	sampledLog(log, logSampled).WithFields(logrus.Fields{
 		"bid":          bid,
 		"signature":    sig,
 		"headerTiming": pf.ReadHeader,
 	}).Info("optimistically parsed bid and verified signature")
 
 	// Join the header bytes with the remaining bytes.
-	fullReader := io.MultiReader(&buf, r)
+	var fullReader io.Reader = io.MultiReader(&buf, r)
+
+	// Keep the exact request body for disputes, e.g. about a demotion
+	var rawBody []byte
+	if api.ffStoreRawSubmissions {
+		rawBody, err = io.ReadAll(fullReader)
+		if err != nil {
+			log.WithError(err).Warn("could not read payload")
+			api.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fullReader = bytes.NewReader(rawBody)
+	}
 
 	// Read full request and unmarshal.
 	payload := new(types.BuilderSubmitBlockRequest)
-	if err := json.NewDecoder(fullReader).Decode(payload); err != nil {
+	payloadDecoder := json.NewDecoder(fullReader)
+	if api.ffStrictJSONDecoding {
+		payloadDecoder.DisallowUnknownFields()
+	}
+	if err := payloadDecoder.Decode(payload); err != nil {
 		log.WithError(err).Warn("could not decode payload")
 		api.RespondError(w, http.StatusBadRequest, err.Error())
 		return
@@ -1190,8 +2728,19 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	// Reject forged submissions before any further work
+	if api.ffVerifySigEarly {
+		ok, err = types.VerifySignature(payload.Message, api.opts.EthNetDetails.DomainBuilder, payload.Message.BuilderPubkey[:], payload.Signature[:])
+		if !ok || err != nil {
+			log.WithError(err).Warn("could not verify builder signature")
+			api.RespondError(w, http.StatusBadRequest, "invalid signature")
+			return
+		}
+	}
+
 	nextTime = time.Now().UTC()
 	pf.Decode = uint64(nextTime.Sub(prevTime).Microseconds())
+	api.metrics.submissions.Inc()
 	prevTime = nextTime
 
 	log = log.WithFields(logrus.Fields{
@@ -1200,6 +2749,12 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		"blockHash":     payload.Message.BlockHash.String(),
 	})
 
+	if api.isSlotBlocklisted(payload.Message.Slot) {
+		log.Info("rejecting submission for blocklisted slot")
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("bids for slot %d are disabled", payload.Message.Slot))
+		return
+	}
+
 	// Reject new submissions once the payload for this slot was delivered
 	slotStr, err := api.redis.GetStats(datastore.RedisStatsFieldSlotLastPayloadDelivered)
 	if err != nil && !errors.Is(err, redis.Nil) {
@@ -1210,13 +2765,13 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 			log.WithError(err).Errorf("failed to parse delivered payload slot from redis: %s", slotStr)
 		} else if payload.Message.Slot <= slotLastPayloadDelivered {
 			log.Info("rejecting submission because payload for this slot was already delivered")
-			api.RespondError(w, http.StatusBadRequest, "payload for this slot was already delivered")
+			api.RespondError(w, http.StatusConflict, "payload for this slot was already delivered")
 			return
 		}
 	}
 
 	builderPubkey := payload.Message.BuilderPubkey.String()
-	builderEntry, ok := api.blockBuildersCache[builderPubkey]
+	builderEntry, ok := api.getBlockBuilderCacheEntry(builderPubkey)
 	if !ok {
 		log.Warnf("unable to read builder: %x from the builder cache, using low-prio and no collateral", builderPubkey)
 		builderEntry = &blockBuilderCacheEntry{
@@ -1230,14 +2785,64 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		"builderEntry": builderEntry,
 	})
 
-	// Timestamp check
-	expectedTimestamp := api.genesisInfo.Data.GenesisTime + (payload.Message.Slot * 12)
+	if api.minCollateral != nil && builderEntry.collateral.Cmp(api.minCollateral) < 0 {
+		log.WithFields(logrus.Fields{
+			"builderPubkey": builderPubkey,
+			"collateral":    builderEntry.collateral.String(),
+			"minCollateral": api.minCollateral.String(),
+		}).Info("rejecting builder with insufficient collateral")
+		api.RespondError(w, http.StatusForbidden, "builder collateral below minimum")
+		return
+	}
+
+	// An implausibly high value would, once accepted, block all legitimate bids of the slot
+	if payload.Message.Value.Cmp(api.maxBidValue) > 0 {
+		log.WithFields(logrus.Fields{
+			"builderPubkey": builderPubkey,
+			"value":         payload.Message.Value.String(),
+			"maxBidValue":   api.maxBidValue.String(),
+		}).Warn("rejecting submission with implausible value")
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("value above maximum of %s wei", api.maxBidValue.String()))
+		return
+	}
+
+	// Timestamp check, the beacon node rejects blocks without the exact slot timestamp
+	expectedTimestamp := api.opts.EthNetDetails.SlotTimestamp(api.genesisInfo.Data.GenesisTime, payload.Message.Slot)
 	if payload.ExecutionPayload.Timestamp != expectedTimestamp {
-		log.Warnf("incorrect timestamp. got %d, expected %d", payload.ExecutionPayload.Timestamp, expectedTimestamp)
+		timestampDiff := payload.ExecutionPayload.Timestamp - expectedTimestamp
+		if payload.ExecutionPayload.Timestamp < expectedTimestamp {
+			timestampDiff = expectedTimestamp - payload.ExecutionPayload.Timestamp
+		}
+		if timestampDiff <= timestampToleranceSec {
+			log.WithField("timestampDiffSec", timestampDiff).Infof("timestamp %d within the tolerance, expected %d", payload.ExecutionPayload.Timestamp, expectedTimestamp)
+		} else {
+			log.Warnf("incorrect timestamp. got %d, expected %d", payload.ExecutionPayload.Timestamp, expectedTimestamp)
+		}
 		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("incorrect timestamp. got %d, expected %d", payload.ExecutionPayload.Timestamp, expectedTimestamp))
 		return
 	}
 
+	// Around a fork builders may submit the payload version of the wrong fork, which would only fail in the simulation
+	payloadVersion := string(VersionBellatrix)
+	if version := req.Header.Get(HeaderEthConsensusVersion); version != "" {
+		payloadVersion = strings.ToLower(version)
+	}
+	if expectedVersion := api.opts.EthNetDetails.ForkAtSlot(payload.Message.Slot); payloadVersion != expectedVersion {
+		log.WithField("payloadVersion", payloadVersion).Infof("submitNewBlock failed: payload version does not match the %s fork", expectedVersion)
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("payload version %s does not match the %s fork of slot %d", payloadVersion, expectedVersion, payload.Message.Slot))
+		return
+	}
+
+	// Right after the slot boundary the head may not be confirmed yet, the builder should retry later
+	if submissionAcceptAfterMs > 0 {
+		acceptAfter := time.Unix(int64(expectedTimestamp), 0).Add(-api.opts.EthNetDetails.SlotDuration()).Add(time.Duration(submissionAcceptAfterMs) * time.Millisecond)
+		if receivedAt.Before(acceptAfter) {
+			log.WithField("acceptAfter", acceptAfter.UnixMilli()).Info("submitNewBlock failed: submission too early in the slot")
+			api.RespondError(w, http.StatusTooEarly, "submission too early in the slot")
+			return
+		}
+	}
+
 	nextTime = time.Now().UTC()
 	pf.CacheRead = uint64(nextTime.Sub(prevTime).Microseconds())
 	prevTime = nextTime
@@ -1255,6 +2860,14 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	pf.RandaoLock1 = uint64(nextTime.Sub(prevTime).Microseconds())
 	prevTime = nextTime
 
+	// If the relay head is behind, randao and duties for the slot may not be known yet, the builder should retry
+	headSlot := api.headSlot.Load()
+	if submissionMaxHeadSlotLag >= 0 && payload.Message.Slot > headSlot+1+uint64(submissionMaxHeadSlotLag) {
+		log.WithField("headSlot", headSlot).Warn("submitNewBlock failed: relay not synced to submission slot")
+		api.RespondError(w, http.StatusServiceUnavailable, "relay not synced to this slot yet")
+		return
+	}
+
 	// ensure correct feeRecipient is used
 	api.proposerDutiesLock.RLock()
 	slotDuty := api.proposerDutiesMap[payload.Message.Slot]
@@ -1267,6 +2880,17 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		log.Info("fee recipient does not match")
 		api.RespondError(w, http.StatusBadRequest, "fee recipient does not match")
 		return
+	} else if !api.isFeeRecipientAllowed(payload.Message.ProposerPubkey.String(), payload.Message.ProposerFeeRecipient.String()) {
+		log.Info("fee recipient is not allowed for the proposer")
+		api.RespondError(w, http.StatusBadRequest, "fee recipient is not allowed for the proposer")
+		return
+	}
+
+	// In strict mode, the payload must pay the proposer fee recipient directly
+	if api.ffStrictFeeRecipient && payload.ExecutionPayload.FeeRecipient != payload.Message.ProposerFeeRecipient {
+		log.Info("payload fee recipient does not match proposer fee recipient")
+		api.RespondError(w, http.StatusBadRequest, "payload fee recipient does not match proposer fee recipient")
+		return
 	}
 
 	nextTime = time.Now().UTC()
@@ -1276,15 +2900,15 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	if builderEntry.status.IsBlacklisted {
 		log.Info("builder is blacklisted")
 		time.Sleep(200 * time.Millisecond)
-		w.WriteHeader(http.StatusOK)
+		api.RespondError(w, http.StatusForbidden, "builder is blacklisted")
 		return
 	}
 
 	// In case only high-prio requests are accepted, fail others
-	if api.ffDisableLowPrioBuilders && !builderEntry.status.IsHighPrio {
+	if api.ffDisableLowPrioBuilders.Load() && !builderEntry.status.IsHighPrio {
 		log.Info("rejecting low-prio builder (ff-disable-low-prio-builders)")
 		time.Sleep(200 * time.Millisecond)
-		w.WriteHeader(http.StatusOK)
+		api.RespondError(w, http.StatusForbidden, "low-prio builders are not accepted")
 		return
 	}
 
@@ -1295,16 +2919,38 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		"tx":             len(payload.ExecutionPayload.Transactions),
 	})
 
-	if payload.Message.Slot <= api.headSlot.Load() {
-		api.log.Info("submitNewBlock failed: submission for past slot")
-		api.RespondError(w, http.StatusBadRequest, "submission for past slot")
-		return
+	if payload.Message.Slot <= headSlot {
+		// Head events can arrive slightly before the slot boundary, the head slot itself is accepted for a short while
+		headSlotStart := time.Unix(int64(api.opts.EthNetDetails.SlotTimestamp(api.genesisInfo.Data.GenesisTime, headSlot)), 0)
+		graceEnd := headSlotStart.Add(time.Duration(headSlotGraceMs) * time.Millisecond)
+		if headSlotGraceMs > 0 && payload.Message.Slot == headSlot && receivedAt.Before(graceEnd) {
+			log.WithFields(logrus.Fields{
+				"headSlot": headSlot,
+				"graceEnd": graceEnd.UnixMilli(),
+			}).Info("submitNewBlock: accepting submission for head slot within grace period")
+		} else {
+			log.Info("submitNewBlock failed: submission for past slot")
+			api.RespondError(w, http.StatusConflict, "submission for past slot")
+			return
+		}
 	}
 
 	// Don't accept blocks with 0 value
 	if payload.Message.Value.Cmp(&ZeroU256) == 0 || len(payload.ExecutionPayload.Transactions) == 0 {
-		api.log.Info("submitNewBlock failed: block with 0 value or no txs")
-		w.WriteHeader(http.StatusOK)
+		log.Info("submitNewBlock failed: block with 0 value or no txs")
+		api.RespondError(w, http.StatusBadRequest, "block with 0 value or no transactions")
+		return
+	}
+
+	// Don't accept trivial blocks, if configured
+	if numTxs := len(payload.ExecutionPayload.Transactions); numTxs < minBlockTxCount {
+		log.WithField("numTx", numTxs).Info("submitNewBlock failed: too few transactions")
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("block has %d transactions, the minimum is %d", numTxs, minBlockTxCount))
+		return
+	}
+	if payload.ExecutionPayload.GasUsed < minBlockGasUsed {
+		log.WithField("gasUsed", payload.ExecutionPayload.GasUsed).Info("submitNewBlock failed: too little gas used")
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("block used %d gas, the minimum is %d", payload.ExecutionPayload.GasUsed, minBlockGasUsed))
 		return
 	}
 
@@ -1316,6 +2962,14 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	if api.ffVerifyBlockHash {
+		if err := VerifyBlockHash(payload.ExecutionPayload); err != nil {
+			log.WithError(err).Info("block submission block hash check failed")
+			api.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	nextTime = time.Now().UTC()
 	pf.Checks = uint64(nextTime.Sub(prevTime).Microseconds())
 	prevTime = nextTime
@@ -1323,6 +2977,10 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	// get the latest randao and check again, it might have updated in the meantime)
 	api.expectedPrevRandaoLock.RLock()
 	expectedRandao := api.expectedPrevRandao
+	if expectedRandao.slot != payload.Message.Slot && api.previousPrevRandao.slot == payload.Message.Slot {
+		// a head slot submission within the grace period, the expected prev_randao already moved on to the next slot
+		expectedRandao = api.previousPrevRandao
+	}
 	api.expectedPrevRandaoLock.RUnlock()
 	if expectedRandao.slot != payload.Message.Slot { // we still don't have the prevrandao yet
 		log.Warn("prev_randao is not known yet")
@@ -1335,25 +2993,103 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	// Verify the signature
-	ok, err = types.VerifySignature(payload.Message, api.opts.EthNetDetails.DomainBuilder, payload.Message.BuilderPubkey[:], payload.Signature[:])
-	if !ok || err != nil {
-		log.WithError(err).Warn("could not verify builder signature")
-		api.RespondError(w, http.StatusBadRequest, "invalid signature")
+	// Block number check, only if building on top of the known parent block
+	if expectedRandao.blockNumber > 0 && expectedRandao.parentHash == payload.ExecutionPayload.ParentHash.String() && expectedRandao.blockNumber != payload.ExecutionPayload.BlockNumber {
+		msg := fmt.Sprintf("incorrect block number - got: %d, expected: %d", payload.ExecutionPayload.BlockNumber, expectedRandao.blockNumber)
+		log.Info(msg)
+		api.RespondError(w, http.StatusBadRequest, msg)
 		return
 	}
 
-	var simErr error
+	// Parent block data used in validation, only known if building on top of the expected parent block
+	var parentTimestamp, parentGasLimit uint64
+	if api.ffRecordParentBlock && expectedRandao.parentHash == payload.ExecutionPayload.ParentHash.String() {
+		parentTimestamp = expectedRandao.parentTimestamp
+		parentGasLimit = expectedRandao.parentGasLimit
+	}
+
+	// Verify the signature, unless it was already verified right after decoding
+	if !api.ffVerifySigEarly {
+		ok, err = types.VerifySignature(payload.Message, api.opts.EthNetDetails.DomainBuilder, payload.Message.BuilderPubkey[:], payload.Signature[:])
+		if !ok || err != nil {
+			log.WithError(err).Warn("could not verify builder signature")
+			api.RespondError(w, http.StatusBadRequest, "invalid signature")
+			return
+		}
+	}
+
+	// Only keep raw bodies signed by the builder, the first one saved is served as evidence
+	if rawBody != nil {
+		go func() {
+			err := api.db.SaveRawBlockSubmission(&database.RawBlockSubmissionEntry{
+				Slot:          payload.Message.Slot,
+				BuilderPubkey: payload.Message.BuilderPubkey.String(),
+				BlockHash:     payload.Message.BlockHash.String(),
+				Body:          rawBody,
+			})
+			if err != nil {
+				log.WithError(err).Error("failed to save raw block submission")
+			}
+		}()
+	}
+
+	// With explicit cancellations, lowering the own top bid requires the cancellations flag
+	if api.ffExplicitCancellations && req.URL.Query().Get("cancellations") != "1" {
+		isLowering, err := api.isLoweringOwnTopBid(payload)
+		if err != nil {
+			log.WithError(err).Error("failed getting top bid from redis")
+		} else if isLowering {
+			log.Info("rejecting lower bid by top bid builder without cancellations")
+			api.RespondError(w, http.StatusBadRequest, "bid is lower than your current top bid, use cancellations=1 to replace it")
+			return
+		}
+	}
+
+	var simErr, redisErr error
 	var optimisticSubmission bool
 	var eligibleAt time.Time
 
+	// Skip exact resubmissions of a block, without simulating or saving it again
+	submissionKey := fmt.Sprintf("%d_%s_%s", payload.Message.Slot, builderPubkey, payload.Message.BlockHash.String())
+	if seen, eligible := api.markSubmissionInFlight(submissionKey); eligible {
+		log.Info("skipping duplicate block submission")
+		api.RespondOK(w, SubmissionSkippedResponse{Reason: "duplicate"})
+		return
+	} else if seen {
+		// The first submission may still fail, so the duplicate can't be answered as accepted yet
+		log.Info("rejecting duplicate block submission while the first one is being processed")
+		api.RespondError(w, http.StatusConflict, "the same block is still being processed")
+		return
+	}
+
+	// Allow the builder to retry the block unless it became eligible, the failure might be transient
+	defer func() {
+		if eligibleAt.IsZero() {
+			api.forgetSubmissionSeen(submissionKey)
+		} else {
+			api.markSubmissionEligible(submissionKey)
+		}
+	}()
+
+	// Don't let a single builder monopolize the simulation capacity within a slot
+	if maxBuilderSubmissionsPerSlot > 0 {
+		numSubmissions, err := api.redis.IncrBuilderSubmissionCount(payload.Message.Slot, builderPubkey)
+		if err != nil {
+			log.WithError(err).Error("failed to count builder submissions in redis")
+		} else if numSubmissions > int64(maxBuilderSubmissionsPerSlot) {
+			log.WithField("numSubmissions", numSubmissions).Info("rejecting submission: too many submissions for this slot")
+			api.RespondError(w, http.StatusTooManyRequests, "too many submissions for this slot")
+			return
+		}
+	}
+
 	nextTime = time.Now().UTC()
 	pf.RandaoLock2 = uint64(nextTime.Sub(prevTime).Microseconds())
 	prevTime = nextTime
 
 	// At end of this function, save builder submission to database (in the background)
 	defer func() {
-		submissionEntry, err := api.db.SaveBuilderBlockSubmission(payload, simErr, receivedAt, eligibleAt, pf, optimisticSubmission, payloadFound)
+		submissionEntry, err := api.db.SaveBuilderBlockSubmission(payload, simErr, redisErr, receivedAt, eligibleAt, pf, optimisticSubmission, payloadFound, remoteAddr, parentTimestamp, parentGasLimit)
 		if err != nil {
 			log.WithError(err).WithField("payload", payload).Error("saving builder block submission to database failed")
 			return
@@ -1370,6 +3106,7 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		ctx:        req.Context(),
 		isHighPrio: builderEntry.status.IsHighPrio,
 		log:        log,
+		sampledOut: !logSampled,
 		req: &BuilderBlockValidationRequest{
 			BuilderSubmitBlockRequest: *payload,
 			RegisteredGasLimit:        slotDuty.GasLimit,
@@ -1377,23 +3114,40 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	}
 
 	// With sufficient collateral, process the block optimistically.
-	if builderEntry.collateral.Cmp(&payload.Message.Value) > 0 &&
+	if api.optimisticEnabled.Load() &&
+		builderEntry.collateral.Cmp(&payload.Message.Value) > 0 &&
 		!builderEntry.status.IsDemoted &&
-		payload.Message.Slot == api.optimisticSlot {
+		payload.Message.Slot == api.optimisticSlot &&
+		(!api.ffRecheckDemotion || !api.isBuilderDemotedInDB(builderPubkey)) {
+		// Without a synchronous simulation, a forged block hash would only be caught after the bid is eligible
+		if api.ffVerifyBlockHashOptimistic && !api.ffVerifyBlockHash {
+			simErr = VerifyBlockHash(payload.ExecutionPayload)
+			if simErr != nil {
+				log.WithError(simErr).Info("optimistic block submission block hash check failed")
+				api.RespondError(w, http.StatusBadRequest, simErr.Error())
+				return
+			}
+		}
 		optimisticSubmission = true
 		go api.processOptimisticBlock(opts)
 	} else {
 		// Simulate block (synchronously).
-		simErr = api.simulateBlock(opts)
+		var simQueueDuration time.Duration
+		simQueueDuration, simErr = api.simulateBlock(opts)
+		pf.SimulationQueue = uint64(simQueueDuration.Microseconds())
 		if simErr != nil {
-			api.RespondError(w, http.StatusBadRequest, simErr.Error())
+			api.RespondError(w, simErrorStatusCode(simErr), simErr.Error())
 			return
 		}
 	}
 
+	// Time spent simulating, excluding the time spent waiting for the rate limiter
 	nextTime = time.Now().UTC()
-	pf.Simulation = uint64(nextTime.Sub(prevTime).Microseconds())
+	pf.Simulation = uint64(nextTime.Sub(prevTime).Microseconds()) - pf.SimulationQueue
 	prevTime = nextTime
+	if !optimisticSubmission {
+		api.recordSimDuration(builderPubkey, builderEntry, pf.Simulation)
+	}
 
 	// Ensure this request is still the latest one
 	latestPayloadReceivedAt, err := api.redis.GetBuilderLatestPayloadReceivedAt(payload.Message.Slot, builderPubkey, payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String())
@@ -1401,13 +3155,19 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		log.WithError(err).Error("failed getting latest payload receivedAt from redis")
 	} else if receivedAt.UnixMilli() < latestPayloadReceivedAt {
 		log.Infof("already have a newer payload: now=%d / prev=%d", receivedAt.UnixMilli(), latestPayloadReceivedAt)
-		api.RespondError(w, http.StatusBadRequest, "already using a newer payload")
+		api.RespondError(w, http.StatusConflict, "already using a newer payload")
 		return
 	}
 
 	// Prepare the response data
-	signedBuilderBid, err := BuilderSubmitBlockRequestToSignedBuilderBid(payload, api.blsSk, api.publicKey, api.opts.EthNetDetails.DomainBuilder)
-	if err != nil {
+	signedBuilderBid, err := BuilderSubmitBlockRequestToSignedBuilderBid(payload, api.signer, api.opts.EthNetDetails.DomainBuilder)
+	if errors.Is(err, ErrMissingSigner) || errors.Is(err, ErrSigningFailed) {
+		// The relay cannot sign right now, which is not the builder's fault and worth retrying
+		api.metrics.signingFailures.Inc()
+		log.WithError(err).WithField("alert", "signingUnavailable").Error("could not sign builder bid")
+		api.RespondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	} else if err != nil {
 		log.WithError(err).Error("could not sign builder bid")
 		api.RespondError(w, http.StatusBadRequest, err.Error())
 		return
@@ -1430,37 +3190,27 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	}
 
 	//
-	// Save to Redis
+	// Save to Redis, retrying to get over brief outages. If it still fails, the error is saved with the submission.
 	//
-	// first the trace
-	err = api.redis.SaveBidTrace(&bidTrace)
-	if err != nil {
-		log.WithError(err).Error("failed saving bidTrace in redis")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	// save execution payload (getPayload response)
-	err = api.redis.SaveExecutionPayload(payload.Message.Slot, payload.Message.ProposerPubkey.String(), payload.Message.BlockHash.String(), &getPayloadResponse)
-	if err != nil {
-		log.WithError(err).Error("failed saving execution payload in redis")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
+	retryBackoff := time.Duration(redisSubmissionRetryBackoffMs) * time.Millisecond
 
-	// save this builder's latest bid
-	err = api.redis.SaveLatestBuilderBid(payload.Message.Slot, builderPubkey, payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String(), receivedAt, &getHeaderResponse)
-	if err != nil {
-		log.WithError(err).Error("could not save latest builder bid")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
+	// the trace, execution payload (getPayload response) and this builder's latest bid, in one round trip
+	redisErr = retryWithBackoff(redisSubmissionRetries, retryBackoff, func() error {
+		return api.redis.SaveSubmission(&bidTrace, receivedAt, &getHeaderResponse, &getPayloadResponse)
+	})
+	if redisErr != nil {
+		log.WithError(redisErr).Error("failed saving submission in redis")
+		api.RespondError(w, http.StatusInternalServerError, redisErr.Error())
 		return
 	}
 
 	// recalculate top bid
-	err = api.redis.UpdateTopBid(payload.Message.Slot, payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String())
-	if err != nil {
-		log.WithError(err).Error("could not compute top bid")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
+	redisErr = retryWithBackoff(redisSubmissionRetries, retryBackoff, func() error {
+		return api.redis.UpdateTopBid(payload.Message.Slot, payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String(), api.topBidTieBreak, api.isHighPrioBuilder)
+	})
+	if redisErr != nil {
+		log.WithError(redisErr).Error("could not compute top bid")
+		api.RespondError(w, http.StatusInternalServerError, redisErr.Error())
 		return
 	}
 
@@ -1469,10 +3219,20 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	pf.RedisUpdate = uint64(eligibleAt.Sub(prevTime).Microseconds())
 	pf.Submission = uint64(eligibleAt.Sub(receivedAt).Microseconds())
 
+	// Flag values far above the recent ones, which often indicate a builder bug
+	if api.recentValues != nil && api.recentValues.isValueAnomaly(payload.Message.Value.BigInt(), int64(valueAnomalyFactor)) {
+		api.metrics.valueAnomalies.Inc()
+		log.WithFields(logrus.Fields{
+			"value":  payload.Message.Value.String(),
+			"alert":  "valueAnomaly",
+			"factor": valueAnomalyFactor,
+		}).Warn("submission value exceeds the median of recent values")
+	}
+
 	//
 	// all done
 	//
-	log.WithFields(logrus.Fields{
+	sampledLog(log, logSampled).WithFields(logrus.Fields{
 		"proposerPubkey": payload.Message.ProposerPubkey.String(),
 		"value":          payload.Message.Value.String(),
 		"tx":             len(payload.ExecutionPayload.Transactions),
@@ -1487,9 +3247,45 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 //  INTERNAL APIS
 // ---------------
 
-func (api *RelayAPI) handleInternalBuilderStatus(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	builderPubkey := vars["pubkey"]
+func (api *RelayAPI) handleInternalBuilders(w http.ResponseWriter, req *http.Request) {
+	var err error
+	args := req.URL.Query()
+
+	filters := database.GetBlockBuildersFilters{
+		IsHighPrio:    args.Get("high_prio") == "true",
+		IsBlacklisted: args.Get("blacklisted") == "true",
+		IsDemoted:     args.Get("demoted") == "true",
+	}
+
+	if args.Get("limit") != "" {
+		filters.Limit, err = strconv.ParseUint(args.Get("limit"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
+			return
+		}
+	}
+
+	if args.Get("offset") != "" {
+		filters.Offset, err = strconv.ParseUint(args.Get("offset"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid offset argument")
+			return
+		}
+	}
+
+	builders, err := api.db.GetBlockBuildersWithFilters(filters)
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not get block builders")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.RespondOK(w, builders)
+}
+
+func (api *RelayAPI) handleInternalBuilderStatus(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	builderPubkey := vars["pubkey"]
 
 	if req.Method == http.MethodGet {
 		builderEntry, err := api.db.GetBlockBuilderByPubkey(builderPubkey)
@@ -1499,7 +3295,7 @@ func (api *RelayAPI) handleInternalBuilderStatus(w http.ResponseWriter, req *htt
 				return
 			}
 
-			api.log.WithError(err).Error("could not get block builder")
+			api.getRequestLog(req).WithError(err).Error("could not get block builder")
 			api.RespondError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -1511,7 +3307,7 @@ func (api *RelayAPI) handleInternalBuilderStatus(w http.ResponseWriter, req *htt
 		isHighPrio := args.Get("high_prio") == "true"
 		isBlacklisted := args.Get("blacklisted") == "true"
 		isDemoted := args.Get("demoted") == "true"
-		api.log.WithFields(logrus.Fields{
+		api.getRequestLog(req).WithFields(logrus.Fields{
 			"builderPubkey": builderPubkey,
 			"isHighPrio":    isHighPrio,
 			"isDemoted":     isDemoted,
@@ -1525,7 +3321,7 @@ func (api *RelayAPI) handleInternalBuilderStatus(w http.ResponseWriter, req *htt
 		err := api.db.SetBlockBuilderStatus(builderPubkey, newStatus)
 		if err != nil {
 			err := fmt.Errorf("error setting builder: %v status: %v", builderPubkey, err)
-			api.log.Error(err)
+			api.getRequestLog(req).Error(err)
 			api.RespondError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -1533,6 +3329,27 @@ func (api *RelayAPI) handleInternalBuilderStatus(w http.ResponseWriter, req *htt
 	}
 }
 
+// handleInternalBuilderRefresh re-reads a builder from the database into the builder cache, so that status and
+// collateral changes take effect before the next slot
+func (api *RelayAPI) handleInternalBuilderRefresh(w http.ResponseWriter, req *http.Request) {
+	builderPubkey := mux.Vars(req)["pubkey"]
+	builderEntry, err := api.db.GetBlockBuilderByPubkey(builderPubkey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.RespondError(w, http.StatusBadRequest, "builder not found")
+			return
+		}
+
+		api.getRequestLog(req).WithError(err).Error("could not get block builder")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.setBlockBuilderCacheEntry(builderEntry)
+	api.getRequestLog(req).WithField("builderPubkey", builderPubkey).Info("refreshed builder cache entry")
+	api.RespondOK(w, builderEntry)
+}
+
 func (api *RelayAPI) handleInternalBuilderCollateral(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	builderPubkey := vars["pubkey"]
@@ -1540,11 +3357,20 @@ func (api *RelayAPI) handleInternalBuilderCollateral(w http.ResponseWriter, req
 		args := req.URL.Query()
 		collateralID := args.Get("collateral_id")
 		value := args.Get("value")
-		log := api.log.WithFields(logrus.Fields{
+		log := api.getRequestLog(req).WithFields(logrus.Fields{
 			"pubkey":       builderPubkey,
 			"collateralID": collateralID,
 			"value":        value,
 		})
+
+		// Ensure the collateral value is parseable, so it can be used for optimistic processing
+		var collateral types.U256Str
+		if err := collateral.UnmarshalText([]byte(value)); err != nil {
+			log.WithError(err).Info("invalid collateral value")
+			api.RespondError(w, http.StatusBadRequest, "invalid collateral value")
+			return
+		}
+
 		log.Infof("updating builder collateral")
 		if err := api.db.SetBlockBuilderCollateral(builderPubkey, collateralID, value); err != nil {
 			fullErr := fmt.Errorf("unable to set collateral in db for pubkey: %v: %v", builderPubkey, err)
@@ -1556,6 +3382,646 @@ func (api *RelayAPI) handleInternalBuilderCollateral(w http.ResponseWriter, req
 	}
 }
 
+func (api *RelayAPI) handleInternalOptimisticEnabled(w http.ResponseWriter, req *http.Request) {
+	value := req.URL.Query().Get("value")
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid value argument")
+		return
+	}
+
+	// Persist the setting so other instances pick it up on their next slot.
+	err = api.redis.SetRelayConfig(datastore.RedisConfigFieldOptimisticEnabled, strconv.FormatBool(enabled))
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not save optimistic setting in redis")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.optimisticEnabled.Store(enabled)
+	api.getRequestLog(req).Infof("optimistic processing enabled: %t", enabled)
+	api.RespondOK(w, NilResponse)
+}
+
+// runtimeFeatureFlags returns the feature flags that can be changed at runtime, by their redis config field
+func (api *RelayAPI) runtimeFeatureFlags() map[string]*uberatomic.Bool {
+	return map[string]*uberatomic.Bool{
+		datastore.RedisConfigFieldForceGetHeader204:      &api.ffForceGetHeader204,
+		datastore.RedisConfigFieldDisableBlockPublishing: &api.ffDisableBlockPublishing,
+		datastore.RedisConfigFieldDisableLowPrioBuilders: &api.ffDisableLowPrioBuilders,
+	}
+}
+
+// isSlotBlocklisted returns true if no bids are served for the slot
+func (api *RelayAPI) isSlotBlocklisted(slot uint64) bool {
+	api.slotBlocklistLock.RLock()
+	defer api.slotBlocklistLock.RUnlock()
+	return api.slotBlocklist[slot]
+}
+
+// getBlocklistedSlots returns the slots for which no bids are served, in ascending order
+func (api *RelayAPI) getBlocklistedSlots() []uint64 {
+	api.slotBlocklistLock.RLock()
+	slots := make([]uint64, 0, len(api.slotBlocklist))
+	for slot := range api.slotBlocklist {
+		slots = append(slots, slot)
+	}
+	api.slotBlocklistLock.RUnlock()
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots
+}
+
+// startSlotBlocklistUpdates reloads the slot blocklist from redis on the interval
+func (api *RelayAPI) startSlotBlocklistUpdates(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		api.updateSlotBlocklist(api.headSlot.Load())
+	}
+}
+
+// updateSlotBlocklist applies the slot blocklist saved in redis, and removes the slots before the head slot from it
+func (api *RelayAPI) updateSlotBlocklist(headSlot uint64) {
+	slots, err := api.redis.GetBlocklistedSlots()
+	if err != nil {
+		api.log.WithError(err).Error("unable to read the slot blocklist from redis")
+		return
+	}
+
+	blocklist := make(map[uint64]bool, len(slots))
+	expired := []uint64{}
+	for _, slot := range slots {
+		if slot < headSlot {
+			expired = append(expired, slot)
+		} else {
+			blocklist[slot] = true
+		}
+	}
+	if err := api.redis.RemoveBlocklistedSlots(expired...); err != nil {
+		api.log.WithError(err).Error("unable to remove expired slots from the slot blocklist")
+	}
+
+	api.slotBlocklistLock.Lock()
+	api.slotBlocklist = blocklist
+	api.slotBlocklistLock.Unlock()
+}
+
+// isFeeRecipientAllowed returns false if the proposer has a fee recipient allowlist which doesn't include the fee recipient
+func (api *RelayAPI) isFeeRecipientAllowed(proposerPubkey, feeRecipient string) bool {
+	api.feeRecipientAllowlistLock.RLock()
+	defer api.feeRecipientAllowlistLock.RUnlock()
+	allowlist, found := api.feeRecipientAllowlists[strings.ToLower(proposerPubkey)]
+	return !found || allowlist[strings.ToLower(feeRecipient)]
+}
+
+// getFeeRecipientAllowlist returns the allowed fee recipients of a proposer in ascending order, empty if not restricted
+func (api *RelayAPI) getFeeRecipientAllowlist(proposerPubkey string) []string {
+	api.feeRecipientAllowlistLock.RLock()
+	allowlist := api.feeRecipientAllowlists[proposerPubkey]
+	feeRecipients := make([]string, 0, len(allowlist))
+	for feeRecipient := range allowlist {
+		feeRecipients = append(feeRecipients, feeRecipient)
+	}
+	api.feeRecipientAllowlistLock.RUnlock()
+
+	sort.Strings(feeRecipients)
+	return feeRecipients
+}
+
+// updateFeeRecipientAllowlists applies the fee recipient allowlists saved in redis
+func (api *RelayAPI) updateFeeRecipientAllowlists() {
+	entries, err := api.redis.GetFeeRecipientAllowlists()
+	if err != nil {
+		api.log.WithError(err).Error("unable to read the fee recipient allowlists from redis")
+		return
+	}
+
+	allowlists := make(map[string]map[string]bool, len(entries))
+	for proposerPubkey, feeRecipients := range entries {
+		allowlist := make(map[string]bool, len(feeRecipients))
+		for _, feeRecipient := range feeRecipients {
+			allowlist[feeRecipient] = true
+		}
+		allowlists[proposerPubkey] = allowlist
+	}
+
+	api.feeRecipientAllowlistLock.Lock()
+	api.feeRecipientAllowlists = allowlists
+	api.feeRecipientAllowlistLock.Unlock()
+}
+
+// updateFeatureFlags applies the runtime feature flags saved in redis
+func (api *RelayAPI) updateFeatureFlags() {
+	if api.srvStopping.Load() {
+		return
+	}
+
+	for field, flag := range api.runtimeFeatureFlags() {
+		valueStr, err := api.redis.GetRelayConfig(field)
+		if err != nil {
+			api.log.WithError(err).Errorf("unable to read feature flag %s from redis", field)
+			continue
+		} else if valueStr == "" {
+			continue
+		}
+
+		value, err := strconv.ParseBool(valueStr)
+		if err != nil {
+			api.log.WithError(err).Errorf("could not parse feature flag %s: %s", field, valueStr)
+		} else if flag.Swap(value) != value {
+			api.log.Warnf("feature flag %s changed to %t", field, value)
+		}
+	}
+}
+
+func (api *RelayAPI) handleInternalFeatureFlags(w http.ResponseWriter, req *http.Request) {
+	log := api.getRequestLog(req)
+
+	if req.Method != http.MethodGet {
+		args := req.URL.Query()
+		updates := make(map[string]bool)
+		for arg, field := range map[string]string{
+			"force_204":          datastore.RedisConfigFieldForceGetHeader204,
+			"disable_publishing": datastore.RedisConfigFieldDisableBlockPublishing,
+			"disable_lowprio":    datastore.RedisConfigFieldDisableLowPrioBuilders,
+		} {
+			if args.Get(arg) == "" {
+				continue
+			}
+			value, err := strconv.ParseBool(args.Get(arg))
+			if err != nil {
+				api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s argument", arg))
+				return
+			}
+			updates[field] = value
+		}
+
+		// Persist the flags so other instances pick them up on their next slot.
+		flags := api.runtimeFeatureFlags()
+		for field, value := range updates {
+			err := api.redis.SetRelayConfig(field, strconv.FormatBool(value))
+			if err != nil {
+				log.WithError(err).Errorf("could not save feature flag %s in redis", field)
+				api.RespondError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		for field, value := range updates {
+			flags[field].Store(value)
+			log.Warnf("feature flag %s set to %t", field, value)
+		}
+	}
+
+	api.RespondOK(w, FeatureFlagsResponse{
+		ForceGetHeader204:      api.ffForceGetHeader204.Load(),
+		DisableBlockPublishing: api.ffDisableBlockPublishing.Load(),
+		DisableLowPrioBuilders: api.ffDisableLowPrioBuilders.Load(),
+	})
+}
+
+// handleInternalSlotBlocklist adds (POST) or removes (DELETE) a slot from the slot blocklist, and returns the
+// blocklisted slots. Other instances pick up the change on their next slot.
+func (api *RelayAPI) handleInternalSlotBlocklist(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		slot, err := strconv.ParseUint(req.URL.Query().Get("slot"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, common.ErrInvalidSlot.Error())
+			return
+		}
+
+		log := api.getRequestLog(req).WithField("slot", slot)
+		if req.Method == http.MethodPost {
+			if slot < api.headSlot.Load() {
+				api.RespondError(w, http.StatusBadRequest, "slot is too old")
+				return
+			}
+			err = api.redis.AddBlocklistedSlot(slot)
+		} else {
+			err = api.redis.RemoveBlocklistedSlots(slot)
+		}
+		if err != nil {
+			log.WithError(err).Error("could not update the slot blocklist in redis")
+			api.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		api.slotBlocklistLock.Lock()
+		if req.Method == http.MethodPost {
+			api.slotBlocklist[slot] = true
+			log.Warn("added slot to the blocklist, no bids are served for it")
+		} else {
+			delete(api.slotBlocklist, slot)
+			log.Warn("removed slot from the blocklist")
+		}
+		api.slotBlocklistLock.Unlock()
+	}
+
+	api.RespondOK(w, api.getBlocklistedSlots())
+}
+
+// handleInternalFeeRecipients sets (POST, PUT) or removes (DELETE) the allowed fee recipients of a proposer, and
+// returns them. Other instances pick up the change on their next slot.
+func (api *RelayAPI) handleInternalFeeRecipients(w http.ResponseWriter, req *http.Request) {
+	proposerPubkey := strings.ToLower(mux.Vars(req)["pubkey"])
+	if _, err := types.HexToPubkey(proposerPubkey); err != nil {
+		api.RespondError(w, http.StatusBadRequest, common.ErrInvalidPubkey.Error())
+		return
+	}
+
+	if req.Method != http.MethodGet {
+		allowlist := make(map[string]bool)
+		if req.Method != http.MethodDelete {
+			for _, arg := range strings.Split(req.URL.Query().Get("fee_recipients"), ",") {
+				feeRecipient, err := types.HexToAddress(strings.TrimSpace(arg))
+				if err != nil {
+					api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("invalid fee recipient: %s", arg))
+					return
+				}
+				allowlist[feeRecipient.String()] = true
+			}
+		}
+
+		feeRecipients := make([]string, 0, len(allowlist))
+		for feeRecipient := range allowlist {
+			feeRecipients = append(feeRecipients, feeRecipient)
+		}
+		log := api.getRequestLog(req).WithFields(logrus.Fields{
+			"proposerPubkey": proposerPubkey,
+			"feeRecipients":  feeRecipients,
+		})
+		if err := api.redis.SetFeeRecipientAllowlist(proposerPubkey, feeRecipients); err != nil {
+			log.WithError(err).Error("could not update the fee recipient allowlist in redis")
+			api.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		api.feeRecipientAllowlistLock.Lock()
+		if len(allowlist) > 0 {
+			api.feeRecipientAllowlists[proposerPubkey] = allowlist
+			log.Warn("updated the fee recipient allowlist of the proposer")
+		} else {
+			delete(api.feeRecipientAllowlists, proposerPubkey)
+			log.Warn("removed the fee recipient allowlist of the proposer")
+		}
+		api.feeRecipientAllowlistLock.Unlock()
+	}
+
+	api.RespondOK(w, FeeRecipientAllowlistResponse{
+		ProposerPubkey: proposerPubkey,
+		FeeRecipients:  api.getFeeRecipientAllowlist(proposerPubkey),
+	})
+}
+
+func (api *RelayAPI) handleInternalConfig(w http.ResponseWriter, req *http.Request) {
+	numBlockSimURLs := 0
+	if api.opts.BlockSimURL != "" {
+		numBlockSimURLs = len(strings.Split(api.opts.BlockSimURL, ","))
+	}
+
+	api.RespondOK(w, RelayConfigResponse{
+		ListenAddr:      api.opts.ListenAddr,
+		Network:         api.opts.EthNetDetails.Name,
+		Pubkey:          api.publicKey.String(),
+		NumBlockSimURLs: numBlockSimURLs,
+
+		ProposerAPI:     api.opts.ProposerAPI,
+		BlockBuilderAPI: api.opts.BlockBuilderAPI,
+		DataAPI:         api.opts.DataAPI,
+		PprofAPI:        api.opts.PprofAPI,
+		InternalAPI:     api.opts.InternalAPI,
+
+		FeatureFlags: map[string]bool{
+			"force_get_header_204":         api.ffForceGetHeader204.Load(),
+			"disable_block_publishing":     api.ffDisableBlockPublishing.Load(),
+			"disable_low_prio_builders":    api.ffDisableLowPrioBuilders.Load(),
+			"strict_fee_recipient":         api.ffStrictFeeRecipient,
+			"strict_json_decoding":         api.ffStrictJSONDecoding,
+			"recheck_demotion":             api.ffRecheckDemotion,
+			"enable_active_validators_api": api.ffEnableActiveValidatorsAPI,
+			"verify_block_hash":            api.ffVerifyBlockHash,
+			"verify_block_hash_optimistic": api.ffVerifyBlockHashOptimistic,
+			"explicit_bid_cancellations":   api.ffExplicitCancellations,
+			"verify_getpayload_body_root":  api.ffVerifyPayloadBodyRoot,
+			"build_info_header":            api.ffBuildInfoHeader,
+			"record_parent_block":          api.ffRecordParentBlock,
+			"store_sim_error_response":     api.ffStoreSimErrorResponse,
+			"verify_sig_early":             api.ffVerifySigEarly,
+			"async_validator_registration": api.ffAsyncValidatorRegs,
+			"getheader_db_fallback":        api.ffGetHeaderDBFallback,
+			"store_raw_submissions":        api.ffStoreRawSubmissions,
+		},
+		OptimisticEnabled: api.optimisticEnabled.Load(),
+	})
+}
+
+func (api *RelayAPI) handleInternalBuildInfo(w http.ResponseWriter, req *http.Request) {
+	api.RespondOK(w, api.opts.BuildInfo)
+}
+
+func (api *RelayAPI) handleInternalMetrics(w http.ResponseWriter, req *http.Request) {
+	api.RespondOK(w, api.getMetrics())
+}
+
+// handleInternalPrometheusMetrics returns the request duration histograms in the Prometheus text format
+func (api *RelayAPI) handleInternalPrometheusMetrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	if err := api.requestMetrics.writePrometheus(w); err != nil {
+		api.getRequestLog(req).WithError(err).Error("couldn't write prometheus metrics")
+	}
+}
+
+func (api *RelayAPI) handleInternalProposerAllowlistReload(w http.ResponseWriter, req *http.Request) {
+	numProposers, err := api.reloadProposerAllowlist()
+	if errors.Is(err, ErrNoProposerAllowlist) {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not reload proposer allowlist")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.getRequestLog(req).Infof("reloaded proposer allowlist with %d proposers", numProposers)
+	api.RespondOK(w, NilResponse)
+}
+
+func (api *RelayAPI) handleInternalBuilderListsReload(w http.ResponseWriter, req *http.Request) {
+	numAllowed, numDenied, err := api.reloadBuilderLists()
+	if errors.Is(err, ErrNoBuilderLists) {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not reload builder lists")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.getRequestLog(req).Infof("reloaded builder lists with %d allowlisted and %d denylisted builders", numAllowed, numDenied)
+	api.RespondOK(w, NilResponse)
+}
+
+func (api *RelayAPI) handleInternalRejectedSubmissions(w http.ResponseWriter, req *http.Request) {
+	args := req.URL.Query()
+	limit := uint64(100)
+	maxLimit := uint64(500)
+
+	if args.Get("limit") != "" {
+		_limit, err := strconv.ParseUint(args.Get("limit"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
+			return
+		}
+		if _limit > maxLimit {
+			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum limit is %d", maxLimit))
+			return
+		}
+		limit = _limit
+	}
+
+	rejectedSubmissions, err := api.db.GetRecentRejectedSubmissions(limit)
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting rejected submissions")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]common.RejectedSubmissionJSON, len(rejectedSubmissions))
+	for i, submission := range rejectedSubmissions {
+		response[i] = database.BuilderSubmissionEntryToRejectedSubmissionJSON(submission)
+	}
+
+	api.RespondOK(w, response)
+}
+
+// handleInternalSimulationStats returns the average simulation duration of the recent submissions of each builder
+func (api *RelayAPI) handleInternalSimulationStats(w http.ResponseWriter, req *http.Request) {
+	averages := api.simDurations.averages()
+	response := make([]SimulationStatsEntry, 0, len(averages))
+	for builderPubkey, average := range averages {
+		response = append(response, SimulationStatsEntry{BuilderPubkey: builderPubkey, AvgSimulationUs: average})
+	}
+	sort.Slice(response, func(i, j int) bool { return response[i].AvgSimulationUs > response[j].AvgSimulationUs })
+	api.RespondOK(w, response)
+}
+
+// handleInternalMissedSlots returns the recently missed slots, optionally between the from and to slots (inclusive)
+func (api *RelayAPI) handleInternalMissedSlots(w http.ResponseWriter, req *http.Request) {
+	args := req.URL.Query()
+
+	var slotFrom, slotTo uint64
+	var err error
+	if args.Get("from") != "" {
+		slotFrom, err = strconv.ParseUint(args.Get("from"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid from argument")
+			return
+		}
+	}
+	if args.Get("to") != "" {
+		slotTo, err = strconv.ParseUint(args.Get("to"), 10, 64)
+		if err != nil || slotTo < slotFrom {
+			api.RespondError(w, http.StatusBadRequest, "invalid to argument")
+			return
+		}
+	}
+
+	api.RespondOK(w, api.missedSlots.between(slotFrom, slotTo))
+}
+
+func (api *RelayAPI) handleInternalPayload(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	slot, err := strconv.ParseUint(vars["slot"], 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot")
+		return
+	}
+
+	getPayloadResp, err := api.datastore.GetGetPayloadResponse(slot, vars["proposer_pubkey"], vars["block_hash"])
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && getPayloadResp == nil) {
+		api.RespondError(w, http.StatusNotFound, "no execution payload for this slot, proposer and block hash")
+		return
+	} else if err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not get execution payload")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.RespondOK(w, getPayloadResp)
+}
+
+func (api *RelayAPI) handleInternalRawSubmission(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	slot, err := strconv.ParseUint(vars["slot"], 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot")
+		return
+	}
+
+	var builderPubkey types.PublicKey
+	if err := builderPubkey.UnmarshalText([]byte(vars["builder_pubkey"])); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid builder_pubkey")
+		return
+	}
+	var blockHash types.Hash
+	if err := blockHash.UnmarshalText([]byte(vars["block_hash"])); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid block_hash")
+		return
+	}
+
+	entry, err := api.db.GetRawBlockSubmission(slot, builderPubkey.String(), blockHash.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondError(w, http.StatusNotFound, "no raw submission for this slot, builder and block hash")
+		return
+	} else if err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not get raw block submission")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The body is returned exactly as submitted
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(entry.Body); err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not write raw block submission")
+	}
+}
+
+func (api *RelayAPI) handleInternalDemotion(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	slot, err := strconv.ParseUint(vars["slot"], 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot")
+		return
+	}
+
+	var builderPubkey types.PublicKey
+	if err := builderPubkey.UnmarshalText([]byte(vars["builder_pubkey"])); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid builder_pubkey")
+		return
+	}
+	var blockHash types.Hash
+	if err := blockHash.UnmarshalText([]byte(vars["block_hash"])); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid block_hash")
+		return
+	}
+
+	demotion, err := api.db.GetBuilderDemotion(&types.BidTrace{Slot: slot, BuilderPubkey: builderPubkey, BlockHash: blockHash})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && demotion == nil) {
+		api.RespondError(w, http.StatusNotFound, "no demotion for this slot, builder and block hash")
+		return
+	} else if err != nil {
+		api.getRequestLog(req).WithError(err).Error("could not get builder demotion")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.RespondOK(w, builderDemotionEntryToResponse(demotion))
+}
+
+// handleInternalDemotions returns the most recent demotions, optionally filtered by builder and slot range
+func (api *RelayAPI) handleInternalDemotions(w http.ResponseWriter, req *http.Request) {
+	var err error
+	args := req.URL.Query()
+
+	filters := database.GetBuilderDemotionsFilters{
+		Limit: 100,
+	}
+	maxLimit := uint64(500)
+
+	if args.Get("builder_pubkey") != "" {
+		var builderPubkey types.PublicKey
+		if err := builderPubkey.UnmarshalText([]byte(args.Get("builder_pubkey"))); err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid builder_pubkey argument")
+			return
+		}
+		filters.BuilderPubkey = builderPubkey.String()
+	}
+	if args.Get("slot_from") != "" {
+		filters.SlotFrom, err = strconv.ParseUint(args.Get("slot_from"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid slot_from argument")
+			return
+		}
+	}
+	if args.Get("slot_to") != "" {
+		filters.SlotTo, err = strconv.ParseUint(args.Get("slot_to"), 10, 64)
+		if err != nil || filters.SlotTo == 0 {
+			api.RespondError(w, http.StatusBadRequest, "invalid slot_to argument")
+			return
+		}
+		if filters.SlotFrom > filters.SlotTo {
+			api.RespondError(w, http.StatusBadRequest, "slot_from must not be greater than slot_to")
+			return
+		}
+	}
+	if args.Get("limit") != "" {
+		filters.Limit, err = strconv.ParseUint(args.Get("limit"), 10, 64)
+		if err != nil || filters.Limit == 0 {
+			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
+			return
+		}
+		if filters.Limit > maxLimit {
+			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum limit is %d", maxLimit))
+			return
+		}
+	}
+
+	demotions, err := api.db.GetBuilderDemotions(filters)
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting builder demotions")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]BuilderDemotionResponse, len(demotions))
+	for i, demotion := range demotions {
+		response[i] = builderDemotionEntryToResponse(demotion)
+	}
+	api.RespondOK(w, response)
+}
+
+func (api *RelayAPI) handleInternalSubmissionStats(w http.ResponseWriter, req *http.Request) {
+	args := req.URL.Query()
+
+	slotFrom, err := strconv.ParseUint(args.Get("slot_from"), 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot_from argument")
+		return
+	}
+	slotTo, err := strconv.ParseUint(args.Get("slot_to"), 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot_to argument")
+		return
+	}
+	if slotFrom > slotTo {
+		api.RespondError(w, http.StatusBadRequest, "slot_from must not be greater than slot_to")
+		return
+	}
+	if slotTo-slotFrom > maxDataAPISlotRange {
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum slot range is %d", maxDataAPISlotRange))
+		return
+	}
+
+	stats, err := api.db.GetSubmissionDurationStats(slotFrom, slotTo)
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting submission stats")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.RespondOK(w, SubmissionStatsResponse{
+		SlotFrom:       slotFrom,
+		SlotTo:         slotTo,
+		NumSubmissions: stats.NumSubmissions,
+		Decode:         DurationPercentiles{P50: stats.DecodeP50, P90: stats.DecodeP90, P99: stats.DecodeP99},
+		Simulation:     DurationPercentiles{P50: stats.SimulationP50, P90: stats.SimulationP90, P99: stats.SimulationP99},
+		RedisUpdate:    DurationPercentiles{P50: stats.RedisUpdateP50, P90: stats.RedisUpdateP90, P99: stats.RedisUpdateP99},
+		Submission:     DurationPercentiles{P50: stats.SubmissionP50, P90: stats.SubmissionP90, P99: stats.SubmissionP99},
+	})
+}
+
 // -----------
 //  DATA APIS
 // -----------
@@ -1568,9 +4034,13 @@ func (api *RelayAPI) handleDataProposerPayloadDelivered(w http.ResponseWriter, r
 		Limit: 200,
 	}
 
+	hasSlotRange := args.Get("slot_from") != "" || args.Get("slot_to") != ""
 	if args.Get("slot") != "" && args.Get("cursor") != "" {
 		api.RespondError(w, http.StatusBadRequest, "cannot specify both slot and cursor")
 		return
+	} else if hasSlotRange && (args.Get("slot") != "" || args.Get("cursor") != "") {
+		api.RespondError(w, http.StatusBadRequest, "cannot specify slot range together with slot or cursor")
+		return
 	} else if args.Get("slot") != "" {
 		filters.Slot, err = strconv.ParseUint(args.Get("slot"), 10, 64)
 		if err != nil {
@@ -1583,6 +4053,25 @@ func (api *RelayAPI) handleDataProposerPayloadDelivered(w http.ResponseWriter, r
 			api.RespondError(w, http.StatusBadRequest, "invalid cursor argument")
 			return
 		}
+	} else if hasSlotRange {
+		filters.SlotFrom, err = strconv.ParseUint(args.Get("slot_from"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid slot_from argument")
+			return
+		}
+		filters.SlotTo, err = strconv.ParseUint(args.Get("slot_to"), 10, 64)
+		if err != nil || filters.SlotTo == 0 {
+			api.RespondError(w, http.StatusBadRequest, "invalid slot_to argument")
+			return
+		}
+		if filters.SlotFrom > filters.SlotTo {
+			api.RespondError(w, http.StatusBadRequest, "slot_from must not be greater than slot_to")
+			return
+		}
+		if filters.SlotTo-filters.SlotFrom > maxDataAPISlotRange {
+			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum slot range is %d", maxDataAPISlotRange))
+			return
+		}
 	}
 
 	if args.Get("block_hash") != "" {
@@ -1640,14 +4129,31 @@ func (api *RelayAPI) handleDataProposerPayloadDelivered(w http.ResponseWriter, r
 
 	deliveredPayloads, err := api.db.GetRecentDeliveredPayloads(filters)
 	if err != nil {
-		api.log.WithError(err).Error("error getting recent payloads")
+		api.getRequestLog(req).WithError(err).Error("error getting recent payloads")
 		api.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	response := make([]common.BidTraceV2JSON, len(deliveredPayloads))
+	response := make([]DeliveredPayloadResponse, len(deliveredPayloads))
 	for i, payload := range deliveredPayloads {
-		response[i] = database.DeliveredPayloadEntryToBidTraceV2JSON(payload)
+		response[i] = DeliveredPayloadResponse{
+			BidTraceV2JSON: database.DeliveredPayloadEntryToBidTraceV2JSON(payload),
+			Reorged:        payload.Reorged,
+		}
+	}
+
+	if args.Get("envelope") == "1" {
+		// The cursor only pages through results ordered by slot
+		nextCursor := ""
+		lastSlot := uint64(0)
+		if len(deliveredPayloads) > 0 {
+			lastSlot = deliveredPayloads[len(deliveredPayloads)-1].Slot
+		}
+		if uint64(len(deliveredPayloads)) == filters.Limit && filters.OrderByValue == 0 && filters.Slot == 0 && filters.SlotTo == 0 && lastSlot > 0 {
+			nextCursor = strconv.FormatUint(lastSlot-1, 10)
+		}
+		api.RespondOK(w, DataAPIPageResponse{Data: response, Count: len(response), NextCursor: nextCursor})
+		return
 	}
 
 	api.RespondOK(w, response)
@@ -1666,8 +4172,11 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 	}
 
 	if args.Get("cursor") != "" {
-		api.RespondError(w, http.StatusBadRequest, "cursor argument not supported")
-		return
+		filters.Cursor, err = strconv.ParseUint(args.Get("cursor"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid cursor argument")
+			return
+		}
 	}
 
 	if args.Get("slot") != "" {
@@ -1723,9 +4232,24 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 		filters.Limit = _limit
 	}
 
+	if args.Get("order_by") == "fill_ratio" {
+		filters.OrderByFillRatio = 1
+	} else if args.Get("order_by") == "-fill_ratio" {
+		filters.OrderByFillRatio = -1
+	} else if args.Get("order_by") != "" {
+		api.RespondError(w, http.StatusBadRequest, "invalid order_by argument")
+		return
+	}
+
+	// The cursor only pages through results ordered by slot
+	if filters.OrderByFillRatio != 0 && filters.Cursor > 0 {
+		api.RespondError(w, http.StatusBadRequest, "cannot specify cursor with order_by fill_ratio")
+		return
+	}
+
 	blockSubmissions, err := api.db.GetBuilderSubmissions(filters)
 	if err != nil {
-		api.log.WithError(err).Error("error getting recent payloads")
+		api.getRequestLog(req).WithError(err).Error("error getting recent payloads")
 		api.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -1735,6 +4259,15 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 		response[i] = database.BuilderSubmissionEntryToBidTraceV2WithTimestampJSON(payload)
 	}
 
+	if args.Get("envelope") == "1" {
+		nextCursor := ""
+		if len(blockSubmissions) > 0 && uint64(len(blockSubmissions)) == filters.Limit && filters.OrderByFillRatio == 0 {
+			nextCursor = strconv.FormatInt(blockSubmissions[len(blockSubmissions)-1].ID, 10)
+		}
+		api.RespondOK(w, DataAPIPageResponse{Data: response, Count: len(response), NextCursor: nextCursor})
+		return
+	}
+
 	api.RespondOK(w, response)
 }
 
@@ -1758,17 +4291,213 @@ func (api *RelayAPI) handleDataValidatorRegistration(w http.ResponseWriter, req
 			api.RespondError(w, http.StatusBadRequest, "no registration found for validator "+pkStr)
 			return
 		}
-		api.log.WithError(err).Error("error getting validator registration")
+		api.getRequestLog(req).WithError(err).Error("error getting validator registration")
 		api.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	signedRegistration, err := registrationEntry.ToSignedValidatorRegistration()
 	if err != nil {
-		api.log.WithError(err).Error("error converting registration entry to signed validator registration")
+		api.getRequestLog(req).WithError(err).Error("error converting registration entry to signed validator registration")
 		api.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	api.RespondOK(w, signedRegistration)
 }
+
+// handleDataValidatorRegistrationHistory returns all stored registrations of a validator, oldest first, to audit changes
+func (api *RelayAPI) handleDataValidatorRegistrationHistory(w http.ResponseWriter, req *http.Request) {
+	pkStr := req.URL.Query().Get("pubkey")
+	if pkStr == "" {
+		api.RespondError(w, http.StatusBadRequest, "missing pubkey argument")
+		return
+	}
+
+	var pk types.PublicKey
+	if err := pk.UnmarshalText([]byte(pkStr)); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid pubkey")
+		return
+	}
+
+	entries, err := api.db.GetValidatorRegistrationHistory(pk.String())
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting validator registration history")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]*types.SignedValidatorRegistration, len(entries))
+	for i, entry := range entries {
+		response[i], err = entry.ToSignedValidatorRegistration()
+		if err != nil {
+			api.getRequestLog(req).WithError(err).Error("error converting registration entry to signed validator registration")
+			api.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	api.RespondOK(w, response)
+}
+
+func (api *RelayAPI) handleDataSlotWinner(w http.ResponseWriter, req *http.Request) {
+	slot, err := strconv.ParseUint(req.URL.Query().Get("slot"), 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot argument")
+		return
+	}
+
+	payload, err := api.db.GetDeliveredPayloadBySlot(slot)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondError(w, http.StatusNotFound, fmt.Sprintf("no payload delivered for slot %d", slot))
+		return
+	} else if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting delivered payload")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.RespondOK(w, SlotWinnerResponse{
+		Slot:           payload.Slot,
+		BuilderPubkey:  payload.BuilderPubkey,
+		ProposerPubkey: payload.ProposerPubkey,
+		BlockHash:      payload.BlockHash,
+		Value:          payload.Value,
+	})
+}
+
+func (api *RelayAPI) handleDataBuilderStats(w http.ResponseWriter, req *http.Request) {
+	args := req.URL.Query()
+
+	builderPubkey := args.Get("builder_pubkey")
+	if builderPubkey != "" {
+		if err := checkBLSPublicKeyHex(builderPubkey); err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid builder_pubkey argument")
+			return
+		}
+	}
+
+	slotFrom, err := strconv.ParseUint(args.Get("slot_from"), 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot_from argument")
+		return
+	}
+	slotTo, err := strconv.ParseUint(args.Get("slot_to"), 10, 64)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid slot_to argument")
+		return
+	}
+	if slotFrom > slotTo {
+		api.RespondError(w, http.StatusBadRequest, "slot_from must not be greater than slot_to")
+		return
+	}
+	if slotTo-slotFrom > maxDataAPISlotRange {
+		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum slot range is %d", maxDataAPISlotRange))
+		return
+	}
+
+	stats, err := api.db.GetBuilderStats(builderPubkey, slotFrom, slotTo)
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting builder stats")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]BuilderStatsResponse, len(stats))
+	for i, entry := range stats {
+		response[i] = BuilderStatsResponse{
+			BuilderPubkey:  entry.BuilderPubkey,
+			SlotFrom:       slotFrom,
+			SlotTo:         slotTo,
+			NumSubmissions: entry.NumSubmissions,
+			NumSimErrors:   entry.NumSimErrors,
+			AvgValue:       entry.AvgValue,
+			MaxValue:       entry.MaxValue,
+			NumDelivered:   entry.NumDelivered,
+			ValueDelivered: entry.ValueDelivered,
+		}
+	}
+
+	api.RespondOK(w, response)
+}
+
+func (api *RelayAPI) handleDataFeeRecipientChanges(w http.ResponseWriter, req *http.Request) {
+	args := req.URL.Query()
+
+	pubkey := args.Get("pubkey")
+	if pubkey != "" {
+		var pk types.PublicKey
+		if err := pk.UnmarshalText([]byte(pubkey)); err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid pubkey")
+			return
+		}
+		pubkey = pk.String()
+	}
+
+	limit := uint64(100)
+	maxLimit := uint64(500)
+	if args.Get("limit") != "" {
+		_limit, err := strconv.ParseUint(args.Get("limit"), 10, 64)
+		if err != nil || _limit == 0 {
+			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
+			return
+		}
+		if _limit > maxLimit {
+			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum limit is %d", maxLimit))
+			return
+		}
+		limit = _limit
+	}
+
+	entries, err := api.db.GetFeeRecipientChanges(pubkey, limit)
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting fee recipient changes")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]common.FeeRecipientChangeJSON, len(entries))
+	for i, entry := range entries {
+		response[i] = database.FeeRecipientChangeEntryToJSON(entry)
+	}
+
+	api.RespondOK(w, response)
+}
+
+func (api *RelayAPI) handleDataActiveValidators(w http.ResponseWriter, req *http.Request) {
+	args := req.URL.Query()
+
+	limit := 100
+	if args.Get("limit") != "" {
+		_limit, err := strconv.Atoi(args.Get("limit"))
+		if err != nil || _limit <= 0 {
+			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
+			return
+		}
+		if _limit > maxActiveValidatorsPageSize {
+			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum limit is %d", maxActiveValidatorsPageSize))
+			return
+		}
+		limit = _limit
+	}
+
+	cursor := args.Get("cursor")
+	if cursor != "" {
+		if err := checkBLSPublicKeyHex(cursor); err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid cursor argument")
+			return
+		}
+	}
+
+	pubkeys, nextCursor, err := api.redis.GetActiveValidatorsPage(types.PubkeyHex(cursor), limit)
+	if err != nil {
+		api.getRequestLog(req).WithError(err).Error("error getting active validators")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.RespondOK(w, ActiveValidatorsResponse{
+		Validators: pubkeys,
+		NextCursor: nextCursor,
+	})
+}
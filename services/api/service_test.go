@@ -2,13 +2,22 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/flashbots/go-boost-utils/bls"
 	"github.com/flashbots/go-boost-utils/types"
 	"github.com/flashbots/mev-boost-relay/beaconclient"
@@ -140,12 +149,87 @@ func TestWebserver(t *testing.T) {
 	})
 }
 
+func TestRetryBeaconStartup(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	beaconStartupRetries = 2
+	beaconStartupRetryBackoffMs = 1
+	defer func() {
+		beaconStartupRetries = 5
+		beaconStartupRetryBackoffMs = 1000
+	}()
+
+	// The beacon node becomes available before the retries are used up
+	attempts := 0
+	err := backend.relay.retryBeaconStartup("genesis", func() error {
+		attempts++
+		if attempts < 3 {
+			return errFake
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = backend.relay.retryBeaconStartup("genesis", func() error {
+		attempts++
+		return errFake
+	})
+	require.ErrorIs(t, err, errFake)
+	require.Equal(t, 3, attempts)
+}
+
+func TestStopServerDrainsPendingWrites(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.ProposerAPI = false // skip waiting for getPayload calls
+	backend.relay.srv = &http.Server{}
+
+	payload, err := generateSignedValidatorRegistration(nil, types.Address{1}, uint64(time.Now().Unix()))
+	require.NoError(t, err)
+	pubkeyHex := payload.Message.Pubkey.PubkeyHex()
+
+	// Queue writes without any processor running
+	backend.relay.activeValidatorC <- pubkeyHex
+	require.True(t, backend.relay.sendValidatorRegistration(*payload))
+
+	err = backend.relay.StopServer()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(backend.relay.activeValidatorC))
+	require.Equal(t, 0, len(backend.relay.validatorRegC))
+
+	activeValidators, err := backend.redis.GetActiveValidators()
+	require.NoError(t, err)
+	require.True(t, activeValidators[pubkeyHex])
+
+	timestamp, err := backend.redis.GetValidatorRegistrationTimestamp(pubkeyHex)
+	require.NoError(t, err)
+	require.Equal(t, payload.Message.Timestamp, timestamp)
+}
+
 func TestWebserverRootHandler(t *testing.T) {
 	backend := newTestBackend(t, 1)
 	rr := backend.request(http.MethodGet, "/", nil)
 	require.Equal(t, http.StatusOK, rr.Code)
 }
 
+func TestRelayInfo(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.genesisInfo = &beaconclient.GetGenesisResponse{}
+	backend.relay.genesisInfo.Data.GenesisTime = 1606824023
+
+	rr := backend.request(http.MethodGet, "/eth/v1/builder/relay_info", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := RelayInfoResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, backend.relay.publicKey.String(), resp.Pubkey)
+	require.Equal(t, "test", resp.Network)
+	require.Equal(t, uint64(1606824023), resp.GenesisTime)
+	require.Equal(t, genesisForkVersionHex, resp.GenesisForkVersion)
+	require.Equal(t, hexutil.Encode(builderSigningDomain[:]), resp.DomainBuilder)
+	require.Equal(t, hexutil.Encode(make([]byte, 32)), resp.DomainBeaconProposer)
+}
+
 func TestStatus(t *testing.T) {
 	backend := newTestBackend(t, 1)
 	path := "/eth/v1/builder/status"
@@ -218,6 +302,115 @@ func TestRegisterValidator(t *testing.T) {
 		require.Equal(t, http.StatusBadRequest, rr.Code)
 		require.Contains(t, rr.Body.String(), "timestamp too far in the future")
 	})
+
+	t.Run("SSZ encoded", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+		payload, err := generateSignedValidatorRegistration(nil, types.Address{1}, uint64(time.Now().Unix()))
+		require.NoError(t, err)
+		err = backend.redis.SetKnownValidator(payload.Message.Pubkey.PubkeyHex(), 1)
+		require.NoError(t, err)
+		_, err = backend.datastore.RefreshKnownValidators()
+		require.NoError(t, err)
+
+		body, err := payload.Message.MarshalSSZ()
+		require.NoError(t, err)
+		body = append(body, payload.Signature[:]...)
+
+		for _, tc := range []struct {
+			body         []byte
+			expectedCode int
+		}{
+			{body, http.StatusOK},
+			{body[:len(body)-1], http.StatusBadRequest},
+		} {
+			req, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", MediaTypeOctetStream)
+			rr := httptest.NewRecorder()
+			backend.relay.getRouter().ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedCode, rr.Code, rr.Body.String())
+		}
+
+		// The registration went through the same pipeline as a JSON one
+		require.Len(t, backend.relay.validatorRegC, 1)
+		reg := <-backend.relay.validatorRegC
+		require.Equal(t, *payload, reg)
+	})
+
+	t.Run("async", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+		backend.relay.ffAsyncValidatorRegs = true
+		go backend.relay.startAsyncValidatorRegProcessor()
+
+		// Errors of single registrations are only logged
+		rr := backend.request(http.MethodPost, path, []types.SignedValidatorRegistration{common.ValidPayloadRegisterValidator})
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader("[{"))
+		require.NoError(t, err)
+		rr = httptest.NewRecorder()
+		backend.relay.getRouter().ServeHTTP(rr, req)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+
+		payload, err := generateSignedValidatorRegistration(nil, types.Address{1}, uint64(time.Now().Unix()))
+		require.NoError(t, err)
+		err = backend.redis.SetKnownValidator(payload.Message.Pubkey.PubkeyHex(), 1)
+		require.NoError(t, err)
+		_, err = backend.datastore.RefreshKnownValidators()
+		require.NoError(t, err)
+
+		rr = backend.request(http.MethodPost, path, []types.SignedValidatorRegistration{*payload})
+		require.Equal(t, http.StatusOK, rr.Code)
+		reg := <-backend.relay.validatorRegC
+		require.Equal(t, *payload, reg)
+	})
+
+	t.Run("async queue full", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+		backend.relay.ffAsyncValidatorRegs = true
+		backend.relay.asyncValidatorRegC = make(chan func())
+
+		payload, err := generateSignedValidatorRegistration(nil, types.Address{1}, uint64(time.Now().Unix()))
+		require.NoError(t, err)
+		err = backend.redis.SetKnownValidator(payload.Message.Pubkey.PubkeyHex(), 1)
+		require.NoError(t, err)
+		_, err = backend.datastore.RefreshKnownValidators()
+		require.NoError(t, err)
+
+		// Without a free processor, the registrations are processed before responding, errors included
+		rr := backend.request(http.MethodPost, path, []types.SignedValidatorRegistration{*payload})
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Len(t, backend.relay.validatorRegC, 1)
+		reg := <-backend.relay.validatorRegC
+		require.Equal(t, *payload, reg)
+
+		rr = backend.request(http.MethodPost, path, []types.SignedValidatorRegistration{common.ValidPayloadRegisterValidator})
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("async drain", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+		backend.relay.ffAsyncValidatorRegs = true
+
+		payload, err := generateSignedValidatorRegistration(nil, types.Address{1}, uint64(time.Now().Unix()))
+		require.NoError(t, err)
+		err = backend.redis.SetKnownValidator(payload.Message.Pubkey.PubkeyHex(), 1)
+		require.NoError(t, err)
+		_, err = backend.datastore.RefreshKnownValidators()
+		require.NoError(t, err)
+
+		// The call is answered and queued, the drain waits for it to be processed and saves the registration
+		rr := backend.request(http.MethodPost, path, []types.SignedValidatorRegistration{*payload})
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Len(t, backend.relay.asyncValidatorRegC, 1)
+		go backend.relay.startAsyncValidatorRegProcessor()
+		remaining := backend.relay.drainPendingWrites(time.Second)
+		require.Equal(t, 0, remaining)
+		require.Len(t, backend.relay.validatorRegC, 0)
+		timestamp, err := backend.redis.GetValidatorRegistrationTimestamp(payload.Message.Pubkey.PubkeyHex())
+		require.NoError(t, err)
+		require.Equal(t, payload.Message.Timestamp, timestamp)
+	})
 }
 
 func TestBuilderApiGetValidators(t *testing.T) {
@@ -242,6 +435,38 @@ func TestBuilderApiGetValidators(t *testing.T) {
 	require.Equal(t, common.ValidPayloadRegisterValidator, *resp[0].Entry)
 }
 
+func TestProposerApiDuties(t *testing.T) {
+	pubkey := common.ValidPayloadRegisterValidator.Message.Pubkey
+	otherPubkey := "0xa1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca2490"
+
+	backend := newTestBackend(t, 1)
+	for _, slot := range []uint64{9, 3} {
+		backend.relay.proposerDutiesResponse = append(backend.relay.proposerDutiesResponse, types.BuilderGetValidatorsResponseEntry{
+			Slot:  slot,
+			Entry: &common.ValidPayloadRegisterValidator,
+		})
+	}
+	err := backend.redis.SetValidatorRegistrationTimestamp(pubkey.PubkeyHex(), 1)
+	require.NoError(t, err)
+
+	rr := backend.request(http.MethodGet, "/eth/v1/builder/duties/0x1234", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = backend.request(http.MethodGet, "/eth/v1/builder/duties/"+pubkey.String(), nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := ProposerDutiesResponse{}
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, ProposerDutiesResponse{Pubkey: pubkey.String(), Slots: []uint64{3, 9}, Registered: true}, resp)
+
+	rr = backend.request(http.MethodGet, "/eth/v1/builder/duties/"+otherPubkey, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = ProposerDutiesResponse{}
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, ProposerDutiesResponse{Pubkey: otherPubkey, Slots: []uint64{}, Registered: false}, resp)
+}
+
 func TestDataApiGetDataProposerPayloadDelivered(t *testing.T) {
 	path := "/relay/v1/data/bidtraces/proposer_payload_delivered"
 
@@ -274,4 +499,1286 @@ func TestDataApiGetDataProposerPayloadDelivered(t *testing.T) {
 			require.Contains(t, rr.Body.String(), "invalid block_hash argument")
 		}
 	})
+
+	t.Run("Slot range", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+
+		testCases := []struct {
+			query        string
+			expectedCode int
+			expectedErr  string
+		}{
+			{"?slot_from=100&slot_to=200", http.StatusOK, ""},
+			{"?slot_from=100&slot_to=100", http.StatusOK, ""},
+			{"?slot_from=200&slot_to=100", http.StatusBadRequest, "slot_from must not be greater than slot_to"},
+			{"?slot_from=100", http.StatusBadRequest, "invalid slot_to argument"},
+			{"?slot_to=100", http.StatusBadRequest, "invalid slot_from argument"},
+			{fmt.Sprintf("?slot_from=1&slot_to=%d", maxDataAPISlotRange+2), http.StatusBadRequest, "maximum slot range"},
+			{"?slot_from=100&slot_to=200&slot=150", http.StatusBadRequest, "cannot specify slot range"},
+			{"?slot_from=100&slot_to=200&cursor=150", http.StatusBadRequest, "cannot specify slot range"},
+		}
+
+		for _, tc := range testCases {
+			rr := backend.request(http.MethodGet, path+tc.query, nil)
+			require.Equal(t, tc.expectedCode, rr.Code, tc.query)
+			require.Contains(t, rr.Body.String(), tc.expectedErr)
+		}
+	})
+}
+
+func TestInternalPayload(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	proposerPubkey := "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+	blockHash := "0xa645370cc112c2e8e3cce121416c7dc849e773506d4b6fb9b752ada711355369"
+	payload := &types.GetPayloadResponse{
+		Version: VersionBellatrix,
+		Data:    &types.ExecutionPayload{BlockNumber: 123, Transactions: []hexutil.Bytes{}},
+	}
+	err := backend.redis.SaveExecutionPayload(42, proposerPubkey, blockHash, payload)
+	require.NoError(t, err)
+
+	rr := backend.request(http.MethodGet, fmt.Sprintf("/internal/v1/payload/42/%s/%s", proposerPubkey, blockHash), nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	resp := new(types.GetPayloadResponse)
+	err = json.Unmarshal(rr.Body.Bytes(), resp)
+	require.NoError(t, err)
+	require.Equal(t, uint64(123), resp.Data.BlockNumber)
+
+	rr = backend.request(http.MethodGet, fmt.Sprintf("/internal/v1/payload/43/%s/%s", proposerPubkey, blockHash), nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestInternalSubmissionStats(t *testing.T) {
+	path := "/internal/v1/stats/submissions"
+	backend := newTestBackend(t, 1)
+
+	testCases := []struct {
+		query        string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"?slot_from=100&slot_to=200", http.StatusOK, "num_submissions"},
+		{"?slot_from=200&slot_to=100", http.StatusBadRequest, "slot_from must not be greater than slot_to"},
+		{"?slot_from=100", http.StatusBadRequest, "invalid slot_to argument"},
+		{"?slot_to=100", http.StatusBadRequest, "invalid slot_from argument"},
+		{fmt.Sprintf("?slot_from=1&slot_to=%d", maxDataAPISlotRange+2), http.StatusBadRequest, "maximum slot range"},
+	}
+
+	for _, tc := range testCases {
+		rr := backend.request(http.MethodGet, path+tc.query, nil)
+		require.Equal(t, tc.expectedCode, rr.Code, tc.query)
+		require.Contains(t, rr.Body.String(), tc.expectedErr)
+	}
+}
+
+func TestInternalRejectedSubmissions(t *testing.T) {
+	path := "/internal/v1/submissions/rejected"
+	backend := newTestBackend(t, 1)
+	receivedAt := time.Now().UTC()
+	backend.relay.db = database.MockDB{
+		RejectedSubmissions: []*database.BuilderBlockSubmissionEntry{
+			{Slot: 2, BuilderPubkey: "0xb2", SimError: "incorrect gas limit", ReceivedAt: database.NewNullTime(receivedAt), RemoteAddr: "10.0.0.1"},
+			{Slot: 1, BuilderPubkey: "0xb1", SimError: "invalid prev_randao", ReceivedAt: database.NewNullTime(receivedAt)},
+		},
+	}
+
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := []common.RejectedSubmissionJSON{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(resp))
+	require.Equal(t, uint64(2), resp[0].Slot)
+	require.Equal(t, "0xb2", resp[0].BuilderPubkey)
+	require.Equal(t, "incorrect gas limit", resp[0].Reason)
+	require.Equal(t, receivedAt.UnixMilli(), resp[0].TimestampMs)
+	require.Equal(t, "10.0.0.1", resp[0].RemoteAddr)
+
+	rr = backend.request(http.MethodGet, path+"?limit=1", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp))
+
+	rr = backend.request(http.MethodGet, path+"?limit=501", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "maximum limit is 500")
+}
+
+func TestInternalDemotions(t *testing.T) {
+	path := "/internal/v1/demotions"
+	backend := newTestBackend(t, 1)
+	builder1 := types.PublicKey{0x01}.String()
+	builder2 := types.PublicKey{0x02}.String()
+	insertedAt := time.Now().UTC()
+	backend.relay.db = database.MockDB{
+		DemotionEntries: map[string]*database.BuilderDemotionEntry{
+			"a": {InsertedAt: insertedAt.Add(-2 * time.Minute), Slot: 10, BuilderPubkey: builder1, SignedBeaconBlock: sql.NullString{Valid: true}, SignedValidatorRegistration: sql.NullString{Valid: true}},
+			"b": {InsertedAt: insertedAt.Add(-time.Minute), Slot: 11, BuilderPubkey: builder2, SignedBeaconBlock: sql.NullString{Valid: true}},
+			"c": {InsertedAt: insertedAt, Slot: 12, BuilderPubkey: builder1},
+		},
+	}
+
+	// Newest first, refund ready only with both the block and the registration
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := []BuilderDemotionResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(resp))
+	require.Equal(t, uint64(12), resp[0].Slot)
+	require.False(t, resp[0].RefundReady)
+	require.Equal(t, uint64(11), resp[1].Slot)
+	require.True(t, resp[1].Refunded)
+	require.False(t, resp[1].RefundReady)
+	require.Equal(t, uint64(10), resp[2].Slot)
+	require.True(t, resp[2].RefundReady)
+
+	rr = backend.request(http.MethodGet, path+"?builder_pubkey="+builder1+"&slot_from=11", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp))
+	require.Equal(t, uint64(12), resp[0].Slot)
+
+	rr = backend.request(http.MethodGet, path+"?slot_from=10&slot_to=11&limit=1", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp))
+	require.Equal(t, uint64(11), resp[0].Slot)
+
+	rr = backend.request(http.MethodGet, path+"?slot_from=12&slot_to=11", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = backend.request(http.MethodGet, path+"?builder_pubkey=0x01", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = backend.request(http.MethodGet, path+"?limit=501", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestDataApiPayloadDeliveredEnvelope(t *testing.T) {
+	path := "/relay/v1/data/bidtraces/proposer_payload_delivered"
+	backend := newTestBackend(t, 1)
+	backend.relay.db = database.MockDB{
+		DeliveredPayloads: []*database.DeliveredPayloadEntry{
+			{Slot: 12, Value: "3"},
+			{Slot: 11, Value: "2", Reorged: true},
+			{Slot: 10, Value: "1"},
+		},
+	}
+
+	// Bare array by default
+	rr := backend.request(http.MethodGet, path+"?limit=2", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	payloads := []DeliveredPayloadResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &payloads)
+	require.NoError(t, err)
+	require.Len(t, payloads, 2)
+	require.True(t, payloads[1].Reorged)
+
+	// A full page has a next cursor
+	rr = backend.request(http.MethodGet, path+"?limit=2&envelope=1", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := new(DataAPIPageResponse)
+	err = json.Unmarshal(rr.Body.Bytes(), resp)
+	require.NoError(t, err)
+	require.Equal(t, 2, resp.Count)
+	require.Len(t, resp.Data, 2)
+	require.Equal(t, "10", resp.NextCursor)
+
+	// The last page doesn't
+	rr = backend.request(http.MethodGet, path+"?limit=5&envelope=1", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = new(DataAPIPageResponse)
+	err = json.Unmarshal(rr.Body.Bytes(), resp)
+	require.NoError(t, err)
+	require.Equal(t, 3, resp.Count)
+	require.Equal(t, "", resp.NextCursor)
+
+	// Ordered by value, there is no cursor
+	rr = backend.request(http.MethodGet, path+"?limit=2&envelope=1&order_by=-value", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = new(DataAPIPageResponse)
+	err = json.Unmarshal(rr.Body.Bytes(), resp)
+	require.NoError(t, err)
+	require.Equal(t, "", resp.NextCursor)
+}
+
+func TestDataApiSlotWinner(t *testing.T) {
+	path := "/relay/v1/data/slot_winner"
+	backend := newTestBackend(t, 1)
+	backend.relay.db = database.MockDB{
+		DeliveredPayloads: []*database.DeliveredPayloadEntry{
+			{Slot: 12, BuilderPubkey: "0xb1", ProposerPubkey: "0xa1", BlockHash: "0xc1", Value: "3"},
+		},
+	}
+
+	rr := backend.request(http.MethodGet, path+"?slot=12", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := new(SlotWinnerResponse)
+	err := json.Unmarshal(rr.Body.Bytes(), resp)
+	require.NoError(t, err)
+	require.Equal(t, SlotWinnerResponse{Slot: 12, BuilderPubkey: "0xb1", ProposerPubkey: "0xa1", BlockHash: "0xc1", Value: "3"}, *resp)
+
+	rr = backend.request(http.MethodGet, path+"?slot=13", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestDataApiBuilderBidsReceivedTimestamps(t *testing.T) {
+	path := "/relay/v1/data/bidtraces/builder_blocks_received"
+	backend := newTestBackend(t, 1)
+	receivedAt := time.UnixMilli(1_700_000_000_123)
+	eligibleAt := receivedAt.Add(25 * time.Millisecond)
+	backend.relay.db = database.MockDB{
+		BuilderSubmissions: []*database.BuilderBlockSubmissionEntry{
+			{Slot: 12, ReceivedAt: database.NewNullTime(receivedAt), EligibleAt: database.NewNullTime(eligibleAt)},
+			{Slot: 12, ReceivedAt: database.NewNullTime(receivedAt), EligibleAt: database.NewNullTime(time.Time{})},
+		},
+	}
+
+	rr := backend.request(http.MethodGet, path+"?slot=12", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := []common.BidTraceV2WithTimestampJSON{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Len(t, resp, 2)
+	require.Equal(t, receivedAt.UnixMilli(), resp[0].ReceivedAtMs)
+	require.Equal(t, eligibleAt.UnixMilli(), resp[0].EligibleAtMs)
+	require.Equal(t, receivedAt.UnixMilli(), resp[1].ReceivedAtMs)
+	require.Equal(t, int64(0), resp[1].EligibleAtMs)
+}
+
+func TestDataApiBuilderBidsReceivedFillRatio(t *testing.T) {
+	path := "/relay/v1/data/bidtraces/builder_blocks_received"
+	backend := newTestBackend(t, 1)
+	backend.relay.db = database.MockDB{
+		BuilderSubmissions: []*database.BuilderBlockSubmissionEntry{
+			{Slot: 12, BlockHash: "0xa1", GasUsed: 15_000_000, GasLimit: 30_000_000},
+			{Slot: 12, BlockHash: "0xa2", GasUsed: 29_000_000, GasLimit: 30_000_000},
+			{Slot: 12, BlockHash: "0xa3", GasUsed: 0, GasLimit: 0},
+		},
+	}
+
+	getBlockHashes := func(query string) ([]string, []float64) {
+		rr := backend.request(http.MethodGet, path+query, nil)
+		require.Equal(t, http.StatusOK, rr.Code, query)
+		resp := []common.BidTraceV2WithTimestampJSON{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		blockHashes, fillRatios := []string{}, []float64{}
+		for _, entry := range resp {
+			blockHashes = append(blockHashes, entry.BlockHash)
+			fillRatios = append(fillRatios, entry.FillRatio)
+		}
+		return blockHashes, fillRatios
+	}
+
+	blockHashes, fillRatios := getBlockHashes("?slot=12")
+	require.Equal(t, []string{"0xa1", "0xa2", "0xa3"}, blockHashes)
+	require.Equal(t, []float64{0.5, 0.9667, 0}, fillRatios)
+
+	blockHashes, _ = getBlockHashes("?slot=12&order_by=fill_ratio")
+	require.Equal(t, []string{"0xa3", "0xa1", "0xa2"}, blockHashes)
+	blockHashes, _ = getBlockHashes("?slot=12&order_by=-fill_ratio")
+	require.Equal(t, []string{"0xa2", "0xa1", "0xa3"}, blockHashes)
+
+	rr := backend.request(http.MethodGet, path+"?slot=12&order_by=value", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// The cursor doesn't page through results ordered by fill ratio
+	rr = backend.request(http.MethodGet, path+"?builder_pubkey="+common.ValidPayloadRegisterValidator.Message.Pubkey.String()+"&cursor=10&order_by=fill_ratio", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = backend.request(http.MethodGet, path+"?slot=12&limit=3&envelope=1&order_by=fill_ratio", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := new(DataAPIPageResponse)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), resp))
+	require.Equal(t, "", resp.NextCursor)
+}
+
+func TestDataApiBuilderStats(t *testing.T) {
+	path := "/relay/v1/data/builder_stats"
+	builderPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	backend := newTestBackend(t, 1)
+	backend.relay.db = database.MockDB{
+		BuilderStats: []*database.BuilderStatsEntry{
+			{BuilderPubkey: builderPubkey, NumSubmissions: 3, NumSimErrors: 1, AvgValue: "2", MaxValue: "3", NumDelivered: 1, ValueDelivered: "3"},
+			{BuilderPubkey: "0xb2", NumSubmissions: 1, AvgValue: "1", MaxValue: "1", ValueDelivered: "0"},
+		},
+	}
+
+	rr := backend.request(http.MethodGet, path+"?slot_from=10&slot_to=20", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := []BuilderStatsResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Len(t, resp, 2)
+
+	rr = backend.request(http.MethodGet, path+"?slot_from=10&slot_to=20&builder_pubkey="+builderPubkey, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, []BuilderStatsResponse{{
+		BuilderPubkey:  builderPubkey,
+		SlotFrom:       10,
+		SlotTo:         20,
+		NumSubmissions: 3,
+		NumSimErrors:   1,
+		AvgValue:       "2",
+		MaxValue:       "3",
+		NumDelivered:   1,
+		ValueDelivered: "3",
+	}}, resp)
+
+	for _, query := range []string{"", "?slot_from=10", "?slot_from=20&slot_to=10", "?slot_from=0&slot_to=100000", "?slot_from=10&slot_to=20&builder_pubkey=0xb2"} {
+		rr = backend.request(http.MethodGet, path+query, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestBuilderApiPrevRandao(t *testing.T) {
+	backend := newTestBackend(t, 1)
+
+	rr := backend.request(http.MethodGet, pathBuilderPrevRandao, nil)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	backend.relay.expectedPrevRandao = randaoHelper{slot: 42, prevRandao: "0x01"}
+	rr = backend.request(http.MethodGet, pathBuilderPrevRandao, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := new(PrevRandaoResponse)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), resp))
+	require.Equal(t, PrevRandaoResponse{Slot: 42, PrevRandao: "0x01"}, *resp)
+}
+
+func TestBuilderApiGetValidatorsPaging(t *testing.T) {
+	path := "/relay/v1/builder/validators"
+
+	backend := newTestBackend(t, 1)
+	for i := uint64(1); i <= 5; i++ {
+		backend.relay.proposerDutiesResponse = append(backend.relay.proposerDutiesResponse, types.BuilderGetValidatorsResponseEntry{
+			Slot:  i,
+			Entry: &common.ValidPayloadRegisterValidator,
+		})
+	}
+
+	testCases := []struct {
+		query         string
+		expectedSlots []uint64
+	}{
+		{"", []uint64{1, 2, 3, 4, 5}},
+		{"?limit=2", []uint64{1, 2}},
+		{"?limit=10", []uint64{1, 2, 3, 4, 5}},
+		{"?offset=3", []uint64{4, 5}},
+		{"?offset=2&limit=2", []uint64{3, 4}},
+		{"?offset=5", []uint64{}},
+	}
+
+	for _, tc := range testCases {
+		rr := backend.request(http.MethodGet, path+tc.query, nil)
+		require.Equal(t, http.StatusOK, rr.Code, tc.query)
+
+		resp := []types.BuilderGetValidatorsResponseEntry{}
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		require.NoError(t, err)
+		slots := []uint64{}
+		for _, entry := range resp {
+			slots = append(slots, entry.Slot)
+		}
+		require.Equal(t, tc.expectedSlots, slots, tc.query)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=abc", "?offset=-1"} {
+		rr := backend.request(http.MethodGet, path+query, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestBuilderApiGetValidatorsLookahead(t *testing.T) {
+	path := "/relay/v1/builder/validators"
+
+	backend := newTestBackend(t, 1)
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "0", rr.Header().Get(HeaderDutiesLookaheadSlots))
+
+	backend.relay.headSlot.Store(10)
+	for _, slot := range []uint64{10, 11, 42} {
+		backend.relay.proposerDutiesResponse = append(backend.relay.proposerDutiesResponse, types.BuilderGetValidatorsResponseEntry{
+			Slot:  slot,
+			Entry: &common.ValidPayloadRegisterValidator,
+		})
+	}
+
+	// The lookahead covers all known duties, not only the requested page
+	rr = backend.request(http.MethodGet, path+"?limit=1", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "32", rr.Header().Get(HeaderDutiesLookaheadSlots))
+}
+
+func TestInternalMissedSlots(t *testing.T) {
+	path := "/internal/v1/missed_slots"
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.BlockBuilderAPI = false // no background updates of the randao
+	backend.relay.processNewSlot(10)
+	backend.relay.processNewSlot(13)
+	backend.relay.processNewSlot(15)
+
+	testCases := []struct {
+		query         string
+		expectedSlots []uint64
+	}{
+		{"", []uint64{11, 12, 14}},
+		{"?from=12", []uint64{12, 14}},
+		{"?from=11&to=12", []uint64{11, 12}},
+		{"?to=11", []uint64{11}},
+	}
+
+	for _, tc := range testCases {
+		rr := backend.request(http.MethodGet, path+tc.query, nil)
+		require.Equal(t, http.StatusOK, rr.Code, tc.query)
+
+		resp := []uint64{}
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		require.NoError(t, err)
+		require.Equal(t, tc.expectedSlots, resp, tc.query)
+	}
+
+	for _, query := range []string{"?from=abc", "?to=-1", "?from=12&to=11"} {
+		rr := backend.request(http.MethodGet, path+query, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestInternalFeatureFlags(t *testing.T) {
+	path := "/internal/v1/flags"
+	backend := newTestBackend(t, 1)
+
+	rr := backend.request(http.MethodPost, path+"?force_204=true&disable_lowprio=1", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	resp := new(FeatureFlagsResponse)
+	err := json.Unmarshal(rr.Body.Bytes(), resp)
+	require.NoError(t, err)
+	require.Equal(t, FeatureFlagsResponse{ForceGetHeader204: true, DisableLowPrioBuilders: true}, *resp)
+	require.True(t, backend.relay.ffForceGetHeader204.Load())
+	require.True(t, backend.relay.ffDisableLowPrioBuilders.Load())
+
+	// Flags are persisted for the other instances
+	value, err := backend.redis.GetRelayConfig(datastore.RedisConfigFieldForceGetHeader204)
+	require.NoError(t, err)
+	require.Equal(t, "true", value)
+
+	// Invalid arguments don't change anything
+	rr = backend.request(http.MethodPost, path+"?force_204=false&disable_publishing=maybe", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.True(t, backend.relay.ffForceGetHeader204.Load())
+
+	// Changes made through another instance are picked up
+	err = backend.redis.SetRelayConfig(datastore.RedisConfigFieldForceGetHeader204, "false")
+	require.NoError(t, err)
+	backend.relay.updateFeatureFlags()
+	require.False(t, backend.relay.ffForceGetHeader204.Load())
+	require.True(t, backend.relay.ffDisableLowPrioBuilders.Load())
+
+	// But not while stopping
+	backend.relay.srvStopping.Store(true)
+	backend.relay.ffForceGetHeader204.Store(true)
+	backend.relay.updateFeatureFlags()
+	require.True(t, backend.relay.ffForceGetHeader204.Load())
+
+	rr = backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestInternalSlotBlocklist(t *testing.T) {
+	path := "/internal/v1/slot_blocklist"
+	backend := newTestBackend(t, 1)
+	proposerPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	parentHash := "0x13e606c7b3d1faad7e83503ce3dedce4c6bb89b0c28ffb240d713c7b110b9747"
+	getHeaderPath := fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", 12, parentHash, proposerPubkey)
+	backend.relay.headSlot.Store(11)
+
+	bid := &types.GetHeaderResponse{
+		Version: "bellatrix",
+		Data: &types.SignedBuilderBid{
+			Message: &types.BuilderBid{Header: &types.ExecutionPayloadHeader{}, Value: types.IntToU256(100)},
+		},
+	}
+	err := backend.relay.redis.SaveLatestBuilderBid(12, "0xb1", parentHash, proposerPubkey, time.Now(), bid)
+	require.NoError(t, err)
+	err = backend.relay.redis.UpdateTopBid(12, parentHash, proposerPubkey, datastore.TieBreakFirstSeen, nil)
+	require.NoError(t, err)
+	rr := backend.request(http.MethodGet, getHeaderPath, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	for _, query := range []string{"", "?slot=abc", "?slot=10"} {
+		rr = backend.request(http.MethodPost, path+query, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	rr = backend.request(http.MethodPost, path+"?slot=12", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	slots := []uint64{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &slots))
+	require.Equal(t, []uint64{12}, slots)
+
+	// No bids are served for the slot
+	rr = backend.request(http.MethodGet, getHeaderPath, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	// The blocklist is persisted for the other instances, expired slots are removed on the next slot
+	err = backend.redis.AddBlocklistedSlot(13)
+	require.NoError(t, err)
+	backend.relay.updateSlotBlocklist(12)
+	require.True(t, backend.relay.isSlotBlocklisted(13))
+	backend.relay.updateSlotBlocklist(13)
+	require.False(t, backend.relay.isSlotBlocklisted(12))
+	slots, err = backend.redis.GetBlocklistedSlots()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{13}, slots)
+
+	rr = backend.request(http.MethodDelete, path+"?slot=13", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &slots))
+	require.Empty(t, slots)
+	require.False(t, backend.relay.isSlotBlocklisted(13))
+}
+
+func TestSlotBlocklistUpdates(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.headSlot.Store(11)
+	go backend.relay.startSlotBlocklistUpdates(10 * time.Millisecond)
+
+	// A slot blocklisted through another instance takes effect without a head event
+	err := backend.redis.AddBlocklistedSlot(12)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return backend.relay.isSlotBlocklisted(12) }, time.Second, 10*time.Millisecond)
+
+	err = backend.redis.RemoveBlocklistedSlots(12)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return !backend.relay.isSlotBlocklisted(12) }, time.Second, 10*time.Millisecond)
+}
+
+func TestInternalFeeRecipients(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	proposerPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	path := "/internal/v1/proposer/" + proposerPubkey + "/fee_recipients"
+	feeRecipient1 := "0x0000000000000000000000000000000000000001"
+	feeRecipient2 := "0x0000000000000000000000000000000000000002"
+
+	// Proposers without an allowlist are not restricted
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := FeeRecipientAllowlistResponse{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, FeeRecipientAllowlistResponse{ProposerPubkey: proposerPubkey, FeeRecipients: []string{}}, resp)
+	require.True(t, backend.relay.isFeeRecipientAllowed(proposerPubkey, feeRecipient1))
+
+	for _, query := range []string{"", "?fee_recipients=abc", "?fee_recipients=" + feeRecipient1 + ",0x01"} {
+		rr = backend.request(http.MethodPost, path+query, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+	rr = backend.request(http.MethodPost, "/internal/v1/proposer/0x1234/fee_recipients?fee_recipients="+feeRecipient1, nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = backend.request(http.MethodPost, path+"?fee_recipients="+feeRecipient2+","+feeRecipient1, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, []string{feeRecipient1, feeRecipient2}, resp.FeeRecipients)
+	require.True(t, backend.relay.isFeeRecipientAllowed(proposerPubkey, feeRecipient2))
+	require.False(t, backend.relay.isFeeRecipientAllowed(proposerPubkey, "0x0000000000000000000000000000000000000003"))
+
+	// The allowlists are persisted for the other instances
+	backend.relay.feeRecipientAllowlists = make(map[string]map[string]bool)
+	backend.relay.updateFeeRecipientAllowlists()
+	require.False(t, backend.relay.isFeeRecipientAllowed(proposerPubkey, "0x0000000000000000000000000000000000000003"))
+
+	rr = backend.request(http.MethodDelete, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Empty(t, resp.FeeRecipients)
+	backend.relay.updateFeeRecipientAllowlists()
+	require.True(t, backend.relay.isFeeRecipientAllowed(proposerPubkey, "0x0000000000000000000000000000000000000003"))
+}
+
+// headEventsBeaconClient records the contexts of the head event subscriptions
+type headEventsBeaconClient struct {
+	*beaconclient.MockMultiBeaconClient
+	subscriptions []context.Context
+}
+
+func (c *headEventsBeaconClient) SubscribeToHeadEvents(ctx context.Context, slotC chan beaconclient.HeadEventData) {
+	c.subscriptions = append(c.subscriptions, ctx)
+}
+
+func TestCheckHeadEvents(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.BlockBuilderAPI = false // no background updates of the randao
+	beaconClient := &headEventsBeaconClient{MockMultiBeaconClient: beaconclient.NewMockMultiBeaconClient()}
+	backend.relay.beaconClient = beaconClient
+	headEventC := make(chan beaconclient.HeadEventData)
+
+	// Recent head event: nothing to do
+	now := time.Now()
+	backend.relay.lastHeadEventAt.Store(now.UnixMilli())
+	backend.relay.checkHeadEvents(headEventC, now)
+	require.Equal(t, uint64(0), backend.relay.headSlot.Load())
+	require.True(t, backend.relay.lastHeadResubscribedAt.IsZero())
+
+	// Status endpoint exposes the age of the latest head event
+	rr := backend.request(http.MethodGet, pathStatus, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotEmpty(t, rr.Header().Get(HeaderLastHeadEventAgeMs))
+
+	// Stale head event: resubscribe and poll the head slot
+	backend.relay.lastHeadEventAt.Store(now.Add(-3 * common.DurationPerSlot).UnixMilli())
+	backend.relay.checkHeadEvents(headEventC, now)
+	require.Equal(t, uint64(1), backend.relay.headSlot.Load())
+	require.Equal(t, now, backend.relay.lastHeadResubscribedAt)
+
+	require.Len(t, beaconClient.subscriptions, 1)
+
+	// Still stale a slot later: poll again, but don't resubscribe yet
+	backend.relay.checkHeadEvents(headEventC, now.Add(common.DurationPerSlot))
+	require.Equal(t, now, backend.relay.lastHeadResubscribedAt)
+	require.Len(t, beaconClient.subscriptions, 1)
+
+	// Resubscribing ends the previous subscription
+	backend.relay.checkHeadEvents(headEventC, now.Add(2*common.DurationPerSlot))
+	require.Len(t, beaconClient.subscriptions, 2)
+	require.ErrorIs(t, beaconClient.subscriptions[0].Err(), context.Canceled)
+	require.NoError(t, beaconClient.subscriptions[1].Err())
+}
+
+func TestProcessNewSlotConcurrently(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.BlockBuilderAPI = false // no background updates of the randao
+	backend.relay.processNewSlot(5)
+
+	// A head event and the watchdog racing for the same slot process it only once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backend.relay.processNewSlot(8)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, uint64(8), backend.relay.headSlot.Load())
+	require.Equal(t, []uint64{6, 7}, backend.relay.missedSlots.between(0, 0))
+
+	// A stale polled slot doesn't overwrite a newer head slot
+	backend.relay.processNewSlot(7)
+	require.Equal(t, uint64(8), backend.relay.headSlot.Load())
+}
+
+func TestInternalBuilders(t *testing.T) {
+	path := "/internal/v1/builders"
+	backend := newTestBackend(t, 1)
+	backend.relay.db = database.MockDB{
+		Builders: map[string]*database.BlockBuilderEntry{
+			"0xb1": {BuilderPubkey: "0xb1", IsHighPrio: true},
+			"0xb2": {BuilderPubkey: "0xb2", IsHighPrio: true, IsDemoted: true},
+			"0xb3": {BuilderPubkey: "0xb3", IsBlacklisted: true},
+		},
+	}
+
+	testCases := []struct {
+		query            string
+		expectedBuilders []string
+	}{
+		{"", []string{"0xb1", "0xb2", "0xb3"}},
+		{"?high_prio=true", []string{"0xb1", "0xb2"}},
+		{"?high_prio=true&demoted=true", []string{"0xb2"}},
+		{"?blacklisted=true", []string{"0xb3"}},
+		{"?limit=2", []string{"0xb1", "0xb2"}},
+		{"?limit=2&offset=2", []string{"0xb3"}},
+	}
+
+	for _, tc := range testCases {
+		rr := backend.request(http.MethodGet, path+tc.query, nil)
+		require.Equal(t, http.StatusOK, rr.Code, tc.query)
+
+		resp := []database.BlockBuilderEntry{}
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		require.NoError(t, err)
+		builders := []string{}
+		for _, entry := range resp {
+			builders = append(builders, entry.BuilderPubkey)
+		}
+		require.Equal(t, tc.expectedBuilders, builders, tc.query)
+	}
+
+	rr := backend.request(http.MethodGet, path+"?limit=abc", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestProposerAllowlist(t *testing.T) {
+	allowedPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	deniedPubkey := "0xa1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca2490"
+	parentHash := "0x13e606c7b3d1faad7e83503ce3dedce4c6bb89b0c28ffb240d713c7b110b9747"
+
+	allowlistFile := filepath.Join(t.TempDir(), "allowlist.txt")
+	err := os.WriteFile(allowlistFile, []byte("# allowed proposers\n0x"+strings.ToUpper(allowedPubkey[2:])+"\n"), 0o600)
+	require.NoError(t, err)
+
+	backend := newTestBackend(t, 1)
+	backend.relay.proposerAllowlistFile = allowlistFile
+
+	getHeader := func(pubkey string) int {
+		path := fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", 1, parentHash, pubkey)
+		return backend.request(http.MethodGet, path, nil).Code
+	}
+
+	// Without an allowlist, all proposers are served.
+	require.Equal(t, http.StatusNoContent, getHeader(allowedPubkey))
+	require.Equal(t, http.StatusNoContent, getHeader(deniedPubkey))
+
+	// Load the allowlist through the internal API.
+	rr := backend.request(http.MethodPost, "/internal/v1/proposer_allowlist/reload", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, http.StatusNoContent, getHeader(allowedPubkey))
+	require.Equal(t, http.StatusForbidden, getHeader(deniedPubkey))
+
+	// Reloading picks up changes to the file.
+	err = os.WriteFile(allowlistFile, []byte(deniedPubkey+"\n"), 0o600)
+	require.NoError(t, err)
+	rr = backend.request(http.MethodPost, "/internal/v1/proposer_allowlist/reload", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, http.StatusForbidden, getHeader(allowedPubkey))
+	require.Equal(t, http.StatusNoContent, getHeader(deniedPubkey))
+
+	// An invalid file keeps the previous allowlist.
+	err = os.WriteFile(allowlistFile, []byte("0x1234\n"), 0o600)
+	require.NoError(t, err)
+	rr = backend.request(http.MethodPost, "/internal/v1/proposer_allowlist/reload", nil)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Equal(t, http.StatusNoContent, getHeader(deniedPubkey))
+}
+
+func TestGetPayloadMaxConcurrent(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.getPayloadSem = make(chan struct{}, 1)
+
+	// A call in progress holds the only slot
+	backend.relay.getPayloadSem <- struct{}{}
+	rr := backend.request(http.MethodPost, pathGetPayload, nil)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	// Once it is done, calls are processed again
+	<-backend.relay.getPayloadSem
+	rr = backend.request(http.MethodPost, pathGetPayload, nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Len(t, backend.relay.getPayloadSem, 0)
+}
+
+func TestGetPayloadBodyReadTimeout(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	getPayloadBodyReadTimeoutMs = 10
+	defer func() { getPayloadBodyReadTimeoutMs = 0 }()
+
+	// The client never finishes sending the body
+	body, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	req, err := http.NewRequest(http.MethodPost, pathGetPayload, body)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	backend.relay.getRouter().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusRequestTimeout, rr.Code)
+
+	// A complete body is decoded as usual
+	rr = backend.request(http.MethodPost, pathGetPayload, nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetHeaderDBFallback(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	proposerPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	parentHash := "0x13e606c7b3d1faad7e83503ce3dedce4c6bb89b0c28ffb240d713c7b110b9747"
+	blockHash := "0xa645370cc112c2e8e3cce121416c7dc849e773506d4b6fb9b752ada711355369"
+	path := fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", 1, parentHash, proposerPubkey)
+
+	executionPayload := &types.ExecutionPayload{
+		ParentHash:   types.Hash{0x13},
+		BlockHash:    types.Hash{0xa6},
+		Transactions: []hexutil.Bytes{{0x03}},
+	}
+	payloadJSON, err := json.Marshal(executionPayload)
+	require.NoError(t, err)
+	backend.relay.db = database.MockDB{
+		BuilderSubmissions: []*database.BuilderBlockSubmissionEntry{{
+			ExecutionPayloadID:   sql.NullInt64{Int64: 1, Valid: true},
+			SimSuccess:           true,
+			Slot:                 1,
+			ParentHash:           parentHash,
+			BlockHash:            blockHash,
+			BuilderPubkey:        proposerPubkey,
+			ProposerPubkey:       proposerPubkey,
+			ProposerFeeRecipient: "0x0000000000000000000000000000000000000001",
+			Value:                "123",
+		}},
+		ExecutionPayloads: map[int64]*database.ExecutionPayloadEntry{
+			1: {ID: 1, Version: "bellatrix", Payload: string(payloadJSON)},
+		},
+	}
+
+	// Without the flag there is no bid
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	backend.relay.ffGetHeaderDBFallback = true
+	rr = backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	bid := new(types.GetHeaderResponse)
+	err = json.Unmarshal(rr.Body.Bytes(), bid)
+	require.NoError(t, err)
+	require.Equal(t, "123", bid.Data.Message.Value.String())
+	require.Equal(t, executionPayload.BlockHash, bid.Data.Message.Header.BlockHash)
+	ok, err := types.VerifySignature(bid.Data.Message, builderSigningDomain, backend.relay.publicKey[:], bid.Data.Signature[:])
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// The bid trace is restored for getPayload
+	bidTrace, err := backend.redis.GetBidTrace(1, proposerPubkey, blockHash)
+	require.NoError(t, err)
+	require.Equal(t, "123", bidTrace.Value.String())
+
+	// Other slots still have no bid
+	rr = backend.request(http.MethodGet, fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", 2, parentHash, proposerPubkey), nil)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+}
+
+func TestGetHeaderRetryAfter(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.BlockBuilderAPI = false
+	backend.relay.beaconClient = beaconclient.NewMockMultiBeaconClient()
+	proposerPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	parentHash := "0x13e606c7b3d1faad7e83503ce3dedce4c6bb89b0c28ffb240d713c7b110b9747"
+	getHeader := func(slot uint64) *httptest.ResponseRecorder {
+		return backend.request(http.MethodGet, fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", slot, parentHash, proposerPubkey), nil)
+	}
+
+	// Right after the startup, the mock beacon node reports head slot 1 which doesn't load the proposer duties yet
+	go backend.relay.StartServer() //nolint:errcheck
+	require.Eventually(t, func() bool { return backend.relay.headSlot.Load() == 1 }, time.Second, 10*time.Millisecond)
+	rr := getHeader(2)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "1", rr.Header().Get(HeaderRetryAfter))
+
+	// The duty for the slot is known
+	backend.relay.proposerDutiesLock.Lock()
+	backend.relay.proposerDutiesMap = map[uint64]*types.RegisterValidatorRequestMessage{2: common.ValidPayloadRegisterValidator.Message}
+	backend.relay.proposerDutiesLock.Unlock()
+	rr = getHeader(2)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Empty(t, rr.Header().Get(HeaderRetryAfter))
+
+	// The proposer duties were loaded, there is just no bid
+	err := backend.redis.SetProposerDuties([]types.BuilderGetValidatorsResponseEntry{})
+	require.NoError(t, err)
+	backend.relay.updateProposerDuties(16)
+	backend.relay.headSlot.Store(16)
+	rr = getHeader(17)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Empty(t, rr.Header().Get(HeaderRetryAfter))
+
+	// The head slot is lagging behind
+	rr = getHeader(18)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "1", rr.Header().Get(HeaderRetryAfter))
+}
+
+func TestUpdateProposerDutiesCadence(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.EthNetDetails.SlotsPerEpoch = 4
+	err := backend.redis.SetProposerDuties([]types.BuilderGetValidatorsResponseEntry{})
+	require.NoError(t, err)
+
+	// Refreshed every half epoch, or when a half epoch passed since the last refresh
+	for _, tc := range []struct {
+		headSlot    uint64
+		dutiesSlot  uint64
+		wantRefresh bool
+	}{
+		{headSlot: 2, dutiesSlot: 0, wantRefresh: true},
+		{headSlot: 3, dutiesSlot: 2, wantRefresh: false},
+		{headSlot: 4, dutiesSlot: 2, wantRefresh: true},
+		{headSlot: 5, dutiesSlot: 4, wantRefresh: false},
+		{headSlot: 9, dutiesSlot: 7, wantRefresh: true},
+	} {
+		backend.relay.proposerDutiesSlot = tc.dutiesSlot
+		backend.relay.updateProposerDuties(tc.headSlot)
+		if tc.wantRefresh {
+			require.Equal(t, tc.headSlot, backend.relay.proposerDutiesSlot, "head slot %d", tc.headSlot)
+		} else {
+			require.Equal(t, tc.dutiesSlot, backend.relay.proposerDutiesSlot, "head slot %d", tc.headSlot)
+		}
+	}
+}
+
+func TestSampleSubmissionLog(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	require.True(t, backend.relay.sampleSubmissionLog())
+
+	submissionLogSampleRate = 3
+	defer func() { submissionLogSampleRate = 1 }()
+	numSampled := 0
+	for i := 0; i < 9; i++ {
+		if backend.relay.sampleSubmissionLog() {
+			numSampled++
+		}
+	}
+	require.Equal(t, 3, numSampled)
+}
+
+func TestGetHeaderETag(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	proposerPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	parentHash := "0x13e606c7b3d1faad7e83503ce3dedce4c6bb89b0c28ffb240d713c7b110b9747"
+	path := fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", 1, parentHash, proposerPubkey)
+
+	saveBid := func(builderPubkey string, value uint64) {
+		bid := &types.GetHeaderResponse{
+			Version: "bellatrix",
+			Data: &types.SignedBuilderBid{
+				Message: &types.BuilderBid{
+					Header: &types.ExecutionPayloadHeader{BlockHash: types.Hash{byte(value)}},
+					Value:  types.IntToU256(value),
+				},
+			},
+		}
+		err := backend.relay.redis.SaveLatestBuilderBid(1, builderPubkey, parentHash, proposerPubkey, time.Now(), bid)
+		require.NoError(t, err)
+		err = backend.relay.redis.UpdateTopBid(1, parentHash, proposerPubkey, datastore.TieBreakFirstSeen, nil)
+		require.NoError(t, err)
+	}
+	getHeader := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set(HeaderIfNoneMatch, ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		backend.relay.getRouter().ServeHTTP(rr, req)
+		return rr
+	}
+
+	saveBid("0xb1", 100)
+	rr := getHeader("")
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get(HeaderETag)
+	require.NotEmpty(t, etag)
+
+	// The top bid is unchanged
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag} {
+		rr = getHeader(ifNoneMatch)
+		require.Equal(t, http.StatusNotModified, rr.Code, ifNoneMatch)
+		require.Empty(t, rr.Body.String())
+		require.Equal(t, etag, rr.Header().Get(HeaderETag))
+	}
+
+	// A new top bid changes the ETag
+	saveBid("0xb2", 101)
+	rr = getHeader(etag)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotEqual(t, etag, rr.Header().Get(HeaderETag))
+	bid := new(types.GetHeaderResponse)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), bid))
+	require.Equal(t, "101", bid.Data.Message.Value.String())
+}
+
+func TestGetHeaderTopBids(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	proposerPubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	parentHash := "0x13e606c7b3d1faad7e83503ce3dedce4c6bb89b0c28ffb240d713c7b110b9747"
+	path := fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", 1, parentHash, proposerPubkey)
+
+	for i, value := range []uint64{100, 102, 0, 101} {
+		bid := &types.GetHeaderResponse{
+			Version: "bellatrix",
+			Data: &types.SignedBuilderBid{
+				Message: &types.BuilderBid{
+					Header: &types.ExecutionPayloadHeader{},
+					Value:  types.IntToU256(value),
+				},
+			},
+		}
+		err := backend.relay.redis.SaveLatestBuilderBid(1, fmt.Sprintf("0xb%d", i), parentHash, proposerPubkey, time.Now(), bid)
+		require.NoError(t, err)
+	}
+	err := backend.relay.redis.UpdateTopBid(1, parentHash, proposerPubkey, datastore.TieBreakFirstSeen, nil)
+	require.NoError(t, err)
+
+	// Without the flag only the best bid is returned
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	bid := new(types.GetHeaderResponse)
+	err = json.Unmarshal(rr.Body.Bytes(), bid)
+	require.NoError(t, err)
+	require.Equal(t, "102", bid.Data.Message.Value.String())
+
+	getTopBids := func(top int) []string {
+		rr := backend.request(http.MethodGet, fmt.Sprintf("%s?top=%d", path, top), nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		bids := []*types.GetHeaderResponse{}
+		err := json.Unmarshal(rr.Body.Bytes(), &bids)
+		require.NoError(t, err)
+		values := []string{}
+		for _, bid := range bids {
+			values = append(values, bid.Data.Message.Value.String())
+		}
+		return values
+	}
+
+	require.Equal(t, []string{"102", "101"}, getTopBids(2))
+	require.Equal(t, []string{"102", "101", "100"}, getTopBids(10))
+
+	rr = backend.request(http.MethodGet, path+"?top=abc", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = backend.request(http.MethodGet, path+"?top=100", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestBuilderLists(t *testing.T) {
+	pubkey, secretkey, backend := startTestBackend(t)
+	otherPubkey := "0xa1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca2490"
+	req := common.TestBuilderSubmitBlockRequest(pubkey, secretkey, getTestBidTrace(*pubkey, collateral))
+
+	submit := func() int {
+		return backend.request(http.MethodPost, pathSubmitNewBlock, req).Code
+	}
+
+	// Reloading without configured lists fails.
+	rr := backend.request(http.MethodPost, pathInternalBuilderLists, nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	allowlistFile := filepath.Join(t.TempDir(), "allowlist.txt")
+	denylistFile := filepath.Join(t.TempDir(), "denylist.txt")
+	backend.relay.builderAllowlistFile = allowlistFile
+	backend.relay.builderDenylistFile = denylistFile
+
+	writeLists := func(allowlist, denylist string) {
+		require.NoError(t, os.WriteFile(allowlistFile, []byte(allowlist), 0o600))
+		require.NoError(t, os.WriteFile(denylistFile, []byte(denylist), 0o600))
+		rr := backend.request(http.MethodPost, pathInternalBuilderLists, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	// Allowlisted builder.
+	writeLists(pubkey.String()+"\n", "")
+	require.Equal(t, http.StatusOK, submit())
+
+	// Not in the allowlist.
+	writeLists(otherPubkey+"\n", "")
+	require.Equal(t, http.StatusForbidden, submit())
+
+	// The denylist applies even to allowlisted builders.
+	writeLists(pubkey.String()+"\n", pubkey.String()+"\n")
+	require.Equal(t, http.StatusForbidden, submit())
+
+	// Denied builders are rejected before the signature is verified.
+	req.Signature = types.Signature{}
+	require.Equal(t, http.StatusForbidden, submit())
+}
+
+func TestRequestID(t *testing.T) {
+	backend := newTestBackend(t, 1)
+
+	t.Run("Incoming request ID is echoed", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, pathGetPayload, bytes.NewReader([]byte("invalid")))
+		require.NoError(t, err)
+		req.Header.Set(HeaderRequestID, "test-request-id")
+		rr := httptest.NewRecorder()
+		backend.relay.getRouter().ServeHTTP(rr, req)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Equal(t, "test-request-id", rr.Header().Get(HeaderRequestID))
+
+		resp := HTTPErrorResp{}
+		err = json.Unmarshal(rr.Body.Bytes(), &resp)
+		require.NoError(t, err)
+		require.Equal(t, "test-request-id", resp.RequestID)
+	})
+
+	t.Run("Request ID is generated if missing", func(t *testing.T) {
+		rr := backend.request(http.MethodGet, pathStatus, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Len(t, rr.Header().Get(HeaderRequestID), 32)
+	})
+}
+
+func TestDataApiGetActiveValidators(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	rr := backend.request(http.MethodGet, pathDataActiveValidators, nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	backend.relay.ffEnableActiveValidatorsAPI = true
+	pubkeys := []types.PubkeyHex{}
+	for i := 0; i < 3; i++ {
+		pk := types.PubkeyHex(fmt.Sprintf("0x%096d", i))
+		pubkeys = append(pubkeys, pk)
+		err := backend.redis.SetActiveValidator(pk)
+		require.NoError(t, err)
+	}
+
+	rr = backend.request(http.MethodGet, pathDataActiveValidators+"?limit=0", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = backend.request(http.MethodGet, pathDataActiveValidators+"?cursor=0x1234", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	resp := ActiveValidatorsResponse{}
+	rr = backend.request(http.MethodGet, pathDataActiveValidators+"?limit=2", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, pubkeys[0:2], resp.Validators)
+	require.Equal(t, pubkeys[1], resp.NextCursor)
+
+	cursor := resp.NextCursor
+	resp = ActiveValidatorsResponse{}
+	rr = backend.request(http.MethodGet, pathDataActiveValidators+"?limit=2&cursor="+cursor.String(), nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, pubkeys[2:], resp.Validators)
+	require.Equal(t, types.PubkeyHex(""), resp.NextCursor)
+}
+
+func TestRecordFeeRecipientChange(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	payload, err := generateSignedValidatorRegistration(nil, types.Address{2}, 100)
+	require.NoError(t, err)
+	pubkey := payload.Message.Pubkey.String()
+
+	db := database.MockDB{
+		ValidatorRegistrations: map[string]*database.ValidatorRegistrationEntry{},
+		FeeRecipientChanges:    map[string][]*database.FeeRecipientChangeEntry{},
+	}
+	backend.relay.db = db
+
+	// No previous registration
+	backend.relay.recordFeeRecipientChange(*payload)
+	require.Len(t, db.FeeRecipientChanges[pubkey], 0)
+
+	// Same fee recipient
+	db.ValidatorRegistrations[pubkey] = &database.ValidatorRegistrationEntry{Pubkey: pubkey, FeeRecipient: types.Address{2}.String(), Timestamp: 90}
+	backend.relay.recordFeeRecipientChange(*payload)
+	require.Len(t, db.FeeRecipientChanges[pubkey], 0)
+
+	// Previous registration is not older
+	db.ValidatorRegistrations[pubkey] = &database.ValidatorRegistrationEntry{Pubkey: pubkey, FeeRecipient: types.Address{1}.String(), Timestamp: 100}
+	backend.relay.recordFeeRecipientChange(*payload)
+	require.Len(t, db.FeeRecipientChanges[pubkey], 0)
+
+	// Changed fee recipient
+	db.ValidatorRegistrations[pubkey].Timestamp = 90
+	backend.relay.recordFeeRecipientChange(*payload)
+	require.Len(t, db.FeeRecipientChanges[pubkey], 1)
+	change := db.FeeRecipientChanges[pubkey][0]
+	require.Equal(t, types.Address{1}.String(), change.OldFeeRecipient)
+	require.Equal(t, types.Address{2}.String(), change.NewFeeRecipient)
+	require.Equal(t, uint64(100), change.Timestamp)
+}
+
+func TestDataApiGetFeeRecipientChanges(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	pubkey := fmt.Sprintf("0x%096d", 1)
+	backend.relay.db = database.MockDB{
+		FeeRecipientChanges: map[string][]*database.FeeRecipientChangeEntry{
+			pubkey: {{Pubkey: pubkey, OldFeeRecipient: "0xf1", NewFeeRecipient: "0xf2", Timestamp: 100}},
+		},
+	}
+
+	rr := backend.request(http.MethodGet, pathDataFeeRecipientChanges+"?pubkey=0x1234", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = backend.request(http.MethodGet, pathDataFeeRecipientChanges+"?limit=501", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = backend.request(http.MethodGet, pathDataFeeRecipientChanges+"?pubkey="+pubkey, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := []common.FeeRecipientChangeJSON{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, []common.FeeRecipientChangeJSON{{Pubkey: pubkey, OldFeeRecipient: "0xf1", NewFeeRecipient: "0xf2", Timestamp: 100}}, resp)
+}
+
+func TestDataApiValidatorRegistrationHistory(t *testing.T) {
+	path := "/relay/v1/data/validator_registration_history"
+	backend := newTestBackend(t, 1)
+	pubkey := fmt.Sprintf("0x%096d", 1)
+	signature := fmt.Sprintf("0x%0192d", 2)
+	backend.relay.db = database.MockDB{
+		RegistrationHistory: map[string][]*database.ValidatorRegistrationEntry{
+			pubkey: {
+				{Pubkey: pubkey, FeeRecipient: fmt.Sprintf("0x%040d", 1), Timestamp: 100, GasLimit: 30000000, Signature: signature},
+				{Pubkey: pubkey, FeeRecipient: fmt.Sprintf("0x%040d", 2), Timestamp: 200, GasLimit: 30000000, Signature: signature},
+			},
+		},
+	}
+
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = backend.request(http.MethodGet, path+"?pubkey=0x1234", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = backend.request(http.MethodGet, path+"?pubkey="+pubkey, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := []*types.SignedValidatorRegistration{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(resp))
+	require.Equal(t, uint64(100), resp[0].Message.Timestamp)
+	require.Equal(t, fmt.Sprintf("0x%040d", 2), resp[1].Message.FeeRecipient.String())
+
+	// No registrations yet
+	rr = backend.request(http.MethodGet, path+"?pubkey="+fmt.Sprintf("0x%096d", 3), nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "[]\n", rr.Body.String())
+}
+
+func TestInternalConfig(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.ffDisableBlockPublishing.Store(true)
+	backend.relay.optimisticEnabled.Store(false)
+
+	rr := backend.request(http.MethodGet, pathInternalConfig, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, strings.ToLower(rr.Body.String()), "secret")
+
+	resp := RelayConfigResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, "test", resp.Network)
+	require.Equal(t, backend.relay.publicKey.String(), resp.Pubkey)
+	require.True(t, resp.ProposerAPI)
+	require.False(t, resp.PprofAPI)
+	require.True(t, resp.FeatureFlags["disable_block_publishing"])
+	require.False(t, resp.FeatureFlags["force_get_header_204"])
+	require.False(t, resp.OptimisticEnabled)
+}
+
+func TestInternalBuildInfo(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.BuildInfo = BuildInfo{
+		Version:   "v1.2.3",
+		Commit:    "0123456789abcdef",
+		BuildTime: "2023-01-01T00:00:00Z",
+	}
+
+	rr := backend.request(http.MethodGet, pathInternalBuildInfo, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get(HeaderRelayBuild))
+
+	resp := BuildInfo{}
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, backend.relay.opts.BuildInfo, resp)
+
+	// With the header enabled, every response identifies the build
+	backend.relay.ffBuildInfoHeader = true
+	rr = backend.request(http.MethodGet, "/", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "v1.2.3 (commit: 0123456789abcdef, built: 2023-01-01T00:00:00Z)", rr.Header().Get(HeaderRelayBuild))
 }
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/flashbots/go-boost-utils/bls"
+	"github.com/flashbots/go-boost-utils/types"
+)
+
+var ErrMissingSigner = errors.New("signer is nil")
+
+// ISigner signs messages with the relay key. Implementations may keep the key outside of the process,
+// for example in a KMS or HSM, and are passed to the relay with RelayAPIOpts.Signer.
+type ISigner interface {
+	PublicKey() types.PublicKey
+	Sign(domain types.Domain, message types.HashTreeRoot) (types.Signature, error)
+}
+
+// LocalSigner signs with a secret key held in memory
+type LocalSigner struct {
+	sk     *bls.SecretKey
+	pubkey types.PublicKey
+}
+
+func NewLocalSigner(sk *bls.SecretKey) (*LocalSigner, error) {
+	if sk == nil {
+		return nil, ErrMissingSecretKey
+	}
+
+	pubkey, err := types.BlsPublicKeyToPublicKey(bls.PublicKeyFromSecretKey(sk))
+	if err != nil {
+		return nil, err
+	}
+	return &LocalSigner{sk: sk, pubkey: pubkey}, nil
+}
+
+func (s *LocalSigner) PublicKey() types.PublicKey {
+	return s.pubkey
+}
+
+func (s *LocalSigner) Sign(domain types.Domain, message types.HashTreeRoot) (types.Signature, error) {
+	return types.SignMessage(message, domain, s.sk)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/flashbots/go-boost-utils/bls"
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/stretchr/testify/require"
+)
+
+var errSignerUnavailable = errors.New("signer unavailable")
+
+type remoteSignerStub struct {
+	pubkey types.PublicKey
+}
+
+func (s *remoteSignerStub) PublicKey() types.PublicKey {
+	return s.pubkey
+}
+
+func (s *remoteSignerStub) Sign(domain types.Domain, message types.HashTreeRoot) (types.Signature, error) {
+	return types.Signature{}, errSignerUnavailable
+}
+
+func TestLocalSigner(t *testing.T) {
+	_, err := NewLocalSigner(nil)
+	require.ErrorIs(t, err, ErrMissingSecretKey)
+
+	sk, blsPubkey, err := bls.GenerateNewKeypair()
+	require.NoError(t, err)
+	signer, err := NewLocalSigner(sk)
+	require.NoError(t, err)
+	pubkey := signer.PublicKey()
+	require.Equal(t, blsPubkey.Compress(), pubkey[:])
+
+	msg := &types.BuilderBid{Value: types.IntToU256(1), Header: &types.ExecutionPayloadHeader{}}
+	sig, err := signer.Sign(builderSigningDomain, msg)
+	require.NoError(t, err)
+	ok, err := types.VerifySignature(msg, builderSigningDomain, pubkey[:], sig[:])
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestExecutionPayloadToSignedBuilderBidSigner(t *testing.T) {
+	payload := &types.ExecutionPayload{BlockHash: types.Hash{0x09}}
+
+	_, err := ExecutionPayloadToSignedBuilderBid(payload, types.IntToU256(1), nil, builderSigningDomain)
+	require.ErrorIs(t, err, ErrMissingSigner)
+
+	// Errors of an external signer are reported as signing failures
+	signer := &remoteSignerStub{pubkey: types.PublicKey{0x01}}
+	_, err = ExecutionPayloadToSignedBuilderBid(payload, types.IntToU256(1), signer, builderSigningDomain)
+	require.ErrorIs(t, err, ErrSigningFailed)
+}
@@ -2,50 +2,248 @@ package api
 
 import (
 	"errors"
+	"fmt"
 
-	"github.com/flashbots/go-boost-utils/bls"
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/database"
 )
 
 var (
 	ErrMissingRequest   = errors.New("req is nil")
 	ErrMissingSecretKey = errors.New("secret key is nil")
+	ErrSigningFailed    = errors.New("failed to sign builder bid")
 )
 
 type HTTPErrorResp struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 var NilResponse = struct{}{}
 
+// DurationPercentiles are in microseconds
+type DurationPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// SubmissionStatsResponse holds duration percentiles of the block submissions in a slot range
+type SubmissionStatsResponse struct {
+	SlotFrom       uint64 `json:"slot_from"`
+	SlotTo         uint64 `json:"slot_to"`
+	NumSubmissions uint64 `json:"num_submissions"`
+
+	Decode      DurationPercentiles `json:"decode"`
+	Simulation  DurationPercentiles `json:"simulation"`
+	RedisUpdate DurationPercentiles `json:"redis_update"`
+	Submission  DurationPercentiles `json:"submission"`
+}
+
+// SimulationStatsEntry holds the average simulation duration of the recent submissions of a builder
+type SimulationStatsEntry struct {
+	BuilderPubkey   string `json:"builder_pubkey"`
+	AvgSimulationUs uint64 `json:"avg_simulation_us"`
+}
+
+// RelayInfoResponse identifies the relay and the network it serves, so clients can verify their configuration
+type RelayInfoResponse struct {
+	Pubkey                string `json:"pubkey"`
+	Network               string `json:"network"`
+	GenesisTime           uint64 `json:"genesis_time,string"`
+	GenesisForkVersion    string `json:"genesis_fork_version"`
+	GenesisValidatorsRoot string `json:"genesis_validators_root"`
+	DomainBuilder         string `json:"domain_builder"`
+	DomainBeaconProposer  string `json:"domain_beacon_proposer"`
+}
+
+// ProposerDutiesResponse holds the upcoming slots of a proposer known to the relay, and whether it has its registration
+type ProposerDutiesResponse struct {
+	Pubkey     string   `json:"pubkey"`
+	Slots      []uint64 `json:"slots"`
+	Registered bool     `json:"registered"`
+}
+
+// PrevRandaoResponse holds the prev_randao expected in block submissions for the slot
+type PrevRandaoResponse struct {
+	Slot       uint64 `json:"slot,string"`
+	PrevRandao string `json:"prev_randao"`
+}
+
+// BuilderDemotionResponse holds the details of a builder demotion caused by a failed simulation
+type BuilderDemotionResponse struct {
+	Slot           uint64 `json:"slot,string"`
+	BuilderPubkey  string `json:"builder_pubkey"`
+	ProposerPubkey string `json:"proposer_pubkey"`
+	BlockHash      string `json:"block_hash"`
+	Value          string `json:"value"`
+
+	SimError         string `json:"sim_error"`
+	SimErrorResponse string `json:"sim_error_response"`
+	Refunded         bool   `json:"refunded"`
+	RefundReady      bool   `json:"refund_ready"`
+}
+
+// builderDemotionEntryToResponse converts a demotion entry, RefundReady is set once both parts of the refund justification are stored
+func builderDemotionEntryToResponse(demotion *database.BuilderDemotionEntry) BuilderDemotionResponse {
+	return BuilderDemotionResponse{
+		Slot:             demotion.Slot,
+		BuilderPubkey:    demotion.BuilderPubkey,
+		ProposerPubkey:   demotion.ProposerPubkey,
+		BlockHash:        demotion.BlockHash,
+		Value:            demotion.Value,
+		SimError:         demotion.SubmitBlockSimError,
+		SimErrorResponse: demotion.SubmitBlockSimErrorResponse,
+		Refunded:         demotion.SignedBeaconBlock.Valid,
+		RefundReady:      demotion.SignedBeaconBlock.Valid && demotion.SignedValidatorRegistration.Valid,
+	}
+}
+
+// DeliveredPayloadResponse is a delivered payload, Reorged is set once its block was found not to be canonical after finalization
+type DeliveredPayloadResponse struct {
+	common.BidTraceV2JSON
+	Reorged bool `json:"reorged"`
+}
+
+// RelayConfigResponse is the effective configuration of a relay instance, without any secrets
+type RelayConfigResponse struct {
+	ListenAddr      string `json:"listen_addr"`
+	Network         string `json:"network"`
+	Pubkey          string `json:"pubkey"`
+	NumBlockSimURLs int    `json:"num_block_sim_urls"`
+
+	ProposerAPI     bool `json:"proposer_api"`
+	BlockBuilderAPI bool `json:"block_builder_api"`
+	DataAPI         bool `json:"data_api"`
+	PprofAPI        bool `json:"pprof_api"`
+	InternalAPI     bool `json:"internal_api"`
+
+	FeatureFlags      map[string]bool `json:"feature_flags"`
+	OptimisticEnabled bool            `json:"optimistic_enabled"`
+}
+
+// ActiveValidatorsResponse is a page of active validators, NextCursor is empty on the last page
+type ActiveValidatorsResponse struct {
+	Validators []types.PubkeyHex `json:"validators"`
+	NextCursor types.PubkeyHex   `json:"next_cursor"`
+}
+
+// SlotWinnerResponse identifies the builder whose payload was delivered for a slot
+type SlotWinnerResponse struct {
+	Slot           uint64 `json:"slot,string"`
+	BuilderPubkey  string `json:"builder_pubkey"`
+	ProposerPubkey string `json:"proposer_pubkey"`
+	BlockHash      string `json:"block_hash"`
+	Value          string `json:"value"`
+}
+
+// BuilderStatsResponse aggregates the submissions and delivered payloads of a builder in a slot range, values in wei
+type BuilderStatsResponse struct {
+	BuilderPubkey  string `json:"builder_pubkey"`
+	SlotFrom       uint64 `json:"slot_from,string"`
+	SlotTo         uint64 `json:"slot_to,string"`
+	NumSubmissions uint64 `json:"num_submissions,string"`
+	NumSimErrors   uint64 `json:"num_sim_errors,string"`
+	AvgValue       string `json:"avg_value"`
+	MaxValue       string `json:"max_value"`
+	NumDelivered   uint64 `json:"num_delivered,string"`
+	ValueDelivered string `json:"value_delivered"`
+}
+
+// DataAPIPageResponse wraps data API results with ?envelope=1, NextCursor is empty on the last page
+type DataAPIPageResponse struct {
+	Data       interface{} `json:"data"`
+	Count      int         `json:"count"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+// SubmissionSkippedResponse is returned with 200 for block submissions which are accepted but not processed,
+// see handleSubmitNewBlock for the status codes of rejected submissions
+type SubmissionSkippedResponse struct {
+	Reason string `json:"reason"`
+}
+
+// HeaderRequestID is read from incoming requests and set on all responses, to correlate logs of a single request
+const HeaderRequestID = "X-Request-ID"
+
+const maxRequestIDLength = 64
+
+// HeaderRelayBuild is set on all responses with BUILD_INFO_HEADER, to identify the build of the instance
+const HeaderRelayBuild = "X-Relay-Build"
+
+// HeaderIdempotencyKey is set by builders on block submissions, retries with the same key get the original response
+const HeaderIdempotencyKey = "X-Idempotency-Key"
+
+// HeaderEthConsensusVersion is set on SSZ encoded getPayload responses, which don't include the version
+const HeaderEthConsensusVersion = "Eth-Consensus-Version"
+
+// HeaderDutiesLookaheadSlots is set on getValidators responses to the number of slots after the head slot with known proposer duties
+const HeaderDutiesLookaheadSlots = "X-Duties-Lookahead-Slots"
+
+// HeaderLastHeadEventAgeMs is set on the status endpoint to the time since the latest beacon node head event
+const HeaderLastHeadEventAgeMs = "X-Last-Head-Event-Age-Ms"
+
+// HeaderRetryAfter is set on getHeader 204 responses while the relay is not ready for the slot yet, in seconds
+const HeaderRetryAfter = "Retry-After"
+
+const getHeaderRetryAfterSeconds = "1"
+
+// HeaderETag is set on getHeader responses to identify the bid by its block hash and value
+const HeaderETag = "ETag"
+
+// HeaderIfNoneMatch is set by clients polling getHeader with the ETag of the bid they have, to get 304 while it is unchanged
+const HeaderIfNoneMatch = "If-None-Match"
+
+// MediaTypeOctetStream is accepted by proposers to receive the getPayload response SSZ encoded
+const MediaTypeOctetStream = "application/octet-stream"
+
+// BuildInfo identifies the relay binary, set with ldflags during the build
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+func (b BuildInfo) String() string {
+	return fmt.Sprintf("%s (commit: %s, built: %s)", b.Version, b.Commit, b.BuildTime)
+}
+
+type requestIDContextKey struct{}
+
 var VersionBellatrix types.VersionString = "bellatrix"
 
 var ZeroU256 = types.IntToU256(0)
 
-func BuilderSubmitBlockRequestToSignedBuilderBid(req *types.BuilderSubmitBlockRequest, sk *bls.SecretKey, pubkey *types.PublicKey, domain types.Domain) (*types.SignedBuilderBid, error) {
+func BuilderSubmitBlockRequestToSignedBuilderBid(req *types.BuilderSubmitBlockRequest, signer ISigner, domain types.Domain) (*types.SignedBuilderBid, error) {
 	if req == nil {
 		return nil, ErrMissingRequest
 	}
 
-	if sk == nil {
-		return nil, ErrMissingSecretKey
+	return ExecutionPayloadToSignedBuilderBid(req.ExecutionPayload, req.Message.Value, signer, domain)
+}
+
+// ExecutionPayloadToSignedBuilderBid signs the bid of the relay for an execution payload
+func ExecutionPayloadToSignedBuilderBid(payload *types.ExecutionPayload, value types.U256Str, signer ISigner, domain types.Domain) (*types.SignedBuilderBid, error) {
+	if signer == nil {
+		return nil, ErrMissingSigner
 	}
 
-	header, err := types.PayloadToPayloadHeader(req.ExecutionPayload)
+	header, err := types.PayloadToPayloadHeader(payload)
 	if err != nil {
 		return nil, err
 	}
 
 	builderBid := types.BuilderBid{
-		Value:  req.Message.Value,
+		Value:  value,
 		Header: header,
-		Pubkey: *pubkey,
+		Pubkey: signer.PublicKey(),
 	}
 
-	sig, err := types.SignMessage(&builderBid, domain, sk)
+	sig, err := signer.Sign(domain, &builderBid)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrSigningFailed, err)
 	}
 
 	return &types.SignedBuilderBid{
@@ -82,3 +280,16 @@ type BuilderBlockValidationRequest struct {
 	types.BuilderSubmitBlockRequest
 	RegisteredGasLimit uint64 `json:"registered_gas_limit,string"`
 }
+
+// FeatureFlagsResponse is the state of the feature flags that can be changed at runtime
+type FeatureFlagsResponse struct {
+	ForceGetHeader204      bool `json:"force_get_header_204"`
+	DisableBlockPublishing bool `json:"disable_block_publishing"`
+	DisableLowPrioBuilders bool `json:"disable_low_prio_builders"`
+}
+
+// FeeRecipientAllowlistResponse is the list of fee recipients a proposer's blocks may use, empty if not restricted
+type FeeRecipientAllowlistResponse struct {
+	ProposerPubkey string   `json:"proposer_pubkey"`
+	FeeRecipients  []string `json:"fee_recipients"`
+}
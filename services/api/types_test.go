@@ -50,10 +50,10 @@ func TestBuilderBlockRequestToSignedBuilderBid(t *testing.T) {
 	sk, _, err := bls.GenerateNewKeypair()
 	require.NoError(t, err)
 
-	publicKey, err := types.BlsPublicKeyToPublicKey(bls.PublicKeyFromSecretKey(sk))
+	signer, err := NewLocalSigner(sk)
 	require.NoError(t, err)
 
-	signedBuilderBid, err := BuilderSubmitBlockRequestToSignedBuilderBid(&reqPayload, sk, &publicKey, builderSigningDomain)
+	signedBuilderBid, err := BuilderSubmitBlockRequestToSignedBuilderBid(&reqPayload, signer, builderSigningDomain)
 	require.NoError(t, err)
 
 	require.Equal(t, 0, signedBuilderBid.Message.Value.Cmp(&reqPayload.Message.Value))
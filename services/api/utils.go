@@ -1,16 +1,105 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	ssz "github.com/ferranbt/fastssz"
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
 )
 
 var (
 	ErrBlockHashMismatch  = errors.New("blockHash mismatch")
 	ErrParentHashMismatch = errors.New("parentHash mismatch")
+	ErrValueAboveFeeBound = errors.New("value is implausibly high for the fees of the block")
+
+	ErrPayloadBlockHashMismatch = errors.New("blockHash does not match execution payload")
+	ErrPayloadHeaderMismatch    = errors.New("execution payload does not match the signed blinded block")
+
+	ErrBodyReadTimeout = errors.New("timeout reading the request body")
 )
 
+// zstdDecoderPool reuses zstd decoders across block submissions, since each one allocates sizeable buffers
+var zstdDecoderPool = sync.Pool{
+	New: func() any {
+		zr, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		return zr
+	},
+}
+
+// statusRecorder passes a response through and keeps a copy of its status code
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// responseRecorder is a statusRecorder which also keeps a copy of the response body
+type responseRecorder struct {
+	*statusRecorder
+	body bytes.Buffer
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{statusRecorder: newStatusRecorder(w)}
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.statusRecorder.Write(b)
+}
+
+// bidETag returns the ETag of a getHeader bid, which changes with the block hash or the value of the bid
+func bidETag(bid *types.GetHeaderResponse) string {
+	return fmt.Sprintf(`"%s-%s"`, bid.Data.Message.Header.BlockHash.String(), bid.Data.Message.Value.String())
+}
+
+// etagMatches returns true if the If-None-Match header value matches the ETag, ignoring weak validator prefixes
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateUTF8 shortens s to at most maxBytes bytes without splitting a multi-byte character
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxBytes], "")
+}
+
+// SanityCheckBuilderBlockSubmission ensures the bid trace and the execution payload agree on the block and parent hash.
+// With VALUE_FEE_BOUND_FACTOR, it also rejects values above that factor times gas_used * base_fee_per_gas.
 func SanityCheckBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest) error {
 	if payload.Message.BlockHash != payload.ExecutionPayload.BlockHash {
 		return ErrBlockHashMismatch
@@ -20,6 +109,16 @@ func SanityCheckBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest)
 		return ErrParentHashMismatch
 	}
 
+	// Legitimate values can exceed the fees through direct payments to the proposer, but not by orders of magnitude
+	baseFee := payload.ExecutionPayload.BaseFeePerGas.BigInt()
+	if valueFeeBoundFactor > 0 && baseFee.Sign() > 0 {
+		bound := new(big.Int).Mul(baseFee, new(big.Int).SetUint64(payload.ExecutionPayload.GasUsed))
+		bound.Mul(bound, big.NewInt(int64(valueFeeBoundFactor)))
+		if payload.Message.Value.BigInt().Cmp(bound) > 0 {
+			return ErrValueAboveFeeBound
+		}
+	}
+
 	return nil
 }
 
@@ -27,3 +126,232 @@ func checkBLSPublicKeyHex(pkHex string) error {
 	var proposerPubkey types.PublicKey
 	return proposerPubkey.UnmarshalText([]byte(pkHex))
 }
+
+// ComputeBlockHash computes the execution layer block hash from the execution payload fields and transactions
+func ComputeBlockHash(payload *types.ExecutionPayload) (types.Hash, error) {
+	txs := make(gethtypes.Transactions, len(payload.Transactions))
+	for i, txBytes := range payload.Transactions {
+		tx := new(gethtypes.Transaction)
+		if err := tx.UnmarshalBinary(txBytes); err != nil {
+			return types.Hash{}, fmt.Errorf("invalid transaction %d: %w", i, err)
+		}
+		txs[i] = tx
+	}
+
+	header := &gethtypes.Header{
+		ParentHash:  gethcommon.Hash(payload.ParentHash),
+		UncleHash:   gethtypes.EmptyUncleHash,
+		Coinbase:    gethcommon.Address(payload.FeeRecipient),
+		Root:        gethcommon.Hash(payload.StateRoot),
+		TxHash:      gethtypes.DeriveSha(txs, trie.NewStackTrie(nil)),
+		ReceiptHash: gethcommon.Hash(payload.ReceiptsRoot),
+		Bloom:       gethtypes.Bloom(payload.LogsBloom),
+		Difficulty:  big.NewInt(0),
+		Number:      new(big.Int).SetUint64(payload.BlockNumber),
+		GasLimit:    payload.GasLimit,
+		GasUsed:     payload.GasUsed,
+		Time:        payload.Timestamp,
+		Extra:       payload.ExtraData,
+		MixDigest:   gethcommon.Hash(payload.Random),
+		BaseFee:     payload.BaseFeePerGas.BigInt(),
+	}
+	return types.Hash(header.Hash()), nil
+}
+
+// VerifyBlockHash ensures the block hash of the payload matches its contents
+func VerifyBlockHash(payload *types.ExecutionPayload) error {
+	blockHash, err := ComputeBlockHash(payload)
+	if err != nil {
+		return err
+	}
+	if blockHash != payload.BlockHash {
+		return fmt.Errorf("%w: computed %s", ErrPayloadBlockHashMismatch, blockHash.String())
+	}
+	return nil
+}
+
+// VerifyBlindedBlockPayload ensures the beacon block reconstructed from the signed blinded block and the execution payload
+// has the same body root as the blinded block. All other body fields are copied as-is, so it's enough to compare the
+// payload header roots.
+func VerifyBlindedBlockPayload(signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock, executionPayload *types.ExecutionPayload) error {
+	header, err := types.PayloadToPayloadHeader(executionPayload)
+	if err != nil {
+		return err
+	}
+	payloadHeaderRoot, err := header.HashTreeRoot()
+	if err != nil {
+		return err
+	}
+	blindedHeaderRoot, err := signedBlindedBeaconBlock.Message.Body.ExecutionPayloadHeader.HashTreeRoot()
+	if err != nil {
+		return err
+	}
+	if payloadHeaderRoot != blindedHeaderRoot {
+		return ErrPayloadHeaderMismatch
+	}
+	return nil
+}
+
+// MarshalExecutionPayloadSSZ encodes a bellatrix execution payload as SSZ, go-boost-utils only implements it for the header
+func MarshalExecutionPayloadSSZ(payload *types.ExecutionPayload) ([]byte, error) {
+	if size := len(payload.ExtraData); size > 32 {
+		return nil, ssz.ErrBytesLengthFn("ExecutionPayload.ExtraData", size, 32)
+	}
+	if size := len(payload.Transactions); size > 1048576 {
+		return nil, ssz.ErrListTooBigFn("ExecutionPayload.Transactions", size, 1048576)
+	}
+
+	// Fixed part: the header fields, with an offset instead of the transactions root
+	offset := 508
+	size := offset + len(payload.ExtraData) + 4*len(payload.Transactions)
+	for _, tx := range payload.Transactions {
+		size += len(tx)
+	}
+	dst := make([]byte, 0, size)
+
+	dst = append(dst, payload.ParentHash[:]...)
+	dst = append(dst, payload.FeeRecipient[:]...)
+	dst = append(dst, payload.StateRoot[:]...)
+	dst = append(dst, payload.ReceiptsRoot[:]...)
+	dst = append(dst, payload.LogsBloom[:]...)
+	dst = append(dst, payload.Random[:]...)
+	dst = ssz.MarshalUint64(dst, payload.BlockNumber)
+	dst = ssz.MarshalUint64(dst, payload.GasLimit)
+	dst = ssz.MarshalUint64(dst, payload.GasUsed)
+	dst = ssz.MarshalUint64(dst, payload.Timestamp)
+	dst = ssz.WriteOffset(dst, offset)
+	offset += len(payload.ExtraData)
+	dst = append(dst, payload.BaseFeePerGas[:]...)
+	dst = append(dst, payload.BlockHash[:]...)
+	dst = ssz.WriteOffset(dst, offset)
+
+	// Variable part: extra data, then the transaction offsets followed by the transactions
+	dst = append(dst, payload.ExtraData...)
+	txOffset := 4 * len(payload.Transactions)
+	for i, tx := range payload.Transactions {
+		if size := len(tx); size > 1073741824 {
+			return nil, ssz.ErrBytesLengthFn(fmt.Sprintf("ExecutionPayload.Transactions[%d]", i), size, 1073741824)
+		}
+		dst = ssz.WriteOffset(dst, txOffset)
+		txOffset += len(tx)
+	}
+	for _, tx := range payload.Transactions {
+		dst = append(dst, tx...)
+	}
+	return dst, nil
+}
+
+// UnmarshalSignedValidatorRegistrationsSSZ decodes an SSZ list of signed validator registrations, which are fixed size
+// and simply concatenated. go-boost-utils only implements SSZ for the registration message.
+func UnmarshalSignedValidatorRegistrationsSSZ(buf []byte) ([]*types.SignedValidatorRegistration, error) {
+	const messageSize = 84
+	const registrationSize = messageSize + 96
+	if len(buf)%registrationSize != 0 {
+		return nil, ssz.ErrSize
+	}
+
+	registrations := make([]*types.SignedValidatorRegistration, len(buf)/registrationSize)
+	for i := range registrations {
+		regBuf := buf[i*registrationSize : (i+1)*registrationSize]
+		registration := &types.SignedValidatorRegistration{Message: new(types.RegisterValidatorRequestMessage)}
+		if err := registration.Message.UnmarshalSSZ(regBuf[:messageSize]); err != nil {
+			return nil, err
+		}
+		copy(registration.Signature[:], regBuf[messageSize:])
+		registrations[i] = registration
+	}
+	return registrations, nil
+}
+
+// discardLogger drops all entries, for the informational logs of sampled out requests
+var discardLogger = &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Hooks: make(logrus.LevelHooks), Level: logrus.PanicLevel}
+
+// sampledLog returns the log entry if sampled, and an entry which drops everything otherwise. It is only meant for
+// informational logs, errors and demotions have to be logged with the original entry.
+func sampledLog(log *logrus.Entry, sampled bool) *logrus.Entry {
+	if sampled {
+		return log
+	}
+	return logrus.NewEntry(discardLogger)
+}
+
+// retryWithBackoff calls fn up to 1+retries times, doubling the backoff after each failure, and returns the last error
+func retryWithBackoff(retries int, backoff time.Duration, fn func() error) (err error) {
+	for i := 0; ; i++ {
+		err = fn()
+		if err == nil || i >= retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// readBodyWithTimeout reads the body in the background, to give up on stalling clients before the server read timeout
+func readBodyWithTimeout(body io.Reader, timeout time.Duration) ([]byte, error) {
+	type readResult struct {
+		data []byte
+		err  error
+	}
+	resultC := make(chan readResult, 1)
+	go func() {
+		data, err := io.ReadAll(body)
+		resultC <- readResult{data, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-resultC:
+		return res.data, res.err
+	case <-timer.C:
+		return nil, ErrBodyReadTimeout
+	}
+}
+
+// readPubkeyList reads a file with one BLS public key per line. Empty lines and lines starting with # are ignored.
+func readPubkeyList(filename string) (map[types.PubkeyHex]bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pubkeys := make(map[types.PubkeyHex]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := checkBLSPublicKeyHex(line); err != nil {
+			return nil, fmt.Errorf("invalid pubkey %s: %w", line, err)
+		}
+		pubkeys[types.NewPubkeyHex(line)] = true
+	}
+	return pubkeys, scanner.Err()
+}
+
+// generateRequestID returns a random 16 byte hex string
+func generateRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// getRemoteAddr returns the client address of the request. If a trusted proxy header is configured and set, its last
+// entry is used, which is the address seen by the proxy.
+func getRemoteAddr(req *http.Request, proxyHeader string) string {
+	if proxyHeader != "" {
+		if value := req.Header.Get(proxyHeader); value != "" {
+			addrs := strings.Split(value, ",")
+			return strings.TrimSpace(addrs[len(addrs)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
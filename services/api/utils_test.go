@@ -0,0 +1,259 @@
+package api
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanityCheckBuilderBlockSubmission(t *testing.T) {
+	valueFeeBoundFactor = 10
+	defer func() { valueFeeBoundFactor = 0 }()
+
+	newPayload := func() *types.BuilderSubmitBlockRequest {
+		return &types.BuilderSubmitBlockRequest{
+			Message: &types.BidTrace{
+				ParentHash: types.Hash{0x01},
+				BlockHash:  types.Hash{0x02},
+				GasLimit:   30_000_000,
+				GasUsed:    15_000_000,
+			},
+			ExecutionPayload: &types.ExecutionPayload{
+				ParentHash:  types.Hash{0x01},
+				BlockHash:   types.Hash{0x02},
+				BlockNumber: 100,
+				GasLimit:    30_000_000,
+				GasUsed:     15_000_000,
+			},
+		}
+	}
+
+	testCases := []struct {
+		description string
+		modify      func(payload *types.BuilderSubmitBlockRequest)
+		expectedErr error
+	}{
+		{
+			description: "consistent",
+			modify:      func(payload *types.BuilderSubmitBlockRequest) {},
+			expectedErr: nil,
+		},
+		{
+			description: "block hash mismatch",
+			modify:      func(payload *types.BuilderSubmitBlockRequest) { payload.ExecutionPayload.BlockHash = types.Hash{0x03} },
+			expectedErr: ErrBlockHashMismatch,
+		},
+		{
+			description: "parent hash mismatch",
+			modify:      func(payload *types.BuilderSubmitBlockRequest) { payload.ExecutionPayload.ParentHash = types.Hash{0x03} },
+			expectedErr: ErrParentHashMismatch,
+		},
+		{
+			description: "value within fee bound",
+			modify: func(payload *types.BuilderSubmitBlockRequest) {
+				payload.ExecutionPayload.BaseFeePerGas = types.IntToU256(2)
+				payload.Message.Value = types.IntToU256(10 * 2 * 15_000_000)
+			},
+			expectedErr: nil,
+		},
+		{
+			description: "value above fee bound",
+			modify: func(payload *types.BuilderSubmitBlockRequest) {
+				payload.ExecutionPayload.BaseFeePerGas = types.IntToU256(2)
+				payload.Message.Value = types.IntToU256(10*2*15_000_000 + 1)
+			},
+			expectedErr: ErrValueAboveFeeBound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			payload := newPayload()
+			tc.modify(payload)
+			require.Equal(t, tc.expectedErr, SanityCheckBuilderBlockSubmission(payload))
+		})
+	}
+}
+
+func TestGetRemoteAddr(t *testing.T) {
+	testCases := []struct {
+		description string
+		remoteAddr  string
+		header      string
+		proxyHeader string
+		expected    string
+	}{
+		{"no proxy header configured", "10.0.0.1:1234", "1.2.3.4", "", "10.0.0.1"},
+		{"proxy header not set", "10.0.0.1:1234", "", "X-Forwarded-For", "10.0.0.1"},
+		{"single proxy header entry", "10.0.0.1:1234", "1.2.3.4", "X-Forwarded-For", "1.2.3.4"},
+		{"last proxy header entry", "10.0.0.1:1234", "5.6.7.8, 1.2.3.4", "X-Forwarded-For", "1.2.3.4"},
+		{"remote address without port", "10.0.0.1", "", "", "10.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.header != "" {
+				req.Header.Set("X-Forwarded-For", tc.header)
+			}
+			require.Equal(t, tc.expected, getRemoteAddr(req, tc.proxyHeader))
+		})
+	}
+}
+
+func TestVerifyBlockHash(t *testing.T) {
+	tx := gethtypes.NewTx(&gethtypes.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     7,
+		GasTipCap: big.NewInt(2),
+		GasFeeCap: big.NewInt(100),
+		Gas:       21000,
+		To:        &gethcommon.Address{0x03},
+		Value:     big.NewInt(1),
+	})
+	txBytes, err := tx.MarshalBinary()
+	require.NoError(t, err)
+
+	// Reference block built by go-ethereum
+	header := &gethtypes.Header{
+		ParentHash:  gethcommon.Hash{0x01},
+		Coinbase:    gethcommon.Address{0x02},
+		Root:        gethcommon.Hash{0x04},
+		ReceiptHash: gethcommon.Hash{0x05},
+		Bloom:       gethtypes.Bloom{0x06},
+		Difficulty:  big.NewInt(0),
+		Number:      big.NewInt(100),
+		GasLimit:    30_000_000,
+		GasUsed:     21_000,
+		Time:        1_663_000_000,
+		Extra:       []byte("builder"),
+		MixDigest:   gethcommon.Hash{0x07},
+		BaseFee:     big.NewInt(12_000_000_000),
+	}
+	block := gethtypes.NewBlock(header, gethtypes.Transactions{tx}, nil, nil, trie.NewStackTrie(nil))
+
+	h := block.Header()
+	payload := &types.ExecutionPayload{
+		ParentHash:   types.Hash(h.ParentHash),
+		FeeRecipient: types.Address(h.Coinbase),
+		StateRoot:    types.Root(h.Root),
+		ReceiptsRoot: types.Root(h.ReceiptHash),
+		LogsBloom:    types.Bloom(h.Bloom),
+		Random:       types.Hash(h.MixDigest),
+		BlockNumber:  h.Number.Uint64(),
+		GasLimit:     h.GasLimit,
+		GasUsed:      h.GasUsed,
+		Timestamp:    h.Time,
+		ExtraData:    h.Extra,
+		BlockHash:    types.Hash(block.Hash()),
+		Transactions: []hexutil.Bytes{txBytes},
+	}
+	err = payload.BaseFeePerGas.FromBig(h.BaseFee)
+	require.NoError(t, err)
+
+	require.NoError(t, VerifyBlockHash(payload))
+
+	// Modified transactions no longer match the block hash
+	payload.Transactions = []hexutil.Bytes{txBytes, txBytes}
+	require.ErrorIs(t, VerifyBlockHash(payload), ErrPayloadBlockHashMismatch)
+
+	// Invalid transaction bytes are rejected
+	payload.Transactions = []hexutil.Bytes{{0x01, 0x02}}
+	require.Error(t, VerifyBlockHash(payload))
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	errRedis := errors.New("redis unavailable")
+
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		calls := 0
+		err := retryWithBackoff(2, time.Millisecond, func() error {
+			calls++
+			if calls < 3 {
+				return errRedis
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("returns the last error when retries are exhausted", func(t *testing.T) {
+		calls := 0
+		err := retryWithBackoff(2, time.Millisecond, func() error {
+			calls++
+			return errRedis
+		})
+		require.ErrorIs(t, err, errRedis)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("no retries", func(t *testing.T) {
+		calls := 0
+		err := retryWithBackoff(0, time.Millisecond, func() error {
+			calls++
+			return errRedis
+		})
+		require.ErrorIs(t, err, errRedis)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestSampledLog(t *testing.T) {
+	logger, hook := logrustest.NewNullLogger()
+	log := logrus.NewEntry(logger)
+
+	sampledLog(log, true).Info("sampled")
+	sampledLog(log, false).Info("sampled out")
+	require.Equal(t, 1, len(hook.AllEntries()))
+	require.Equal(t, "sampled", hook.LastEntry().Message)
+}
+
+func TestMarshalExecutionPayloadSSZ(t *testing.T) {
+	payload := &types.ExecutionPayload{
+		ParentHash:    types.Hash{0x01},
+		FeeRecipient:  types.Address{0x02},
+		BlockNumber:   1234,
+		GasLimit:      30_000_000,
+		Timestamp:     1680000000,
+		ExtraData:     types.ExtraData{0x0a, 0x0b},
+		BaseFeePerGas: types.IntToU256(7),
+		BlockHash:     types.Hash{0x03},
+		Transactions:  []hexutil.Bytes{{0xc1}, {0xc2, 0xc3}},
+	}
+	header, err := types.PayloadToPayloadHeader(payload)
+	require.NoError(t, err)
+	headerSSZ, err := header.MarshalSSZ()
+	require.NoError(t, err)
+
+	payloadSSZ, err := MarshalExecutionPayloadSSZ(payload)
+	require.NoError(t, err)
+	require.Len(t, payloadSSZ, 508+2+4*2+3)
+
+	// The fixed part matches the header, except for the offsets and the transactions root
+	require.Equal(t, headerSSZ[:436], payloadSSZ[:436])
+	require.Equal(t, headerSSZ[440:504], payloadSSZ[440:504])
+	require.Equal(t, []byte{0xfc, 0x01, 0x00, 0x00}, payloadSSZ[436:440])
+	require.Equal(t, []byte{0xfe, 0x01, 0x00, 0x00}, payloadSSZ[504:508])
+
+	// Extra data, transaction offsets and transactions
+	require.Equal(t, []byte{0x0a, 0x0b}, payloadSSZ[508:510])
+	require.Equal(t, []byte{0x08, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0xc1, 0xc2, 0xc3}, payloadSSZ[510:])
+
+	payload.ExtraData = make(types.ExtraData, 33)
+	_, err = MarshalExecutionPayloadSSZ(payload)
+	require.Error(t, err)
+}
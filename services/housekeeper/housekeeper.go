@@ -8,6 +8,7 @@
 package housekeeper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/go-utils/cli"
 	"github.com/flashbots/mev-boost-relay/beaconclient"
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
@@ -28,6 +30,8 @@ type HousekeeperOpts struct {
 	Redis        *datastore.RedisCache
 	DB           database.IDatabaseService
 	BeaconClient beaconclient.IMultiBeaconClient
+
+	EthNetDetails common.EthNetworkDetails
 }
 
 type Housekeeper struct {
@@ -44,12 +48,28 @@ type Housekeeper struct {
 
 	headSlot uberatomic.Uint64
 
+	reorgCheckedSlot uint64 // last finalized slot checked for reorged delivered payloads
+
 	proposersAlreadySaved map[string]bool // to avoid repeating redis writes
 }
 
 var ErrServerAlreadyStarted = errors.New("server was already started")
 
+var (
+	// number of epochs after the current one to fetch proposer duties for
+	proposerDutiesLookaheadEpochs    = cli.GetEnvInt("PROPOSER_DUTIES_LOOKAHEAD_EPOCHS", 1)
+	maxProposerDutiesLookaheadEpochs = 1 // beacon nodes only know the proposer duties up to the next epoch
+)
+
 func NewHousekeeper(opts *HousekeeperOpts) *Housekeeper {
+	if proposerDutiesLookaheadEpochs > maxProposerDutiesLookaheadEpochs {
+		opts.Log.Warnf("env: PROPOSER_DUTIES_LOOKAHEAD_EPOCHS - %d epochs is above the maximum, using %d", proposerDutiesLookaheadEpochs, maxProposerDutiesLookaheadEpochs)
+		proposerDutiesLookaheadEpochs = maxProposerDutiesLookaheadEpochs
+	} else if proposerDutiesLookaheadEpochs < 0 {
+		opts.Log.Warnf("env: PROPOSER_DUTIES_LOOKAHEAD_EPOCHS - %d epochs is negative, using 0", proposerDutiesLookaheadEpochs)
+		proposerDutiesLookaheadEpochs = 0
+	}
+
 	server := &Housekeeper{
 		opts:                  opts,
 		log:                   opts.Log,
@@ -82,6 +102,7 @@ func (hk *Housekeeper) Start() (err error) {
 	go hk.periodicTaskUpdateKnownValidators()
 	go hk.periodicTaskLogValidators()
 	go hk.periodicTaskUpdateBuilderStatusInRedis()
+	go hk.subscribeToFinalizedCheckpoints()
 
 	// Process the current slot
 	headSlot := bestSyncStatus.HeadSlot
@@ -89,7 +110,7 @@ func (hk *Housekeeper) Start() (err error) {
 
 	// Start regular slot updates
 	c := make(chan beaconclient.HeadEventData)
-	hk.beaconClient.SubscribeToHeadEvents(c)
+	hk.beaconClient.SubscribeToHeadEvents(context.Background(), c)
 	for {
 		headEvent := <-c
 		hk.processNewSlot(headEvent.Slot)
@@ -112,7 +133,7 @@ func (hk *Housekeeper) periodicTaskLogValidators() {
 			hk.log.WithError(err).Error("failed to get number of active validators")
 		}
 
-		time.Sleep(common.DurationPerEpoch / 2)
+		time.Sleep(hk.opts.EthNetDetails.EpochDuration() / 2)
 	}
 }
 
@@ -123,15 +144,77 @@ func (hk *Housekeeper) periodicTaskUpdateKnownValidators() {
 		hk.log.Debug("periodicTaskUpdateKnownValidators done")
 
 		// Wait half an epoch
-		time.Sleep(common.DurationPerEpoch / 2)
+		time.Sleep(hk.opts.EthNetDetails.EpochDuration() / 2)
 	}
 }
 
 func (hk *Housekeeper) periodicTaskUpdateBuilderStatusInRedis() {
 	for {
 		// builders, err := hk.da
-		time.Sleep(common.DurationPerEpoch / 2)
+		time.Sleep(hk.opts.EthNetDetails.EpochDuration() / 2)
+	}
+}
+
+func (hk *Housekeeper) subscribeToFinalizedCheckpoints() {
+	c := make(chan beaconclient.FinalizedCheckpointEventData)
+	hk.beaconClient.SubscribeToFinalizedCheckpointEvents(c)
+	for {
+		finalizedEvent := <-c
+		hk.checkDeliveredPayloadsReorged(finalizedEvent.Epoch * hk.opts.EthNetDetails.SlotsInEpoch())
+	}
+}
+
+// checkDeliveredPayloadsReorged marks delivered payloads up to the finalized slot whose block is not in the canonical chain
+func (hk *Housekeeper) checkDeliveredPayloadsReorged(finalizedSlot uint64) {
+	if finalizedSlot <= hk.reorgCheckedSlot {
+		return
+	}
+
+	// On the first checkpoint, only look at the epoch that was just finalized
+	slotFrom := hk.reorgCheckedSlot + 1
+	slotsInEpoch := hk.opts.EthNetDetails.SlotsInEpoch()
+	if hk.reorgCheckedSlot == 0 && finalizedSlot > slotsInEpoch {
+		slotFrom = finalizedSlot - slotsInEpoch + 1
+	}
+
+	log := hk.log.WithFields(logrus.Fields{
+		"slotFrom": slotFrom,
+		"slotTo":   finalizedSlot,
+	})
+
+	payloads, err := hk.db.GetRecentDeliveredPayloads(database.GetPayloadsFilters{
+		SlotFrom: slotFrom,
+		SlotTo:   finalizedSlot,
+		Limit:    finalizedSlot - slotFrom + 1,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to get delivered payloads")
+		return
 	}
+
+	for _, payload := range payloads {
+		block, err := hk.beaconClient.GetBlock(fmt.Sprint(payload.Slot))
+		if err != nil && !errors.Is(err, beaconclient.ErrBlockNotFound) {
+			log.WithError(err).WithField("slot", payload.Slot).Error("failed to get canonical block")
+			return
+		}
+
+		if err == nil && block.Data.Message.Body.ExecutionPayload.BlockHash.String() == payload.BlockHash {
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"slot":      payload.Slot,
+			"blockHash": payload.BlockHash,
+		}).Warn("delivered payload is not canonical after finalization")
+		err = hk.db.SetDeliveredPayloadReorged(payload.Slot, payload.BlockHash)
+		if err != nil {
+			log.WithError(err).WithField("slot", payload.Slot).Error("failed to mark delivered payload as reorged")
+			return
+		}
+	}
+
+	hk.reorgCheckedSlot = finalizedSlot
 }
 
 func (hk *Housekeeper) processNewSlot(headSlot uint64) {
@@ -161,10 +244,10 @@ func (hk *Housekeeper) processNewSlot(headSlot uint64) {
 	}()
 
 	hk.headSlot.Store(headSlot)
-	currentEpoch := headSlot / uint64(common.SlotsPerEpoch)
+	currentEpoch := headSlot / hk.opts.EthNetDetails.SlotsInEpoch()
 	log.WithFields(logrus.Fields{
 		"epoch":              currentEpoch,
-		"slotStartNextEpoch": (currentEpoch + 1) * uint64(common.SlotsPerEpoch),
+		"slotStartNextEpoch": (currentEpoch + 1) * hk.opts.EthNetDetails.SlotsInEpoch(),
 	}).Infof("updated headSlot to %d", headSlot)
 }
 
@@ -228,15 +311,22 @@ func (hk *Housekeeper) updateProposerDuties(headSlot uint64) {
 	}
 	defer hk.isUpdatingProposerDuties.Store(false)
 
-	if headSlot%uint64(common.SlotsPerEpoch/2) != 0 && headSlot-hk.proposerDutiesSlot < uint64(common.SlotsPerEpoch/2) {
+	// Update every half epoch, at least every slot on networks with a single slot per epoch
+	slotsInEpoch := hk.opts.EthNetDetails.SlotsInEpoch()
+	halfEpoch := slotsInEpoch / 2
+	if halfEpoch == 0 {
+		halfEpoch = 1
+	}
+	if headSlot%halfEpoch != 0 && headSlot-hk.proposerDutiesSlot < halfEpoch {
 		return
 	}
 
-	epoch := headSlot / uint64(common.SlotsPerEpoch)
+	epoch := headSlot / slotsInEpoch
+	epochTo := epoch + uint64(proposerDutiesLookaheadEpochs)
 
 	log := hk.log.WithFields(logrus.Fields{
 		"epochFrom": epoch,
-		"epochTo":   epoch + 1,
+		"epochTo":   epochTo,
 	})
 	log.Debug("updating proposer duties...")
 
@@ -248,12 +338,15 @@ func (hk *Housekeeper) updateProposerDuties(headSlot uint64) {
 	}
 	entries := r.Data
 
-	// Query next epoch
-	r2, err := hk.beaconClient.GetProposerDuties(epoch + 1)
-	if err != nil {
-		log.WithError(err).Error("failed to get proposer duties for next epoch for all beacon nodes")
-	} else if r2 != nil {
-		entries = append(entries, r2.Data...)
+	// Query the lookahead epochs, stop at the first one not known to the beacon nodes yet
+	for e := epoch + 1; e <= epochTo; e++ {
+		r2, err := hk.beaconClient.GetProposerDuties(e)
+		if err != nil {
+			log.WithError(err).WithField("epoch", e).Error("failed to get proposer duties for next epoch for all beacon nodes")
+			break
+		} else if r2 != nil {
+			entries = append(entries, r2.Data...)
+		}
 	}
 
 	// Get registrations from database